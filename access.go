@@ -0,0 +1,84 @@
+package lfu
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// AccessEvent is a lookup reported to Config.AccessRecorder.
+type AccessEvent struct {
+	Key  string
+	Time time.Time
+	Hit  bool
+}
+
+// defaultAccessBuffer is the AccessBuffer used when none is configured.
+const defaultAccessBuffer = 1024
+
+// accessLog queues the lookups of cache for the recorder, which a goroutine
+// started on demand feeds them to, in order, until the queue is empty.
+type accessLog struct {
+	record   func(e AccessEvent)
+	queue    chan AccessEvent
+	draining int32
+	dropped  uint64
+}
+
+func newAccessLog(record func(e AccessEvent), buffer int) *accessLog {
+	if buffer <= 0 {
+		buffer = defaultAccessBuffer
+	}
+	return &accessLog{record: record, queue: make(chan AccessEvent, buffer)}
+}
+
+// add queues e, dropping it if the queue is full. The caller must hold the
+// lock of cache, which keeps the events in the order of the lookups.
+func (l *accessLog) add(e AccessEvent) {
+	select {
+	case l.queue <- e:
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+	}
+	l.drain()
+}
+
+// drain makes sure a goroutine is feeding the queue to the recorder.
+func (l *accessLog) drain() {
+	if !atomic.CompareAndSwapInt32(&l.draining, 0, 1) {
+		return
+	}
+	go func() {
+		for {
+			select {
+			case e := <-l.queue:
+				l.record(e)
+				continue
+			default:
+			}
+
+			atomic.StoreInt32(&l.draining, 0)
+			// an event queued after the queue looked empty, but before
+			// draining was cleared, would be stuck until the next one
+			if len(l.queue) == 0 || !atomic.CompareAndSwapInt32(&l.draining, 0, 1) {
+				return
+			}
+		}
+	}()
+}
+
+// recordAccess reports a lookup of k to the configured AccessRecorder. The
+// caller must hold the lock.
+func (c *Cache) recordAccess(k string, hit bool) {
+	if c.accesses != nil {
+		c.accesses.add(AccessEvent{Key: k, Time: c.now(), Hit: hit})
+	}
+}
+
+// DroppedAccesses returns the number of lookups that weren't reported to the
+// configured AccessRecorder because its buffer was full.
+func (c *Cache) DroppedAccesses() uint64 {
+	if c.accesses == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.accesses.dropped)
+}
@@ -0,0 +1,61 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_AccessRecorder(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	events := make(chan AccessEvent)
+	cache := New(1, WithClock(clock), WithAccessRecorder(func(e AccessEvent) {
+		events <- e
+	}, 16))
+
+	cache.Get("a")
+	cache.Set("a", 1)
+	clock.Advance(time.Second)
+	cache.Get("a")
+	cache.GetBatch([]string{"b", "a"})
+
+	var got []AccessEvent
+	for i := 0; i < 4; i++ {
+		got = append(got, <-events)
+	}
+	assert.Equal(t, []AccessEvent{
+		{Key: "a", Time: time.Unix(0, 0), Hit: false},
+		{Key: "a", Time: time.Unix(1, 0), Hit: true},
+		{Key: "b", Time: time.Unix(1, 0), Hit: false},
+		{Key: "a", Time: time.Unix(1, 0), Hit: true},
+	}, got)
+	assert.Equal(t, uint64(0), cache.DroppedAccesses())
+}
+
+func TestCache_AccessRecorderDrops(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	recorded := make(chan AccessEvent, 8)
+	cache := New(0, WithAccessRecorder(func(e AccessEvent) {
+		started <- placeholder
+		<-release
+		recorded <- e
+	}, 2))
+
+	// the recorder holds the first event, the buffer the next two, and the
+	// rest are dropped
+	cache.Get("a")
+	<-started
+	for i := 0; i < 4; i++ {
+		cache.Get("a")
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		<-recorded
+		if i < 2 {
+			<-started
+		}
+	}
+	assert.Equal(t, uint64(2), cache.DroppedAccesses())
+	assert.Equal(t, uint64(0), New(0).DroppedAccesses())
+}
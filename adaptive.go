@@ -0,0 +1,81 @@
+package lfu
+
+const (
+	// adaptWindow is the number of lookups between two capacity adjustments.
+	adaptWindow = 1000
+	// adaptMargin is how far above the target the hit ratio must be before
+	// capacity is shrunk, so it doesn't flap around the target.
+	adaptMargin = 0.05
+)
+
+// capacityController resizes a cache toward a target hit ratio.
+type capacityController struct {
+	target         float64
+	minCap, maxCap int
+
+	hits, lookups uint64
+}
+
+// SetTargetHitRatio makes cache tune its capacity toward the target hit ratio,
+// within [minCap, maxCap]. Every adaptWindow lookups, capacity grows by an
+// eighth if the hit ratio of that window is below target, and shrinks by an
+// eighth if it's comfortably above. A non-positive target stops the tuning
+// and leaves capacity where it is.
+func (c *Cache) SetTargetHitRatio(target float64, minCap, maxCap int) {
+	c.lock()
+	defer c.unlock()
+
+	if target <= 0 {
+		c.adaptive = nil
+		return
+	}
+	if minCap < 1 {
+		minCap = 1
+	}
+	if maxCap < minCap {
+		maxCap = minCap
+	}
+
+	c.adaptive = &capacityController{target: target, minCap: minCap, maxCap: maxCap}
+	c.adaptive.hits, c.adaptive.lookups = c.hits, c.lookups()
+	c.resizeCap(clampCap(c.cap, minCap, maxCap))
+}
+
+// adapt adjusts capacity once a window of lookups has passed. The caller must
+// hold the lock.
+func (c *Cache) adapt() {
+	a := c.adaptive
+	if a == nil || c.lookups()-a.lookups < adaptWindow {
+		return
+	}
+
+	lookups := c.lookups()
+	ratio := float64(c.hits-a.hits) / float64(lookups-a.lookups)
+	a.hits, a.lookups = c.hits, lookups
+
+	step := c.cap / 8
+	if step < 1 {
+		step = 1
+	}
+	switch {
+	case ratio < a.target:
+		// a maxCap near math.MaxInt is reached rather than overflowed
+		grown := a.maxCap
+		if c.cap <= a.maxCap-step {
+			grown = c.cap + step
+		}
+		c.resizeCap(clampCap(grown, a.minCap, a.maxCap))
+	case ratio > a.target+adaptMargin:
+		c.resizeCap(clampCap(c.cap-step, a.minCap, a.maxCap))
+	}
+}
+
+func clampCap(cap, minCap, maxCap int) int {
+	if cap < minCap {
+		return minCap
+	}
+	if cap > maxCap {
+		return maxCap
+	}
+	return cap
+}
@@ -0,0 +1,46 @@
+package lfu
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestCache_SetTargetHitRatio(t *testing.T) {
+	cache := New(10)
+	cache.SetTargetHitRatio(0.9, 5, 500)
+
+	r := rand.New(rand.NewSource(1))
+	readThrough := func(keys int) {
+		k := fmt.Sprintf("k%d", r.Intn(keys))
+		if _, ok := cache.Get(k); !ok {
+			cache.Set(k, k)
+		}
+	}
+
+	// 100 equally hot keys: capacity must grow to hold about 90 of them
+	for i := 0; i < 100000; i++ {
+		readThrough(100)
+	}
+	assert.True(t, cache.cap >= 75 && cache.cap <= 115, "cap %d", cache.cap)
+
+	// the working set shrinks to 20 keys: capacity follows it down, once
+	// the frequencies learned so far stop pinning the old keys
+	cache.ResetFrequencies()
+	for i := 0; i < 100000; i++ {
+		readThrough(20)
+	}
+	assert.True(t, cache.cap >= 15 && cache.cap <= 30, "cap %d", cache.cap)
+	assert.True(t, cache.Size() <= cache.cap)
+
+	cache.SetTargetHitRatio(0, 0, 0)
+	cap := cache.cap
+	for i := 0; i < 10000; i++ {
+		readThrough(1000)
+	}
+	assert.Equal(t, cap, cache.cap)
+
+	cache.SetTargetHitRatio(0.5, 40, 50)
+	assert.Equal(t, 40, cache.cap)
+}
@@ -0,0 +1,93 @@
+package lfu
+
+import "container/list"
+
+// admissionSamples is how many lookups per item of capacity the admission
+// sketch counts before halving its counts, so popularity fades with time.
+const admissionSamples = 10
+
+// admission is the W-TinyLFU admission policy: new keys enter a window of
+// the most recently stored ones, ordered by recency, and the key leaving a
+// full window only stays in cache if the sketch estimates it was looked up
+// more often than the item it would evict instead. Items in the window are
+// regular items, whose kvItem.windowed is their element in window.
+type admission struct {
+	window  *list.List
+	size    int
+	sketch  *cmSketch
+	samples int
+	limit   int
+}
+
+func newAdmission(size, cap int) *admission {
+	width := cap
+	if width < 16 {
+		width = 16
+	}
+	return &admission{
+		window: list.New(),
+		size:   size,
+		sketch: newCMSketch(width, 0),
+		limit:  width * admissionSamples,
+	}
+}
+
+// record counts a lookup of k, halving every count once enough lookups were
+// counted.
+func (a *admission) record(k string) {
+	a.sketch.add(k)
+	if a.samples++; a.samples >= a.limit {
+		a.sketch.halve()
+		a.samples /= 2
+	}
+}
+
+// admit evicts an item to make room for a new key in a full cache: either
+// the key leaving the window, or the least frequently used item out of the
+// window if the key leaving the window was looked up more often. It returns
+// the item evicted. The caller must hold the lock.
+func (c *Cache) admit() *kvItem {
+	a := c.admission
+	inWindow := func(item *kvItem) bool { return item.windowed != nil }
+
+	var candidate *kvItem
+	if a.window.Len() >= a.size {
+		candidate = a.window.Back().Value.(*kvItem)
+		if candidate.pinned {
+			c.leaveWindow(candidate)
+			candidate = nil
+		}
+	}
+	victim := c.victim(inWindow)
+	switch {
+	case candidate == nil && victim == nil:
+		return nil
+	case victim == nil:
+		victim = candidate
+	case candidate == nil:
+		// the window has room left, so nothing competes
+	case a.sketch.estimate(candidate.k) > a.sketch.estimate(victim.k):
+		c.leaveWindow(candidate)
+	default:
+		victim = candidate
+	}
+	c.evictItem(victim, ReasonCapacity)
+	return victim
+}
+
+// enterWindow adds the new item to the window, moving the oldest one out of
+// the window if it's full. The caller must hold the lock.
+func (c *Cache) enterWindow(item *kvItem) {
+	a := c.admission
+	item.windowed = a.window.PushFront(item)
+	if a.window.Len() > a.size {
+		c.leaveWindow(a.window.Back().Value.(*kvItem))
+	}
+}
+
+// leaveWindow makes item a regular item out of the window. The caller must
+// hold the lock.
+func (c *Cache) leaveWindow(item *kvItem) {
+	c.admission.window.Remove(item.windowed)
+	item.windowed = nil
+}
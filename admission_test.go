@@ -0,0 +1,77 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestCache_AdmissionWindow(t *testing.T) {
+	cache := New(4, WithAdmissionWindow(1))
+	for _, k := range []string{"a", "b", "c"} {
+		cache.Set(k, k)
+		cache.Get(k)
+		cache.Get(k)
+	}
+	cache.Set("d", "d")
+	assert.Equal(t, 4, cache.Size())
+
+	// d leaves the window never looked up, so it doesn't displace anything
+	evicted, evictedKey := cache.SetReport("e", "e")
+	assert.True(t, evicted)
+	assert.Equal(t, "d", evictedKey)
+	assert.ElementsMatch(t, []string{"a", "b", "c", "e"}, cache.Keys())
+
+	// x was missed more often than a, b and c were looked up
+	for i := 0; i < 5; i++ {
+		cache.Get("x")
+	}
+	_, evictedKey = cache.SetReport("x", "x")
+	assert.Equal(t, "e", evictedKey)
+	_, evictedKey = cache.SetReport("y", "y")
+	assert.Contains(t, []string{"a", "b", "c"}, evictedKey)
+	_, ok := cache.Peek("x")
+	assert.True(t, ok)
+	assert.Equal(t, 4, cache.Size())
+	assert.NoError(t, cache.Verify())
+
+	// items leaving cache leave the window too
+	cache.Remove("y")
+	cache.Set("z", "z")
+	assert.NoError(t, cache.Verify())
+
+	cache.Reset(4)
+	for i := 0; i < 10; i++ {
+		cache.Set(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 4, cache.Size())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_AdmissionWindowPinned(t *testing.T) {
+	cache := New(2, WithAdmissionWindow(1))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Pin("a")
+	cache.Pin("b")
+
+	// nothing can be evicted
+	cache.Set("c", 3)
+	assert.Equal(t, 3, cache.Size())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestAdmission_Aging(t *testing.T) {
+	a := newAdmission(1, 16)
+	assert.Equal(t, 16*admissionSamples, a.limit)
+	for i := 0; i < 100; i++ {
+		a.record("k")
+	}
+	assert.Equal(t, uint32(100), a.sketch.estimate("k"))
+
+	for i := 0; i < 60; i++ {
+		a.record(strconv.Itoa(i))
+	}
+	assert.Equal(t, uint32(50), a.sketch.estimate("k"))
+	assert.Equal(t, 80, a.samples)
+}
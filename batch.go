@@ -0,0 +1,217 @@
+package lfu
+
+import "sort"
+
+// GetBatch looks up all keys under a single lock. Found values are returned
+// keyed by k and counted as accesses like Get. Keys not in cache are returned
+// in missing, in the order they first appear in keys, so they can be loaded
+// from the backend in one go.
+func (c *Cache) GetBatch(keys []string) (found map[string]interface{}, missing []string) {
+	var seen map[string]struct{}
+	found = c.getBatch(keys, func(k string) {
+		if _, dup := seen[k]; !dup {
+			if seen == nil {
+				seen = make(map[string]struct{})
+			}
+			seen[k] = placeholder
+			missing = append(missing, k)
+		}
+	})
+	return
+}
+
+// MGet looks up all keys under a single lock, like GetBatch, and returns the
+// values found keyed by k, leaving out the keys not in cache. Taking the lock
+// once rather than once per key is meant for callers contending for it: on
+// an uncontended cache, building the map costs more than the Gets themselves.
+func (c *Cache) MGet(keys []string) map[string]interface{} {
+	return c.getBatch(keys, nil)
+}
+
+// getBatch looks up keys under a single lock like GetBatch, and calls missed,
+// if not nil, with every key not in cache.
+func (c *Cache) getBatch(keys []string, missed func(k string)) map[string]interface{} {
+	c.lock()
+	defer c.unlock()
+
+	found := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		nk := c.key(k)
+		item, ok := c.lookup(nk)
+		if !ok {
+			c.miss(nk)
+			if missed != nil {
+				missed(k)
+			}
+			continue
+		}
+
+		found[k] = c.clone(item.v)
+		c.hit(item)
+	}
+	return found
+}
+
+// PeekMultiple returns the values of the keys in cache under a single lock,
+// keyed by k, like Peek does: unlike GetBatch, it is frequency-neutral,
+// neither the frequency counts of the keys nor the hit and miss counts
+// change, e.g. for dashboards or checks that must not perturb cache. Keys
+// not in cache are left out.
+func (c *Cache) PeekMultiple(keys []string) map[string]interface{} {
+	c.lock()
+	defer c.unlock()
+
+	found := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		if item, ok := c.lookup(c.key(k)); ok {
+			found[k] = c.clone(item.v)
+		}
+	}
+	return found
+}
+
+// SetMultiple stores all the given kv pairs under a single lock. Keys already
+// in cache are updated like Set. New keys are stored in ascending key order,
+// evicting least frequently used items that aren't part of the batch to make
+// room. The batch never evicts its own items: if it holds more keys than
+// cache can, the new keys beyond capacity (the last ones in key order) are not
+// stored at all, nor are those left without room once only pinned items
+// remain to evict, so unlike Set the batch never grows a cache over
+// capacity. A cache configured to RejectNew evicts nothing, and only
+// stores the new keys fitting in the room left.
+func (c *Cache) SetMultiple(items map[string]interface{}) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	c.setMultiple(c.normalizeItems(items))
+}
+
+// MSet stores all the given kv pairs under a single lock, evicting once for
+// them all. It is SetMultiple, named to go with MGet.
+func (c *Cache) MSet(items map[string]interface{}) {
+	c.SetMultiple(items)
+}
+
+// SetMultipleReport works like SetMultiple, and reports which of the keys of
+// items ended up in cache, stored, and which didn't fit, dropped, each in
+// ascending key order. Together they hold every key of items.
+func (c *Cache) SetMultipleReport(items map[string]interface{}) (stored, dropped []string) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	c.setMultiple(c.normalizeItems(items))
+	for k := range items {
+		if _, ok := c.kv[c.key(k)]; ok {
+			stored = append(stored, k)
+		} else {
+			dropped = append(dropped, k)
+		}
+	}
+	sort.Strings(stored)
+	sort.Strings(dropped)
+	return
+}
+
+// normalizeItems returns items with their keys as configured by
+// KeyNormalizer, or items itself without one. The caller must hold the lock.
+func (c *Cache) normalizeItems(items map[string]interface{}) map[string]interface{} {
+	if c.normalize == nil {
+		return items
+	}
+	normalized := make(map[string]interface{}, len(items))
+	for k, v := range items {
+		normalized[c.key(k)] = v
+	}
+	return normalized
+}
+
+// setMultiple stores items as described by SetMultiple and returns the new
+// keys that didn't fit. The caller must hold the lock.
+func (c *Cache) setMultiple(items map[string]interface{}) (dropped []string) {
+	var fresh []string
+	updated := 0
+	// too long keys go first, so that Strict mode panics before any of the
+	// batch is stored
+	var long map[string]bool
+	for k := range items {
+		if c.longKey(k) {
+			if long == nil {
+				long = make(map[string]bool)
+			}
+			long[k] = true
+			dropped = append(dropped, k)
+		}
+	}
+	for k, v := range items {
+		if long[k] {
+			continue
+		}
+		if item, ok := c.lookup(k); ok {
+			c.update(item, v, measured)
+			c.increment(item)
+			updated++
+			continue
+		}
+		fresh = append(fresh, k)
+	}
+	sort.Strings(fresh)
+
+	if c.cap > 0 {
+		room := c.cap - updated
+		if c.onFull == RejectNew {
+			room = c.cap - len(c.kv)
+		}
+		if room < 0 {
+			room = 0
+		}
+		if room < len(fresh) {
+			dropped = append(dropped, fresh[room:]...)
+			fresh = fresh[:room]
+		}
+		if over := len(c.kv) + len(fresh) - c.cap; over > 0 {
+			// with pinned items, fewer may be evicted than asked for, and
+			// only the room actually made is filled
+			free := c.cap - len(c.kv)
+			if free < 0 {
+				free = 0
+			}
+			if room := free + c.evictExcept(over, items); room < len(fresh) {
+				dropped = append(dropped, fresh[room:]...)
+				fresh = fresh[:room]
+			}
+		}
+	}
+
+	for _, k := range fresh {
+		c.insert(k, items[k], c.initialFrequency(), measured)
+	}
+	if c.maxBytes > 0 {
+		c.trimBytes(c.maxBytes, func(item *kvItem) bool {
+			_, ok := items[item.k]
+			return ok
+		}, ReasonCapacity)
+	}
+	return
+}
+
+// evictExcept removes up to n least frequently used unpinned items whose keys
+// are not in keep, and returns how many it removed. The caller must hold the
+// lock.
+func (c *Cache) evictExcept(n int, keep map[string]interface{}) (evicted int) {
+	skip := func(item *kvItem) bool {
+		_, ok := keep[item.k]
+		return ok
+	}
+
+	for i := 0; i < n; i++ {
+		victim := c.victim(skip)
+		if victim == nil {
+			return
+		}
+		c.evictItem(victim, ReasonCapacity)
+		evicted++
+	}
+	return
+}
@@ -0,0 +1,144 @@
+package lfu
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCache_GetBatch(t *testing.T) {
+	cache := New(0)
+
+	found, missing := cache.GetBatch(nil)
+	assert.Equal(t, 0, len(found))
+	assert.Nil(t, missing)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+
+	found, missing = cache.GetBatch([]string{"x", "a", "c", "y", "x", "a"})
+	assert.Equal(t, map[string]interface{}{"a": 1, "c": 3}, found)
+	assert.Equal(t, []string{"x", "y"}, missing)
+	assert.Equal(t, 3, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 1, cache.kv["b"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 2, cache.kv["c"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 3, cache.Size())
+}
+
+func TestCache_MGetMSet(t *testing.T) {
+	cache := New(3)
+	cache.Set("a", 1)
+	cache.Get("a")
+
+	cache.MSet(map[string]interface{}{"b": 2, "c": 3})
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, cache.Keys())
+	// c, the least frequently used outside the batch, makes room
+	cache.Get("b")
+	cache.MSet(map[string]interface{}{"b": 2, "d": 4})
+	assert.ElementsMatch(t, []string{"a", "b", "d"}, cache.Keys())
+
+	found := cache.MGet([]string{"a", "x", "b", "a"})
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, found)
+	assert.Equal(t, 4, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 4, cache.kv["b"].parent.Value.(*freqNode).freq)
+	stats := cache.Stats()
+	assert.Equal(t, uint64(5), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+}
+
+func TestCache_SetMultiple(t *testing.T) {
+	cache := New(10)
+
+	items := make(map[string]interface{}, 100)
+	for i := 0; i < 100; i++ {
+		items[fmt.Sprintf("k%02d", i)] = i
+	}
+	cache.SetMultiple(items)
+	assert.Equal(t, 10, cache.Size())
+	for i := 0; i < 10; i++ {
+		v, ok := cache.kv[fmt.Sprintf("k%02d", i)]
+		assert.True(t, ok)
+		assert.Equal(t, i, v.v)
+	}
+
+	// updates always apply, and only items outside the batch get evicted
+	cache.Get("k09")
+	cache.SetMultiple(map[string]interface{}{"k00": "x", "k01": "y", "a": 1, "b": 2})
+	assert.Equal(t, 10, cache.Size())
+	assert.Equal(t, "x", cache.kv["k00"].v)
+	assert.Equal(t, 2, cache.kv["k00"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, "y", cache.kv["k01"].v)
+	for _, k := range []string{"a", "b", "k09"} {
+		_, ok := cache.kv[k]
+		assert.True(t, ok)
+	}
+
+	small := New(2)
+	small.Set("z", 0)
+	small.Get("z")
+	small.SetMultiple(map[string]interface{}{"z": 1, "c": 2, "a": 3, "b": 4})
+	assert.Equal(t, 2, small.Size())
+	assert.Equal(t, 1, small.kv["z"].v)
+	assert.Equal(t, 3, small.kv["a"].v)
+
+	unbounded := New(0)
+	unbounded.SetMultiple(items)
+	assert.Equal(t, 100, unbounded.Size())
+}
+
+func TestCache_PeekMultiple(t *testing.T) {
+	cache := New(0)
+	cache.SetWithFrequency("a", 1, 5)
+	cache.SetWithFrequency("b", 2, 9)
+	cache.Set("c", 3)
+	before, stats := cache.Snapshot(), cache.Stats()
+
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, cache.PeekMultiple([]string{"a", "b", "x", "a"}))
+	assert.Empty(t, cache.PeekMultiple(nil))
+
+	added, removed, changed := cache.Diff(before)
+	assert.Nil(t, added)
+	assert.Nil(t, removed)
+	assert.Nil(t, changed)
+	assert.Equal(t, stats, cache.Stats())
+}
+
+func TestCache_SetMultipleReport(t *testing.T) {
+	cache := New(3)
+	cache.SetWithFrequency("a", 1, 5)
+	cache.Set("b", 2)
+
+	items := map[string]interface{}{"a": 10, "c": 3, "d": 4, "e": 5}
+	stored, dropped := cache.SetMultipleReport(items)
+	// a updated, b evicted for c and d, no room left for e
+	assert.Equal(t, []string{"a", "c", "d"}, stored)
+	assert.Equal(t, []string{"e"}, dropped)
+	assert.ElementsMatch(t, []string{"a", "c", "d"}, cache.Keys())
+	assert.Equal(t, len(items), len(stored)+len(dropped))
+
+	// pinned items aren't evicted to make room
+	cache = New(2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Pin("a")
+	cache.Pin("b")
+	stored, dropped = cache.SetMultipleReport(map[string]interface{}{"c": 3, "d": 4})
+	assert.Empty(t, stored)
+	assert.Equal(t, []string{"c", "d"}, dropped)
+	assert.Equal(t, 2, cache.Size())
+	cache.Unpin("b")
+	stored, dropped = cache.SetMultipleReport(map[string]interface{}{"c": 3, "d": 4})
+	assert.Equal(t, []string{"c"}, stored)
+	assert.Equal(t, []string{"d"}, dropped)
+	assert.ElementsMatch(t, []string{"a", "c"}, cache.Keys())
+
+	// too long keys are dropped too, and normalized keys reported as given
+	cache = New(0, WithMaxKeyLength(3, false), WithKeyNormalizer(strings.ToLower))
+	stored, dropped = cache.SetMultipleReport(map[string]interface{}{"A": 1, "long": 2})
+	assert.Equal(t, []string{"A"}, stored)
+	assert.Equal(t, []string{"long"}, dropped)
+	v, _ := cache.Peek("a")
+	assert.Equal(t, 1, v)
+}
@@ -0,0 +1,248 @@
+package lfu
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// accessPattern returns n keys drawn from keys distinct ones, uniformly or
+// following a Zipf distribution.
+func accessPattern(n, keys int, zipf bool) []string {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.1, 1, uint64(keys-1))
+
+	pattern := make([]string, n)
+	for i := range pattern {
+		if zipf {
+			pattern[i] = strconv.FormatUint(z.Uint64(), 10)
+		} else {
+			pattern[i] = strconv.Itoa(r.Intn(keys))
+		}
+	}
+	return pattern
+}
+
+func BenchmarkCache_ReadThrough(b *testing.B) {
+	const keys = 100000
+	for _, dist := range []string{"uniform", "zipf"} {
+		pattern := accessPattern(1<<16, keys, dist == "zipf")
+		for _, cap := range []int{100, 10000, 0} {
+			b.Run(dist+"/cap="+strconv.Itoa(cap), func(b *testing.B) {
+				cache := New(cap)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					k := pattern[i%len(pattern)]
+					if _, ok := cache.Get(k); !ok {
+						cache.Set(k, k)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkCache_Set(b *testing.B) {
+	pattern := accessPattern(1<<16, 1<<20, false)
+	for _, cap := range []int{100, 10000} {
+		b.Run("cap="+strconv.Itoa(cap), func(b *testing.B) {
+			cache := New(cap)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Set(pattern[i%len(pattern)], i)
+			}
+		})
+	}
+}
+
+func BenchmarkCache_Evict(b *testing.B) {
+	cache := New(0)
+	for i := 0; i < b.N; i++ {
+		cache.SetWithFrequency(strconv.Itoa(i), i, i%16+1)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Evict(1)
+	}
+}
+
+func BenchmarkCache_Restore(b *testing.B) {
+	for _, n := range []int{1000, 10000} {
+		entries := make([]Entry, n)
+		for i := range entries {
+			entries[i] = Entry{Key: strconv.Itoa(i), Value: i, Freq: i + 1}
+		}
+
+		b.Run("entries="+strconv.Itoa(n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				New(0).Restore(entries)
+			}
+		})
+	}
+}
+
+func BenchmarkCache_TouchBy(b *testing.B) {
+	cache := New(0)
+	for i := 0; i < 1000; i++ {
+		cache.SetWithFrequency(strconv.Itoa(i), i, 2*i+1)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.TouchBy(strconv.Itoa(i%1000), 1)
+	}
+}
+
+func BenchmarkCache_GetParallel(b *testing.B) {
+	const keys = 10000
+	pattern := accessPattern(1<<16, keys, true)
+	for _, mode := range []struct {
+		name string
+		opt  Option
+	}{
+		{"lazy=0", WithLazyPromotions(0)},
+		{"lazy=1024", WithLazyPromotions(1024)},
+		{"reads=64", WithReadBuffer(64)},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			cache := New(0, mode.opt)
+			for i := 0; i < keys; i++ {
+				cache.Set(strconv.Itoa(i), i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Int()
+				for pb.Next() {
+					cache.Get(pattern[i%len(pattern)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkSharded_Parallel runs a mix of nine Gets for every Set from every
+// core, against a single Cache and against Sharded with more shards, which
+// take turns on fewer locks.
+func BenchmarkSharded_Parallel(b *testing.B) {
+	const keys = 10000
+	pattern := accessPattern(1<<16, keys, true)
+	for _, impl := range []struct {
+		name string
+		new  func() LFU
+	}{
+		{"cache", func() LFU { return New(keys / 2) }},
+		{"shards=8", func() LFU { return NewSharded(keys/2, 8) }},
+		{"shards=32", func() LFU { return NewSharded(keys/2, 32) }},
+	} {
+		b.Run(impl.name, func(b *testing.B) {
+			cache := impl.new()
+			for _, k := range pattern[:keys] {
+				cache.Set(k, k)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Int()
+				for pb.Next() {
+					k := pattern[i%len(pattern)]
+					if i%10 == 0 {
+						cache.Set(k, k)
+					} else {
+						cache.Get(k)
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCache_GetBatch looks up 100 keys per op from every core, one Get
+// per key or with a single GetBatch or MGet, which take the lock once but
+// build a map of the values found.
+func BenchmarkCache_GetBatch(b *testing.B) {
+	const keys = 10000
+	cache := New(0)
+	for i := 0; i < keys; i++ {
+		cache.Set(strconv.Itoa(i), i)
+	}
+	pattern := accessPattern(100*1024, keys, true)
+
+	for _, bench := range []struct {
+		name string
+		get  func(keys []string)
+	}{
+		{"Get", func(keys []string) {
+			for _, k := range keys {
+				cache.Get(k)
+			}
+		}},
+		{"GetBatch", func(keys []string) { cache.GetBatch(keys) }},
+		{"MGet", func(keys []string) { cache.MGet(keys) }},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(1024)
+				for pb.Next() {
+					start := i % 1024 * 100
+					bench.get(pattern[start : start+100])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkCache_GetHot reads the keys of a full cache round-robin, so every
+// key keeps leaving a freq node shared with the others for the one above,
+// which is what moving items between freq nodes costs on hot reads.
+func BenchmarkCache_GetHot(b *testing.B) {
+	for _, keys := range []int{100, 10000} {
+		b.Run("keys="+strconv.Itoa(keys), func(b *testing.B) {
+			cache := New(keys)
+			pattern := make([]string, keys)
+			for i := range pattern {
+				pattern[i] = strconv.Itoa(i)
+				cache.Set(pattern[i], i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Get(pattern[i%len(pattern)])
+			}
+		})
+	}
+}
+
+// BenchmarkCache_GetZipf reads through a cache holding a tenth of the keys of
+// a Zipf distribution, so the hottest keys keep climbing alone past every
+// other frequency, and are bumped in their freq node rather than moved to a
+// new one.
+func BenchmarkCache_GetZipf(b *testing.B) {
+	const keys = 10000
+	cache := New(keys / 10)
+	accesses := accessPattern(1<<16, keys, true)
+	for _, k := range accesses {
+		if _, ok := cache.Get(k); !ok {
+			cache.Set(k, k)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		k := accesses[i%len(accesses)]
+		if _, ok := cache.Get(k); !ok {
+			cache.Set(k, k)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package lfu
+
+// recycle hands the value of an evicted item over to the buffer pool, if
+// cache has one and the value is a []byte. Values collected for the caller
+// are still in use and are left alone. The caller must hold the lock.
+func (c *Cache) recycle(item *kvItem) {
+	if c.buffers == nil || c.collecting {
+		return
+	}
+	if b, ok := item.v.([]byte); ok && cap(b) > 0 {
+		b = b[:0]
+		c.buffers.Put(&b)
+	}
+}
+
+// GetBuffer returns a []byte of length n, reusing the buffer of an evicted
+// value when Config.RecycleBuffers is set and one of at least n bytes is
+// available, e.g. to read a value into before storing it. The content of a
+// reused buffer is whatever its previous value held.
+func (c *Cache) GetBuffer(n int) []byte {
+	if c.buffers != nil {
+		if p, ok := c.buffers.Get().(*[]byte); ok && cap(*p) >= n {
+			return (*p)[:n]
+		}
+	}
+	return make([]byte, n)
+}
+
+// PutBuffer gives b back to the buffer pool when Config.RecycleBuffers is
+// set, so GetBuffer can reuse it. b must not be used afterwards.
+func (c *Cache) PutBuffer(b []byte) {
+	if c.buffers == nil || cap(b) == 0 {
+		return
+	}
+	b = b[:0]
+	c.buffers.Put(&b)
+}
@@ -0,0 +1,56 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestCache_RecycleBuffers(t *testing.T) {
+	cache := New(1)
+	b := cache.GetBuffer(4)
+	assert.Equal(t, 4, len(b))
+	cache.PutBuffer(b)
+
+	cache = New(1, WithRecycleBuffers())
+	recycled := false
+	// sync.Pool may drop what it is given, so try a few times
+	for i := 0; i < 100 && !recycled; i++ {
+		buf := cache.GetBuffer(8)
+		cache.Set(strconv.Itoa(i), buf)
+		cache.Set("other", 1)
+
+		got := cache.GetBuffer(4)
+		assert.Equal(t, 4, len(got))
+		recycled = cap(got) == cap(buf) && &got[0] == &buf[0]
+	}
+	assert.True(t, recycled)
+
+	// too small to reuse
+	cache.PutBuffer(make([]byte, 2))
+	assert.Equal(t, 16, len(cache.GetBuffer(16)))
+}
+
+func BenchmarkCache_RecycleBuffers(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts []Option
+	}{
+		{"off", nil},
+		{"on", []Option{WithRecycleBuffers()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			cache := New(100, bc.opts...)
+			keys := make([]string, 1000)
+			for i := range keys {
+				keys[i] = strconv.Itoa(i)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Set(keys[i%len(keys)], cache.GetBuffer(4096))
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package lfu
+
+// SetIfAbsent stores the kv pair only if k isn't in cache, and reports
+// whether it stored it. Unlike GetOrSet, finding k does nothing at all: it
+// neither counts as an access nor returns the value.
+func (c *Cache) SetIfAbsent(k string, v interface{}) (stored bool) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	if _, ok := c.lookup(k); ok || c.rejects(k) {
+		return false
+	}
+	c.set(k, v)
+	return true
+}
+
+// CompareAndSwap stores new for k only if k is in cache with a value equal to
+// old, as compared by the configured ValueEqual, and reports whether it did.
+// A swap counts as an access of k, like Set.
+func (c *Cache) CompareAndSwap(k string, old, new interface{}) (swapped bool) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	item, ok := c.lookup(k)
+	if !ok || !c.equal(item.v, old) {
+		return false
+	}
+	c.set(k, new)
+	return true
+}
+
+// Update calls fn with the value of k, or with exists false if k isn't in
+// cache, and stores the value fn returns if it also returns true, all under
+// the lock, so concurrent read-modify-writes of k don't lose each other's
+// writes. It reports whether a value was stored, which counts as an access
+// of k like Set; reading the value doesn't. fn must not call cache.
+func (c *Cache) Update(k string, fn func(old interface{}, exists bool) (new interface{}, store bool)) (stored bool) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	var old interface{}
+	item, exists := c.lookup(k)
+	if exists {
+		old = c.clone(item.v)
+	}
+	v, store := fn(old, exists)
+	if !store || !exists && c.rejects(k) {
+		return false
+	}
+	c.set(k, v)
+	return true
+}
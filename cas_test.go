@@ -0,0 +1,73 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestCache_SetIfAbsent(t *testing.T) {
+	cache := New(0, WithMaxKeyLength(3, false))
+	assert.True(t, cache.SetIfAbsent("a", 1))
+	assert.False(t, cache.SetIfAbsent("a", 2))
+	assert.False(t, cache.SetIfAbsent("long", 3))
+
+	v, _ := cache.Peek("a")
+	assert.Equal(t, 1, v)
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 1, freq)
+	assert.Equal(t, CacheStats{Size: 1}, cache.Stats())
+}
+
+func TestCache_CompareAndSwap(t *testing.T) {
+	cache := New(0)
+	assert.False(t, cache.CompareAndSwap("a", nil, 1))
+
+	cache.Set("a", 1)
+	assert.False(t, cache.CompareAndSwap("a", 2, 3))
+	assert.False(t, cache.CompareAndSwap("a", "1", 3))
+	assert.True(t, cache.CompareAndSwap("a", 1, 3))
+
+	v, _ := cache.Peek("a")
+	assert.Equal(t, 3, v)
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 2, freq)
+}
+
+func TestCache_Update(t *testing.T) {
+	cache := New(0)
+	incr := func(old interface{}, exists bool) (interface{}, bool) {
+		if !exists {
+			return 1, true
+		}
+		return old.(int) + 1, true
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Update("n", incr)
+			}
+		}()
+	}
+	wg.Wait()
+	v, _ := cache.Peek("n")
+	assert.Equal(t, 800, v)
+
+	assert.False(t, cache.Update("n", func(old interface{}, exists bool) (interface{}, bool) {
+		assert.True(t, exists)
+		return nil, false
+	}))
+	v, _ = cache.Peek("n")
+	assert.Equal(t, 800, v)
+
+	assert.False(t, cache.Update("m", func(old interface{}, exists bool) (interface{}, bool) {
+		assert.False(t, exists)
+		assert.Nil(t, old)
+		return nil, false
+	}))
+	assert.Equal(t, 1, cache.Size())
+}
@@ -0,0 +1,108 @@
+package lfu
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time of a cache: Now for TTLs, freshness and every
+// other age, and After for what runs periodically, like the expiry sweep.
+// Tests may set a ManualClock, see WithClock, to advance time without
+// sleeping.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// realClock is the Clock of time.Now and time.After.
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// ManualClock is a Clock whose time only changes when Advance or Set moves
+// it. It is safe for concurrent use.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []clockWaiter
+}
+
+type clockWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+var _ Clock = (*ManualClock)(nil)
+
+// NewManualClock returns a ManualClock set to now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns the time the clock was last set to.
+func (m *ManualClock) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.now
+}
+
+// After returns a channel that receives the time of the clock once it has
+// been moved d forward. A non-positive d fires right away.
+func (m *ManualClock) After(d time.Duration) <-chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- m.now
+		return ch
+	}
+	m.waiters = append(m.waiters, clockWaiter{at: m.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock d forward, firing the channels of After that are
+// due, earliest first.
+func (m *ManualClock) Advance(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(m.now.Add(d))
+}
+
+// Set moves the clock to now, firing the channels of After that are due,
+// earliest first. Moving it back fires nothing.
+func (m *ManualClock) Set(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(now)
+}
+
+// Waiters returns the number of channels of After still to fire, e.g. for a
+// test to wait until a goroutine is waiting on the clock before advancing it.
+func (m *ManualClock) Waiters() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.waiters)
+}
+
+func (m *ManualClock) set(now time.Time) {
+	m.now = now
+	sort.SliceStable(m.waiters, func(i, j int) bool {
+		return m.waiters[i].at.Before(m.waiters[j].at)
+	})
+	n := 0
+	for _, w := range m.waiters {
+		if w.at.After(now) {
+			break
+		}
+		w.ch <- now
+		n++
+	}
+	m.waiters = append(m.waiters[:0], m.waiters[n:]...)
+}
@@ -0,0 +1,44 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	assert.Equal(t, time.Unix(0, 0), clock.Now())
+
+	now := <-clock.After(0)
+	assert.Equal(t, time.Unix(0, 0), now)
+
+	late := clock.After(2 * time.Second)
+	early := clock.After(time.Second)
+	assert.Equal(t, 2, clock.Waiters())
+
+	clock.Advance(time.Second - time.Nanosecond)
+	assert.Empty(t, early)
+	clock.Advance(time.Nanosecond)
+	assert.Equal(t, time.Unix(1, 0), <-early)
+	assert.Empty(t, late)
+	assert.Equal(t, 1, clock.Waiters())
+
+	// moving back fires nothing
+	clock.Set(time.Unix(0, 0))
+	assert.Empty(t, late)
+	clock.Set(time.Unix(5, 0))
+	assert.Equal(t, time.Unix(5, 0), <-late)
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestNew_WithClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	assert.Equal(t, time.Unix(0, 0), cache.now())
+
+	cache.SetWithTTL("a", 1, time.Minute)
+	clock.Advance(time.Minute)
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+}
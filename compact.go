@@ -0,0 +1,28 @@
+package lfu
+
+// compactRatio is how far len(kv) must fall below its peak before Compact
+// rebuilds the map.
+const compactRatio = 4
+
+// Compact rebuilds the map holding the items when it has shrunk to less than
+// a quarter of its peak size, so memory held by the grown map can be
+// reclaimed. Go maps never shrink, so a cache that once held many more items
+// than it does now keeps paying for them; Compact is worth calling after
+// removing or evicting most of the items, and is a no-op otherwise.
+//
+// Items keep their values and frequencies.
+func (c *Cache) Compact() {
+	c.lock()
+	defer c.unlock()
+
+	if len(c.kv)*compactRatio >= c.kvPeak {
+		return
+	}
+
+	kv := make(map[string]*kvItem, len(c.kv))
+	for k, item := range c.kv {
+		kv[k] = item
+	}
+	c.kv = kv
+	c.kvPeak = len(kv)
+}
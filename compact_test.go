@@ -0,0 +1,36 @@
+package lfu
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_Compact(t *testing.T) {
+	cache := New(1000)
+	for i := 0; i < 1000; i++ {
+		cache.Set(fmt.Sprintf("k%d", i), i)
+	}
+	for i := 0; i < 10; i++ {
+		cache.Get(fmt.Sprintf("k%d", i))
+	}
+
+	// not sparse enough yet
+	cache.Evict(500)
+	cache.Compact()
+	assert.Equal(t, 1000, cache.kvPeak)
+
+	cache.Evict(480)
+	cache.Compact()
+	assert.Equal(t, 20, cache.kvPeak)
+	assert.Equal(t, 20, cache.Size())
+	assert.NoError(t, cache.checkInvariants())
+
+	for i := 0; i < 10; i++ {
+		k := fmt.Sprintf("k%d", i)
+		v, ok := cache.Get(k)
+		assert.True(t, ok, k)
+		assert.Equal(t, i, v)
+		assert.Equal(t, 3, cache.kv[k].parent.Value.(*freqNode).freq)
+	}
+}
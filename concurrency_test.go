@@ -0,0 +1,317 @@
+package lfu
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// cacheOp is one public method of Cache, called with arguments derived from
+// i so concurrent callers touch overlapping keys.
+type cacheOp struct {
+	name string
+	fn   func(c *Cache, i int)
+}
+
+func cacheOps() []cacheOp {
+	key := func(i int) string { return "k" + strconv.Itoa(i%8) }
+	load := func(k string) (interface{}, error) { return len(k), nil }
+
+	return []cacheOp{
+		{"Set", func(c *Cache, i int) { c.Set(key(i), i) }},
+		{"SetReport", func(c *Cache, i int) { c.SetReport(key(i), i) }},
+		{"TrySet", func(c *Cache, i int) { c.TrySet(key(i), i) }},
+		{"SetWithTTL", func(c *Cache, i int) { c.SetWithTTL(key(i), i, time.Duration(i%3)*time.Millisecond) }},
+		{"SetWithMeta", func(c *Cache, i int) { c.SetWithMeta(key(i), i, map[string]string{"i": key(i)}) }},
+		{"StoreMiss", func(c *Cache, i int) { c.StoreMiss(key(i), time.Millisecond) }},
+		{"SetWithTags", func(c *Cache, i int) { c.SetWithTags(key(i), i, key(i%2), key(i%3)) }},
+		{"GetMeta", func(c *Cache, i int) { c.GetMeta(key(i)) }},
+		{"SetWithFrequency", func(c *Cache, i int) { c.SetWithFrequency(key(i), i, i%5) }},
+		{"SetMultiple", func(c *Cache, i int) {
+			c.SetMultiple(map[string]interface{}{key(i): i, key(i + 1): i, key(i + 2): i})
+		}},
+		{"SetMultipleReport", func(c *Cache, i int) { c.SetMultipleReport(map[string]interface{}{key(i): i, key(i + 4): i}) }},
+		{"SetManyWithTTL", func(c *Cache, i int) {
+			c.SetManyWithTTL([]EntryWithTTL{{Key: key(i), Value: i, TTL: time.Millisecond}, {Key: key(i + 3), Value: i}})
+		}},
+		{"SetIfAbsent", func(c *Cache, i int) { c.SetIfAbsent(key(i), i) }},
+		{"CompareAndSwap", func(c *Cache, i int) { c.CompareAndSwap(key(i), i-1, i) }},
+		{"Update", func(c *Cache, i int) {
+			c.Update(key(i), func(old interface{}, exists bool) (interface{}, bool) { return i, !exists || i%2 == 0 })
+		}},
+		{"Get", func(c *Cache, i int) { c.Get(key(i)) }},
+		{"GetOrDefault", func(c *Cache, i int) { c.GetOrDefault(key(i), 0) }},
+		{"GetOrSet", func(c *Cache, i int) { c.GetOrSet(key(i), i) }},
+		{"GetOrSetReport", func(c *Cache, i int) { c.GetOrSetReport(key(i), i) }},
+		{"GetWithFreshness", func(c *Cache, i int) { c.GetWithFreshness(key(i)) }},
+		{"GetWithAge", func(c *Cache, i int) { c.GetWithAge(key(i)) }},
+		{"GetStale", func(c *Cache, i int) { c.GetStale(key(i)) }},
+		{"GetCopy", func(c *Cache, i int) { c.GetCopy(key(i), func(v interface{}) interface{} { return v }) }},
+		{"GetBatch", func(c *Cache, i int) { c.GetBatch([]string{key(i), key(i + 1), key(i)}) }},
+		{"PeekMultiple", func(c *Cache, i int) { c.PeekMultiple([]string{key(i), key(i + 1)}) }},
+		{"GetOrLoad", func(c *Cache, i int) { c.GetOrLoad(key(i), load) }},
+		{"Ctx", func(c *Cache, i int) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+			defer cancel()
+			c.GetCtx(ctx, key(i))
+			c.SetCtx(ctx, key(i), i)
+			c.GetOrLoadCtx(ctx, key(i+1), func(ctx context.Context, k string) (interface{}, error) { return load(k) })
+		}},
+		{"GetWithRefresh", func(c *Cache, i int) { c.GetWithRefresh(key(i), load) }},
+		{"SetRefreshAfter", func(c *Cache, i int) { c.SetRefreshAfter(key(i), time.Duration(i%2)) }},
+		{"Evict", func(c *Cache, i int) { c.Evict(i % 3) }},
+		{"EvictWithResult", func(c *Cache, i int) { c.EvictWithResult(i % 3) }},
+		{"Remove", func(c *Cache, i int) { c.Remove(key(i)) }},
+		{"Purge", func(c *Cache, i int) { c.Purge() }},
+		{"RemoveByPrefix", func(c *Cache, i int) { c.RemoveByPrefix(key(i)) }},
+		{"InvalidateTag", func(c *Cache, i int) { c.InvalidateTag(key(i % 2)) }},
+		{"Resize", func(c *Cache, i int) { c.Resize(i%6 + 2) }},
+		{"Reset", func(c *Cache, i int) { c.Reset(i%6 + 2) }},
+		{"Restore", func(c *Cache, i int) { c.Restore([]Entry{{Key: key(i), Value: i, Freq: i % 7}}) }},
+		{"ResetFrequencies", func(c *Cache, i int) { c.ResetFrequencies() }},
+		{"DecayFrequencies", func(c *Cache, i int) { c.DecayFrequencies() }},
+		{"SetFrequency", func(c *Cache, i int) { c.SetFrequency(key(i), i%9) }},
+		{"TouchBy", func(c *Cache, i int) { c.TouchBy(key(i), i%4) }},
+		{"Touch", func(c *Cache, i int) { c.Touch(key(i)) }},
+		{"Pin", func(c *Cache, i int) { c.Pin(key(i)) }},
+		{"Unpin", func(c *Cache, i int) { c.Unpin(key(i)) }},
+		{"Freeze", func(c *Cache, i int) { c.Freeze(); c.Unfreeze() }},
+		{"Batch", func(c *Cache, i int) {
+			c.Batch(func(tx *Txn) {
+				tx.Set(key(i), i)
+				tx.Get(key(i + 1))
+				tx.Remove(key(i + 2))
+			})
+		}},
+		{"TrimToMemory", func(c *Cache, i int) { c.TrimToMemory(int64(i % 4)) }},
+		{"SetWithCost", func(c *Cache, i int) { c.SetWithCost(key(i), i, int64(i%3)) }},
+		{"EvictUnderPressure", func(c *Cache, i int) {
+			c.SetPressureFunc(func() int { return i % 3 })
+			c.EvictUnderPressure()
+		}},
+		{"Compact", func(c *Cache, i int) { c.Compact() }},
+		{"EvictLeastFrequent", func(c *Cache, i int) { c.EvictLeastFrequent() }},
+		{"RemoveExpired", func(c *Cache, i int) { c.RemoveExpired() }},
+		{"Flush", func(c *Cache, i int) { c.Flush() }},
+		{"Clone", func(c *Cache, i int) { c.Clone().Get(key(i)) }},
+		{"WithKeyLock", func(c *Cache, i int) { c.WithKeyLock(key(i), func() { c.Get(key(i)) }) }},
+		{"SetDirty", func(c *Cache, i int) { c.SetDirty(key(i), i) }},
+		{"FlushDirty", func(c *Cache, i int) {
+			c.FlushDirty(func(k string, v interface{}) error { c.Get(k); return nil })
+		}},
+		{"WaitForSpace", func(c *Cache, i int) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Microsecond)
+			c.WaitForSpace(ctx)
+			cancel()
+		}},
+		{"WaitForKeys", func(c *Cache, i int) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if i%2 == 0 {
+				cancel()
+			}
+			go cancel()
+			c.WaitForKeys(ctx, []string{key(i), key(i + 1)})
+		}},
+		{"DrainTo", func(c *Cache, i int) {
+			ch := make(chan Entry)
+			go func() {
+				for range ch {
+				}
+			}()
+			c.DrainTo(ch)
+			close(ch)
+		}},
+		{"OnPromote", func(c *Cache, i int) {
+			if i == 0 {
+				c.OnPromote(3, func(k string, v interface{}) { c.Get(k) })
+			}
+		}},
+		{"OnReach", func(c *Cache, i int) { c.OnReach(key(i), i%4+1, func() { c.Size() }) }},
+		{"Watch", func(c *Cache, i int) {
+			if i%2 == 0 {
+				c.Watch(key(i), func(v interface{}, hit bool) { c.Size() })
+			} else {
+				c.Unwatch(key(i))
+			}
+		}},
+		{"SetTargetHitRatio", func(c *Cache, i int) { c.SetTargetHitRatio(0.5, 2, 10) }},
+		{"WriteToReadFrom", func(c *Cache, i int) {
+			var buf bytes.Buffer
+			c.WriteTo(&buf)
+			c.ReadFrom(&buf)
+		}},
+		{"Reads", func(c *Cache, i int) {
+			c.Size()
+			c.Bytes()
+			c.EstimatedBytes()
+			c.Keys()
+			c.Snapshot()
+			c.SnapshotTopN(2)
+			c.ColdestN(2)
+			c.Partition(2)
+			c.ForEach(func(k string, v interface{}) {})
+			c.Range(func(k string, v interface{}) bool { return true })
+			c.GetFrequencyRank(key(i))
+			c.GetFrequency(key(i))
+			c.FreqListLength()
+			c.CurrentHitRatio()
+			c.RecentHitRatio()
+			c.ColdMisses()
+			c.HottestMissedKeys(2)
+			c.LastEvictedFrequency()
+			c.EvictedFrequencyHistogram()
+			c.FrequencyHistogram()
+			c.Stats()
+			c.DroppedAccesses()
+			c.ObservabilityBytes()
+			c.PutBuffer(c.GetBuffer(4))
+		}},
+	}
+}
+
+func newConcurrencyCache() *Cache {
+	codec := WithValueCodec(func(v interface{}) ([]byte, error) {
+		return []byte(strconv.Itoa(v.(int))), nil
+	}, func(data []byte) (interface{}, error) {
+		return strconv.Atoi(string(data))
+	})
+
+	cfg := Config{Capacity: 5}
+	for _, opt := range []Option{
+		codec,
+		WithTrackMisses(4),
+		WithMetrics(&InMemoryMetrics{}),
+		WithCloneFunc(func(v interface{}) interface{} { return v }),
+		WithMaxConcurrentLoads(2),
+		WithAccessRecorder(func(e AccessEvent) {}, 4),
+		WithTTLJitter(0.5),
+		WithHitRatioWindow(16),
+		WithRecycleBuffers(),
+		WithLazyPromotions(3),
+		WithFrequencyWindow(time.Millisecond, 4),
+		WithEvictionComparator(func(a, b Entry) bool { return a.Key < b.Key }),
+		WithEvictionCallback(func(k string, v interface{}, reason EvictReason) {}),
+		WithAdmissionWindow(2),
+		WithFrequencyDecay(time.Millisecond),
+		WithDefaultTTL(time.Millisecond),
+		WithProtectedRatio(0.5),
+		WithRefreshAfter(time.Nanosecond, 2),
+		WithTrackAccessTime(),
+		WithReadBuffer(2),
+	} {
+		opt(&cfg)
+	}
+	cfg.Sizer = func(v interface{}) int64 { return 1 }
+	cfg.MaxBytes = 4
+	return NewWithConfig(cfg)
+}
+
+// TestCache_ConcurrencyMatrix runs every pair of public methods concurrently,
+// which is meant to be run with -race, and checks the invariants afterwards.
+func TestCache_ConcurrencyMatrix(t *testing.T) {
+	iterations := 200
+	if testing.Short() {
+		iterations = 5
+	}
+
+	ops := cacheOps()
+	for i, a := range ops {
+		for _, b := range ops[i:] {
+			a, b := a, b
+			t.Run(a.name+"/"+b.name, func(t *testing.T) {
+				c := newConcurrencyCache()
+				for j := 0; j < 8; j++ {
+					c.Set("k"+strconv.Itoa(j), j)
+				}
+
+				var wg sync.WaitGroup
+				for _, op := range []cacheOp{a, b} {
+					wg.Add(1)
+					go func(op cacheOp) {
+						defer wg.Done()
+						for j := 0; j < iterations; j++ {
+							op.fn(c, j)
+						}
+					}(op)
+				}
+				wg.Wait()
+
+				if err := c.Verify(); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+// TestLFU_ConcurrencyMatrix runs the LFU methods of the composed caches
+// concurrently with each other.
+func TestLFU_ConcurrencyMatrix(t *testing.T) {
+	key := func(i int) string { return "k" + strconv.Itoa(i%16) }
+	ops := []struct {
+		name string
+		fn   func(l LFU, i int)
+	}{
+		{"Set", func(l LFU, i int) { l.Set(key(i), i) }},
+		{"Get", func(l LFU, i int) { l.Get(key(i)) }},
+		{"Evict", func(l LFU, i int) { l.Evict(i % 3) }},
+		{"Size", func(l LFU, i int) { l.Size() }},
+		{"Remove", func(l LFU, i int) { l.Remove(key(i)) }},
+		{"Purge", func(l LFU, i int) {
+			if i%50 == 0 {
+				l.Purge()
+			}
+		}},
+		{"Reshard", func(l LFU, i int) {
+			if s, ok := l.(*ConsistentSharded); ok {
+				s.Reshard(i%4 + 1)
+			}
+		}},
+		{"Resize", func(l LFU, i int) {
+			if r, ok := l.(interface{ Resize(cap int) }); ok {
+				r.Resize(i%8 + 2)
+			}
+		}},
+	}
+
+	for _, impl := range []struct {
+		name string
+		new  func() LFU
+	}{
+		{"tiered", func() LFU { return NewTiered(New(2), New(4)) }},
+		{"consistent", func() LFU { return NewConsistentSharded(6, 3) }},
+		{"sharded", func() LFU { return NewSharded(6, 3) }},
+		{"namespaced", func() LFU { return NewNamespaced(6).Namespace("a") }},
+		{"quota", func() LFU {
+			n := NewNamespaced(6)
+			n.SetQuota("a", 3)
+			n.Namespace("b").Set("x", 1)
+			return n.Namespace("a")
+		}},
+		{"lru", func() LFU { return NewLRU(6) }},
+	} {
+		for i, a := range ops {
+			for _, b := range ops[i:] {
+				a, b := a, b
+				t.Run(impl.name+"/"+a.name+"/"+b.name, func(t *testing.T) {
+					l := impl.new()
+					var wg sync.WaitGroup
+					for _, fn := range []func(l LFU, i int){a.fn, b.fn} {
+						wg.Add(1)
+						go func(fn func(l LFU, i int)) {
+							defer wg.Done()
+							for j := 0; j < 200; j++ {
+								fn(l, j)
+							}
+						}(fn)
+					}
+					wg.Wait()
+					l.Size()
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package lfu
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ringReplicas is the number of points each shard owns on the hash ring. More
+// points spread keys more evenly across shards, at the cost of a larger ring.
+const ringReplicas = 128
+
+// ConsistentSharded is an LFU split into shards, each a *Cache of its own
+// with an equal part of the capacity, so callers of different shards don't
+// contend for the same lock. Keys are routed to shards by consistent hashing:
+// each shard owns points on a hash ring, and a key belongs to the shard
+// owning the first point at or after the hash of the key.
+//
+// Compared to routing by hash modulo the number of shards, a lookup costs a
+// binary search of the ring instead of a division, and keys spread a little
+// less evenly. In exchange, Reshard from n to m shards only moves about
+// |m-n|/max(m,n) of the keys, where modulo would move almost all of them.
+//
+// Frequencies are per shard, so Evict, which evicts the least frequently
+// used item of the shards, is only as exact as their frequencies compare.
+type ConsistentSharded struct {
+	mu     sync.RWMutex
+	cap    int
+	shards []*Cache
+	ring   []ringPoint
+}
+
+type ringPoint struct {
+	hash  uint32
+	shard int
+}
+
+var _ LFU = (*ConsistentSharded)(nil)
+
+// NewConsistentSharded creates an LFU of the given total capacity split into
+// the given number of shards, at least one. A non-positive cap means the
+// shards won't do any eviction.
+func NewConsistentSharded(cap int, shards int) *ConsistentSharded {
+	if shards < 1 {
+		shards = 1
+	}
+
+	s := &ConsistentSharded{cap: cap}
+	for i := 0; i < shards; i++ {
+		s.shards = append(s.shards, New(shardCap(s.cap, shards)))
+	}
+	s.ring = newRing(shards)
+	return s
+}
+
+// Set stores the given kv pair in the shard of k.
+func (s *ConsistentSharded) Set(k string, v interface{}) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.shards[s.shardOf(k)].Set(k, v)
+}
+
+// Get returns the v related to k from the shard of k.
+func (s *ConsistentSharded) Get(k string) (v interface{}, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.shards[s.shardOf(k)].Get(k)
+}
+
+// Evict evicts up to n items, each time from the shard whose least frequently
+// used item has the lowest frequency.
+func (s *ConsistentSharded) Evict(n int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	evictColdest(s.shards, n)
+}
+
+// Size returns the number of items in all shards.
+func (s *ConsistentSharded) Size() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	size := 0
+	for _, shard := range s.shards {
+		size += shard.Size()
+	}
+	return size
+}
+
+// Remove deletes k from its shard.
+func (s *ConsistentSharded) Remove(k string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.shards[s.shardOf(k)].Remove(k)
+}
+
+// Purge deletes every item of every shard.
+func (s *ConsistentSharded) Purge() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, shard := range s.shards {
+		shard.Purge()
+	}
+}
+
+// ShardStats returns the Stats of every shard, e.g. to spot a shard taking
+// more than its share of the keys or the lookups.
+func (s *ConsistentSharded) ShardStats() []CacheStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := make([]CacheStats, len(s.shards))
+	for i, shard := range s.shards {
+		stats[i] = shard.Stats()
+	}
+	return stats
+}
+
+// ShardSizes returns the number of items in every shard.
+func (s *ConsistentSharded) ShardSizes() []int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sizes := make([]int, len(s.shards))
+	for i, shard := range s.shards {
+		sizes[i] = shard.Size()
+	}
+	return sizes
+}
+
+// Resize changes the total capacity to cap, shared out equally again, each
+// shard evicting its least frequently used items if it holds more than its
+// part. A non-positive cap means the shards won't do any eviction.
+func (s *ConsistentSharded) Resize(cap int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cap = cap
+	for _, shard := range s.shards {
+		shard.Resize(shardCap(cap, len(s.shards)))
+	}
+}
+
+// Reshard changes the number of shards to n, at least one, moving the keys
+// whose shard changed along with their frequencies and sharing the capacity
+// out again. Shards that stay keep the keys still routed to them, so only
+// about |n-old|/max(n,old) of the keys move. It blocks every other call
+// while it runs.
+func (s *ConsistentSharded) Reshard(n int) {
+	if n < 1 {
+		n = 1
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old := s.shards
+	for len(s.shards) < n {
+		s.shards = append(s.shards, New(0))
+	}
+	s.shards = s.shards[:n]
+	s.ring = newRing(n)
+
+	// let the shards hold everything while keys move, and only then evict
+	// what doesn't fit their new capacity
+	for _, shard := range old {
+		shard.Resize(0)
+	}
+	for i, shard := range old {
+		for _, e := range shard.Snapshot() {
+			if to := s.shardOf(e.Key); to != i {
+				shard.Remove(e.Key)
+				s.shards[to].SetWithFrequency(e.Key, e.Value, e.Freq)
+			}
+		}
+	}
+	for _, shard := range s.shards {
+		shard.Resize(shardCap(s.cap, n))
+	}
+}
+
+// shardOf returns the index of the shard k is routed to. The caller must hold
+// s.mu.
+func (s *ConsistentSharded) shardOf(k string) int {
+	h := ringHash(k)
+	i := sort.Search(len(s.ring), func(i int) bool {
+		return s.ring[i].hash >= h
+	})
+	if i == len(s.ring) {
+		i = 0
+	}
+	return s.ring[i].shard
+}
+
+// newRing returns the points of n shards, sorted by hash. The points of a
+// shard only depend on its index, which is what keeps keys in place when
+// shards are added or removed.
+func newRing(n int) []ringPoint {
+	ring := make([]ringPoint, 0, n*ringReplicas)
+	for shard := 0; shard < n; shard++ {
+		for r := 0; r < ringReplicas; r++ {
+			h := ringHash(strconv.Itoa(shard) + "#" + strconv.Itoa(r))
+			ring = append(ring, ringPoint{hash: h, shard: shard})
+		}
+	}
+	sort.Slice(ring, func(i, j int) bool {
+		return ring[i].hash < ring[j].hash
+	})
+	return ring
+}
+
+func ringHash(k string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	return h.Sum32()
+}
@@ -0,0 +1,150 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestConsistentSharded(t *testing.T) {
+	s := NewConsistentSharded(8, 4)
+	assert.Equal(t, 4, len(s.shards))
+	assert.Equal(t, 2, s.shards[0].cap)
+
+	s.Set("a", 1)
+	v, ok := s.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 1, s.shards[s.shardOf("a")].Size())
+
+	s.Set("b", 2)
+	s.Get("a")
+	s.Evict(1)
+	assert.Equal(t, 1, s.Size())
+	_, ok = s.Get("a")
+	assert.True(t, ok)
+}
+
+func TestConsistentSharded_Reshard(t *testing.T) {
+	const keys = 10000
+	s := NewConsistentSharded(0, 4)
+	for i := 0; i < keys; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	s.Get("0")
+
+	owners := make([]int, keys)
+	for i := range owners {
+		owners[i] = s.shardOf(strconv.Itoa(i))
+	}
+
+	s.Reshard(5)
+	moved := 0
+	for i := range owners {
+		if s.shardOf(strconv.Itoa(i)) != owners[i] {
+			moved++
+		}
+	}
+	// ideally 1/5 of the keys move, where modulo sharding would move 4/5
+	assert.True(t, moved > keys/10 && moved < keys*3/10, "moved %d", moved)
+
+	assert.Equal(t, keys, s.Size())
+	for i := 0; i < keys; i++ {
+		k := strconv.Itoa(i)
+		if _, ok := s.shards[s.shardOf(k)].kv[k]; !ok {
+			t.Fatalf("%s is not in its shard", k)
+		}
+	}
+	// frequencies move along with the keys
+	assert.Equal(t, 2, s.shards[s.shardOf("0")].kv["0"].parent.Value.(*freqNode).freq)
+
+	// shrinking sends the keys of the dropped shards to the others
+	s.Reshard(2)
+	assert.Equal(t, 2, len(s.shards))
+	assert.Equal(t, keys, s.Size())
+	for _, shard := range s.shards {
+		assert.NoError(t, shard.checkInvariants())
+	}
+}
+
+func TestConsistentSharded_ReshardCapacity(t *testing.T) {
+	s := NewConsistentSharded(10, 2)
+	for i := 0; i < 10; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	s.Reshard(5)
+	for _, shard := range s.shards {
+		assert.Equal(t, 2, shard.cap)
+		assert.True(t, shard.Size() <= 2)
+	}
+}
+
+func TestConsistentSharded_ShardStats(t *testing.T) {
+	s := NewConsistentSharded(0, 4)
+
+	// keys that all land on shard 0
+	var skewed []string
+	for i := 0; len(skewed) < 20; i++ {
+		if k := strconv.Itoa(i); s.shardOf(k) == 0 {
+			skewed = append(skewed, k)
+		}
+	}
+	for _, k := range skewed {
+		s.Set(k, k)
+		s.Get(k)
+	}
+	s.Get("missing-" + skewed[0])
+
+	assert.Equal(t, []int{20, 0, 0, 0}, s.ShardSizes())
+	stats := s.ShardStats()
+	assert.Equal(t, 4, len(stats))
+	assert.Equal(t, CacheStats{Hits: 20, Size: 20}, stats[0])
+	misses := uint64(0)
+	for _, st := range stats[1:] {
+		assert.Equal(t, uint64(0), st.Hits)
+		assert.Equal(t, 0, st.Size)
+		misses += st.Misses
+	}
+	assert.Equal(t, uint64(1), misses+stats[0].Misses)
+}
+
+func TestConsistentSharded_RemovePurge(t *testing.T) {
+	s := NewConsistentSharded(0, 4)
+	for i := 0; i < 20; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+
+	assert.True(t, s.Remove("3"))
+	assert.False(t, s.Remove("3"))
+	_, ok := s.Get("3")
+	assert.False(t, ok)
+	assert.Equal(t, 19, s.Size())
+
+	s.Purge()
+	assert.Equal(t, []int{0, 0, 0, 0}, s.ShardSizes())
+}
+
+func TestConsistentSharded_Resize(t *testing.T) {
+	s := NewConsistentSharded(0, 3)
+	for i := 0; i < 30; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+
+	s.Resize(6)
+	for _, size := range s.ShardSizes() {
+		assert.True(t, size <= 2)
+	}
+
+	// the new capacity holds across a Reshard
+	s.Reshard(2)
+	for i := 0; i < 30; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, []int{3, 3}, s.ShardSizes())
+
+	s.Resize(-1)
+	for i := 0; i < 30; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 30, s.Size())
+}
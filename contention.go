@@ -0,0 +1,25 @@
+package lfu
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// lock locks the cache, counting the time it waited for the lock in Stats and
+// reporting it to the configured LockWaitObserver, if any. unlock releases
+// it.
+func (c *Cache) lock() {
+	// an uncontended lock costs no clock reads
+	if c.mu.TryLock() {
+		return
+	}
+	start := time.Now()
+	c.mu.Lock()
+	wait := time.Since(start)
+
+	atomic.AddUint64(&c.lockWaits, 1)
+	atomic.AddInt64(&c.lockWaited, int64(wait))
+	if c.lockWait != nil {
+		c.lockWait(wait)
+	}
+}
@@ -0,0 +1,37 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_LockWaitObserver(t *testing.T) {
+	var waits []time.Duration
+	cache := New(0, WithLockWaitObserver(func(wait time.Duration) {
+		waits = append(waits, wait)
+	}))
+
+	// uncontended
+	cache.Set("a", 1)
+	cache.Get("a")
+	assert.Empty(t, waits)
+
+	// a slow holder
+	held := make(chan struct{})
+	go func() {
+		cache.lock()
+		close(held)
+		time.Sleep(20 * time.Millisecond)
+		cache.unlock()
+	}()
+	<-held
+	cache.Get("a")
+
+	assert.Equal(t, 1, len(waits))
+	assert.True(t, waits[0] >= 10*time.Millisecond, waits[0])
+
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.LockWaits)
+	assert.Equal(t, waits[0], stats.LockWaited)
+}
@@ -0,0 +1,46 @@
+package lfu
+
+import "context"
+
+// GetCtx works like Get, unless ctx is done already, in which case it
+// returns ctx.Err() without looking k up.
+func (c *Cache) GetCtx(ctx context.Context, k string) (v interface{}, ok bool, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	v, ok = c.Get(k)
+	return
+}
+
+// SetCtx works like Set, and stops waiting for a frozen cache to be unfrozen
+// when ctx is done, returning ctx.Err() without storing the kv pair.
+func (c *Cache) SetCtx(ctx context.Context, k string, v interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	c.lock()
+	defer c.unlock()
+	if err := c.waitWritableCtx(ctx); err != nil {
+		return err
+	}
+
+	c.set(c.key(k), v)
+	return nil
+}
+
+// GetOrLoadCtx works like GetOrLoad, with ctx passed on to loader, e.g. to
+// bound the load with a deadline or carry a tracing span into it. Waiting
+// for another call loading k, or for a slot of MaxConcurrentLoads, ends
+// when ctx is done, returning ctx.Err(). The call running loader is the
+// first one missing k, so the others share the outcome of its ctx: if it
+// was canceled, they get what loader returns for that.
+func (c *Cache) GetOrLoadCtx(ctx context.Context, k string, loader func(ctx context.Context, k string) (interface{}, error)) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if v, ok := c.Get(k); ok {
+		return loaded(v)
+	}
+	return c.loadOnce(ctx, k, loader)
+}
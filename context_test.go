@@ -0,0 +1,113 @@
+package lfu
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_GetCtx(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+
+	v, ok, err := cache.GetCtx(context.Background(), "a")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, ok, err = cache.GetCtx(ctx, "a")
+	assert.Equal(t, context.Canceled, err)
+	assert.False(t, ok)
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 2, freq)
+}
+
+func TestCache_SetCtx(t *testing.T) {
+	cache := New(0)
+	assert.Nil(t, cache.SetCtx(context.Background(), "a", 1))
+
+	cache.Freeze()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cache.SetCtx(ctx, "b", 2))
+	assert.Equal(t, 1, cache.Size())
+
+	done := make(chan error)
+	go func() { done <- cache.SetCtx(context.Background(), "b", 2) }()
+	time.Sleep(time.Millisecond)
+	cache.Unfreeze()
+	assert.Nil(t, <-done)
+	assert.Equal(t, 2, cache.Size())
+
+	// freezing again waits anew
+	cache.Freeze()
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, context.Canceled, cache.SetCtx(ctx, "c", 3))
+	cache.Unfreeze()
+	assert.Nil(t, cache.Verify())
+}
+
+type ctxKey struct{}
+
+func TestCache_GetOrLoadCtx(t *testing.T) {
+	cache := New(0, WithMaxConcurrentLoads(1))
+	ctx := context.WithValue(context.Background(), ctxKey{}, "span")
+
+	v, err := cache.GetOrLoadCtx(ctx, "a", func(ctx context.Context, k string) (interface{}, error) {
+		return ctx.Value(ctxKey{}), nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "span", v)
+
+	// a waiter gives up on its own ctx
+	started, release := make(chan struct{}), make(chan struct{})
+	go cache.GetOrLoadCtx(context.Background(), "b", func(ctx context.Context, k string) (interface{}, error) {
+		close(started)
+		<-release
+		return 2, nil
+	})
+	<-started
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = cache.GetOrLoadCtx(timeout, "b", func(ctx context.Context, k string) (interface{}, error) {
+		return nil, nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// and so does a load waiting for a slot
+	_, err = cache.GetOrLoadCtx(timeout, "c", func(ctx context.Context, k string) (interface{}, error) {
+		return 3, nil
+	})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	close(release)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cache.GetOrLoadCtx(canceled, "a", nil)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestCache_GetOrLoadCtxCircuit(t *testing.T) {
+	cache := New(0, WithMaxConcurrentLoads(1), WithCircuitBreaker(1, 0))
+	fail := func(ctx context.Context, k string) (interface{}, error) { return nil, context.Canceled }
+	_, err := cache.GetOrLoadCtx(context.Background(), "a", fail)
+	assert.Equal(t, context.Canceled, err)
+
+	// a probe giving up waiting for a slot lets the next call probe
+	cache.loadSlots <- placeholder
+	canceled, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = cache.GetOrLoadCtx(canceled, "a", fail)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	<-cache.loadSlots
+
+	v, err := cache.GetOrLoadCtx(context.Background(), "a", func(ctx context.Context, k string) (interface{}, error) {
+		return 1, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+}
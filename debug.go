@@ -0,0 +1,101 @@
+package lfu
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultDebugEntries is the number of hottest and coldest entries the
+// DebugHandler serves when the request doesn't say.
+const defaultDebugEntries = 10
+
+// DebugState is what DebugHandler serves.
+type DebugState struct {
+	Size            int          `json:"size"`
+	Cap             int          `json:"cap"`
+	Hits            uint64       `json:"hits"`
+	Misses          uint64       `json:"misses"`
+	HitRatio        float64      `json:"hit_ratio"`
+	Evictions       uint64       `json:"evictions"`
+	EvictionRuns    uint64       `json:"eviction_runs"`
+	LockWaits       uint64       `json:"lock_waits"`
+	LockWaitSeconds float64      `json:"lock_wait_seconds"`
+	Frequencies     []DebugTier  `json:"frequencies"`
+	Evicted         []uint64     `json:"evicted_frequency_histogram"`
+	Top             []DebugEntry `json:"top"`
+	Bottom          []DebugEntry `json:"bottom"`
+}
+
+// DebugTier is the number of items of a frequency count.
+type DebugTier struct {
+	Freq  int `json:"freq"`
+	Items int `json:"items"`
+}
+
+// DebugEntry is a key and its frequency count. It leaves the value out,
+// which may not encode to JSON.
+type DebugEntry struct {
+	Key  string `json:"key"`
+	Freq int    `json:"freq"`
+}
+
+// DebugHandler returns a handler serving the internals of cache as JSON, e.g.
+// to mount on /debug/lfu of a service, as a DebugState: its size and
+// capacity, Stats, the number of items of every frequency, the EvictedFrequencyHistogram, and the
+// keys and frequencies of the n hottest and coldest entries, n being the n
+// query parameter, 10 by default. Values aren't served. Every part is read
+// under the lock on its own, so parts may be a little apart from each other
+// on a busy cache.
+func (c *Cache) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := defaultDebugEntries
+		if s := r.URL.Query().Get("n"); s != "" {
+			var err error
+			if n, err = strconv.Atoi(s); err != nil || n < 0 {
+				http.Error(w, "invalid n: "+s, http.StatusBadRequest)
+				return
+			}
+		}
+
+		stats := c.Stats()
+		state := DebugState{
+			Size:            stats.Size,
+			Cap:             c.capacity(),
+			Hits:            stats.Hits,
+			Misses:          stats.Misses,
+			Evictions:       stats.Evictions,
+			EvictionRuns:    stats.EvictionRuns,
+			LockWaits:       stats.LockWaits,
+			LockWaitSeconds: stats.LockWaited.Seconds(),
+			Evicted:         c.EvictedFrequencyHistogram(),
+			Top:             debugEntries(c.SnapshotTopN(n)),
+			Bottom:          debugEntries(c.ColdestN(n)),
+		}
+		if lookups := stats.Hits + stats.Misses; lookups > 0 {
+			state.HitRatio = float64(stats.Hits) / float64(lookups)
+		}
+		for _, tier := range c.EntriesByTier() {
+			state.Frequencies = append(state.Frequencies, DebugTier{Freq: tier.Freq, Items: len(tier.Entries)})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	})
+}
+
+// capacity returns the current capacity of cache.
+func (c *Cache) capacity() int {
+	c.lock()
+	defer c.unlock()
+
+	return c.cap
+}
+
+func debugEntries(entries []Entry) []DebugEntry {
+	debug := make([]DebugEntry, len(entries))
+	for i, e := range entries {
+		debug[i] = DebugEntry{Key: e.Key, Freq: e.Freq}
+	}
+	return debug
+}
@@ -0,0 +1,53 @@
+package lfu
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCache_DebugHandler(t *testing.T) {
+	cache := New(3)
+	cache.Set("a", 1)
+	cache.SetWithFrequency("b", func() {}, 3)
+	cache.SetWithFrequency("c", 3, 5)
+	cache.Set("d", 4) // evicts a
+	cache.Get("c")
+	cache.Get("a")
+
+	get := func(url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		cache.DebugHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	w := get("/debug/lfu?n=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var state DebugState
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state))
+	assert.Equal(t, DebugState{
+		Size:         3,
+		Cap:          3,
+		Hits:         1,
+		Misses:       1,
+		HitRatio:     0.5,
+		Evictions:    1,
+		EvictionRuns: 1,
+		Frequencies:  []DebugTier{{Freq: 1, Items: 1}, {Freq: 3, Items: 1}, {Freq: 6, Items: 1}},
+		Evicted:      []uint64{1},
+		Top:          []DebugEntry{{Key: "c", Freq: 6}, {Key: "b", Freq: 3}},
+		Bottom:       []DebugEntry{{Key: "d", Freq: 1}, {Key: "b", Freq: 3}},
+	}, state)
+
+	// 10 entries by default
+	state = DebugState{}
+	assert.NoError(t, json.Unmarshal(get("/").Body.Bytes(), &state))
+	assert.Equal(t, 3, len(state.Top))
+
+	assert.Equal(t, http.StatusBadRequest, get("/?n=x").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/?n=-1").Code)
+}
@@ -0,0 +1,113 @@
+package lfu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrTooManyDirty is returned by SetDirty when cache holds MaxDirty dirty
+// entries already, for the caller to FlushDirty them before trying again.
+var ErrTooManyDirty = errors.New("lfu: too many dirty entries")
+
+// SetDirty works like Set, and marks the kv pair dirty: written to cache but
+// not yet to the store behind it, so cache can serve as a write-back layer.
+// FlushDirty writes the dirty entries to the store. Eviction picks clean
+// entries before dirty ones, and writes a dirty victim with the configured
+// WriteBack before dropping it. A later Set of k, like one storing what was
+// read from the store, makes it clean again.
+//
+// With MaxDirty, SetDirty stores nothing and returns ErrTooManyDirty if k
+// would be one dirty entry too many. With WriteThrough, it writes v with
+// WriteBack instead, and stores it clean, or returns the error of WriteBack
+// without storing anything.
+func (c *Cache) SetDirty(k string, v interface{}) error {
+	if c.writeThrough && c.writeBackFn != nil {
+		if err := c.writeBackFn(c.key(k), v); err != nil {
+			return fmt.Errorf("lfu: write through %q: %w", k, err)
+		}
+		c.Set(k, v)
+		return nil
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	if item, ok := c.kv[k]; (!ok || item.dirty == 0) && c.maxDirty > 0 && c.dirty >= c.maxDirty {
+		return ErrTooManyDirty
+	}
+	c.set(k, v)
+	if item, ok := c.kv[k]; ok {
+		c.dirtySeq++
+		item.dirty = c.dirtySeq
+		c.dirty++
+	}
+	return nil
+}
+
+// DirtyCount returns the number of dirty entries in cache.
+func (c *Cache) DirtyCount() int {
+	c.lock()
+	defer c.unlock()
+
+	return c.dirty
+}
+
+// FlushDirty calls writer for every dirty entry, from the least to the most
+// frequently used, which are the closest to eviction, and marks the entries
+// written clean, unless they were set again meanwhile. It stops at the first
+// error of writer, and returns it. The entries are copied under the lock,
+// and writer is called after releasing it, so writer may use cache. It costs
+// O(size of cache) to find the dirty entries.
+func (c *Cache) FlushDirty(writer func(k string, v interface{}) error) error {
+	type dirtyEntry struct {
+		item *kvItem
+		v    interface{}
+		seq  uint64
+	}
+
+	c.lock()
+	entries := make([]dirtyEntry, 0, c.dirty)
+	for e := c.freqList.Front(); e != nil && len(entries) < c.dirty; e = e.Next() {
+		for item := e.Value.(*freqNode).head; item != nil; item = item.next {
+			if item.dirty != 0 {
+				entries = append(entries, dirtyEntry{item: item, v: item.v, seq: item.dirty})
+			}
+		}
+	}
+	c.unlock()
+
+	for _, e := range entries {
+		if err := writer(e.item.k, e.v); err != nil {
+			return fmt.Errorf("lfu: write back %q: %w", e.item.k, err)
+		}
+
+		c.lock()
+		if c.kv[e.item.k] == e.item && e.item.dirty == e.seq {
+			c.clean(e.item)
+		}
+		c.unlock()
+	}
+	return nil
+}
+
+// clean marks item clean. The caller must hold the lock.
+func (c *Cache) clean(item *kvItem) {
+	if item.dirty != 0 {
+		item.dirty = 0
+		c.dirty--
+	}
+}
+
+// writeBack writes a dirty item about to be evicted with the configured
+// WriteBack. The caller must hold the lock.
+func (c *Cache) writeBack(item *kvItem) {
+	if item.dirty == 0 {
+		return
+	}
+	if c.writeBackFn != nil {
+		// the entry goes anyway: WriteBack is meant to retry or log failures
+		_ = c.writeBackFn(item.k, item.v)
+	}
+}
@@ -0,0 +1,151 @@
+package lfu
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_SetDirty(t *testing.T) {
+	cache := New(0, WithWriteBack(nil, 2))
+	assert.NoError(t, cache.SetDirty("a", 1))
+	assert.NoError(t, cache.SetDirty("b", 2))
+	assert.NoError(t, cache.SetDirty("a", 3))
+	assert.Equal(t, ErrTooManyDirty, cache.SetDirty("c", 4))
+	_, ok := cache.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.DirtyCount())
+
+	// a plain Set makes the entry clean, Remove drops it unwritten
+	cache.Set("a", 5)
+	assert.Equal(t, 1, cache.DirtyCount())
+	assert.NoError(t, cache.SetDirty("c", 6))
+	assert.True(t, cache.Remove("c"))
+	assert.Equal(t, 1, cache.DirtyCount())
+	assert.NoError(t, cache.Verify())
+
+	cache.Reset(0)
+	assert.Equal(t, 0, cache.DirtyCount())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_FlushDirty(t *testing.T) {
+	cache := New(0)
+	cache.SetDirty("hot", 1)
+	cache.Get("hot")
+	cache.Get("hot")
+	cache.SetDirty("warm", 2)
+	cache.Get("warm")
+	cache.SetDirty("cold", 3)
+	cache.Set("clean", 4)
+
+	var written []string
+	store := map[string]interface{}{}
+	assert.NoError(t, cache.FlushDirty(func(k string, v interface{}) error {
+		written = append(written, k)
+		store[k] = v
+		return nil
+	}))
+	assert.Equal(t, []string{"cold", "warm", "hot"}, written)
+	assert.Equal(t, map[string]interface{}{"cold": 3, "warm": 2, "hot": 1}, store)
+	assert.Equal(t, 0, cache.DirtyCount())
+
+	// an entry set again while being written stays dirty
+	errStore := errors.New("store down")
+	cache.SetDirty("a", 1)
+	cache.SetDirty("b", 2)
+	err := cache.FlushDirty(func(k string, v interface{}) error {
+		if k == "b" {
+			return errStore
+		}
+		cache.SetDirty(k, 10)
+		return nil
+	})
+	assert.True(t, errors.Is(err, errStore))
+	assert.Equal(t, 2, cache.DirtyCount())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_WriteBackOnEviction(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	store := map[string]interface{}{}
+	cache := New(2, WithClock(clock), WithDefaultTTL(time.Minute), WithWriteBack(func(k string, v interface{}) error {
+		store[k] = v
+		return nil
+	}, 0))
+
+	// the clean entry goes first, though used more
+	cache.SetDirty("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	cache.Set("c", 3)
+	_, ok := cache.Get("b")
+	assert.False(t, ok)
+	assert.Empty(t, store)
+
+	// with no clean candidate, the dirty victim is written before it goes
+	cache.SetDirty("c", 4)
+	cache.Set("d", 5)
+	assert.Equal(t, map[string]interface{}{"a": 1}, store)
+	assert.Equal(t, 1, cache.DirtyCount())
+
+	// expiring writes back too
+	cache.SetDirty("d", 6)
+	clock.Advance(time.Minute)
+	assert.Equal(t, 2, cache.RemoveExpired())
+	assert.Equal(t, map[string]interface{}{"a": 1, "c": 4, "d": 6}, store)
+	assert.Equal(t, 0, cache.DirtyCount())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_WriteThrough(t *testing.T) {
+	errStore := errors.New("store down")
+	store := map[string]interface{}{}
+	cache := New(0, WithWriteThrough(), WithWriteBack(func(k string, v interface{}) error {
+		if k == "bad" {
+			return errStore
+		}
+		store[k] = v
+		return nil
+	}, 0))
+
+	assert.NoError(t, cache.SetDirty("a", 1))
+	assert.Equal(t, map[string]interface{}{"a": 1}, store)
+	assert.Equal(t, 0, cache.DirtyCount())
+	assert.True(t, errors.Is(cache.SetDirty("bad", 2), errStore))
+	_, ok := cache.Get("bad")
+	assert.False(t, ok)
+}
+
+func TestCache_SetDirtyNotRefreshed(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithRefreshAfter(time.Minute, 1))
+	loaded := make(chan struct{}, 1)
+	loader := func(k string) (interface{}, error) {
+		loaded <- placeholder
+		return "backend", nil
+	}
+
+	assert.NoError(t, cache.SetDirty("a", "local"))
+	clock.Advance(time.Minute)
+	v, err := cache.GetWithRefresh("a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "local", v)
+	select {
+	case <-loaded:
+		t.Fatal("a dirty entry got refreshed")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	var written []interface{}
+	assert.NoError(t, cache.FlushDirty(func(k string, v interface{}) error {
+		written = append(written, v)
+		return nil
+	}))
+	assert.Equal(t, []interface{}{"local"}, written)
+
+	// clean again, it is refreshed as usual
+	cache.GetWithRefresh("a", loader)
+	<-loaded
+}
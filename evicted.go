@@ -0,0 +1,47 @@
+package lfu
+
+import "math/bits"
+
+// evictedFrequencies records the frequency counts of evicted items.
+type evictedFrequencies struct {
+	last      int
+	histogram [bits.UintSize]uint64
+}
+
+func (e *evictedFrequencies) record(freq int) {
+	e.last = freq
+	e.histogram[freqBucket(freq)]++
+}
+
+// freqBucket returns the histogram bucket of freq, for the frequency
+// histograms.
+func freqBucket(freq int) int {
+	return bits.Len(uint(freq)) - 1
+}
+
+// LastEvictedFrequency returns the frequency count the last evicted item had,
+// or 0 if nothing was evicted yet. Evicting items with high frequencies means
+// cache is too small for its working set. Removing a key is not an eviction.
+func (c *Cache) LastEvictedFrequency() int {
+	c.lock()
+	defer c.unlock()
+
+	return c.evicted.last
+}
+
+// EvictedFrequencyHistogram returns how many evicted items had each range of
+// frequency counts: the i-th bucket counts the items evicted with a frequency
+// in [2^i, 2^(i+1)). Trailing empty buckets are left out.
+func (c *Cache) EvictedFrequencyHistogram() []uint64 {
+	c.lock()
+	defer c.unlock()
+
+	n := len(c.evicted.histogram)
+	for n > 0 && c.evicted.histogram[n-1] == 0 {
+		n--
+	}
+
+	histogram := make([]uint64, n)
+	copy(histogram, c.evicted.histogram[:n])
+	return histogram
+}
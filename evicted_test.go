@@ -0,0 +1,37 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_LastEvictedFrequency(t *testing.T) {
+	cache := New(2)
+	assert.Equal(t, 0, cache.LastEvictedFrequency())
+	assert.Equal(t, []uint64{}, cache.EvictedFrequencyHistogram())
+
+	cache.SetWithFrequency("a", 1, 5)
+	cache.SetWithFrequency("b", 2, 3)
+	cache.Set("c", 3)
+	assert.Equal(t, 3, cache.LastEvictedFrequency())
+
+	cache.Remove("c")
+	assert.Equal(t, 3, cache.LastEvictedFrequency())
+
+	cache.Evict(1)
+	assert.Equal(t, 5, cache.LastEvictedFrequency())
+
+	cache.Set("d", 4)
+	cache.Set("e", 5)
+	cache.SetMultiple(map[string]interface{}{"f": 6, "g": 7})
+	assert.Equal(t, 1, cache.LastEvictedFrequency())
+
+	cache.SetWithFrequency("h", 8, 17)
+	cache.Evict(1)
+	assert.Equal(t, 1, cache.LastEvictedFrequency())
+	cache.Evict(1)
+	assert.Equal(t, 17, cache.LastEvictedFrequency())
+
+	// 1: d, e, f, g; 2-3: b; 4-7: a; 16-31: h
+	assert.Equal(t, []uint64{4, 1, 1, 0, 1}, cache.EvictedFrequencyHistogram())
+}
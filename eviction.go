@@ -0,0 +1,55 @@
+package lfu
+
+// EvictReason is why an item left cache, as reported to
+// Config.EvictionCallback.
+type EvictReason int
+
+const (
+	// ReasonCapacity is an eviction making room for Capacity or MaxBytes,
+	// including when Resize shrinks cache.
+	ReasonCapacity EvictReason = iota
+	// ReasonEvicted is an eviction asked for by the caller, through Evict,
+	// TrimToMemory or EvictUnderPressure.
+	ReasonEvicted
+	// ReasonDeleted is a removal by the caller, through Remove,
+	// RemoveByPrefix, InvalidateTag, Txn.Remove or Reset.
+	ReasonDeleted
+	// ReasonExpired is the removal of an entry that outlived its TTL, when
+	// looked up or by RemoveExpired.
+	ReasonExpired
+)
+
+// String returns the name of r.
+func (r EvictReason) String() string {
+	switch r {
+	case ReasonCapacity:
+		return "capacity"
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonDeleted:
+		return "deleted"
+	case ReasonExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// deleteItem removes item for the given reason, which unlike evictItem isn't
+// an eviction. An expired item still gets written back if dirty, unlike a
+// deleted one. The caller must hold the lock.
+func (c *Cache) deleteItem(item *kvItem, reason EvictReason) {
+	if reason == ReasonExpired {
+		c.writeBack(item)
+	}
+	c.removeItem(item)
+	c.notifyEvicted(item, reason)
+}
+
+// notifyEvicted queues the call of the EvictionCallback, if any, for item,
+// which left cache for the given reason. The caller must hold the lock.
+func (c *Cache) notifyEvicted(item *kvItem, reason EvictReason) {
+	if fn := c.onEvicted; fn != nil {
+		k, v := item.k, item.v
+		c.after = append(c.after, func() { fn(k, v, reason) })
+	}
+}
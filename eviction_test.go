@@ -0,0 +1,107 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_EvictionCallback(t *testing.T) {
+	type evicted struct {
+		k      string
+		v      interface{}
+		reason EvictReason
+	}
+	var got []evicted
+	clock := NewManualClock(time.Unix(0, 0))
+	var cache *Cache
+	cache = New(2, WithClock(clock), WithEvictionCallback(func(k string, v interface{}, reason EvictReason) {
+		// called outside the lock
+		cache.Size()
+		got = append(got, evicted{k, v, reason})
+	}))
+
+	cache.Set("a", 1)
+	cache.Set("a", 2)
+	cache.Set("b", 3)
+	cache.Set("c", 4)
+	assert.Equal(t, []evicted{{"b", 3, ReasonCapacity}}, got)
+
+	got = nil
+	cache.Evict(1)
+	assert.Equal(t, []evicted{{"c", 4, ReasonEvicted}}, got)
+
+	got = nil
+	cache.Remove("a")
+	cache.Remove("a")
+	assert.Equal(t, []evicted{{"a", 2, ReasonDeleted}}, got)
+
+	got = nil
+	cache.SetWithTTL("d", 5, time.Minute)
+	cache.SetWithTTL("e", 6, time.Minute)
+	clock.Advance(time.Minute)
+	cache.Get("d")
+	cache.RemoveExpired()
+	assert.Equal(t, []evicted{{"d", 5, ReasonExpired}, {"e", 6, ReasonExpired}}, got)
+
+	got = nil
+	cache.Set("f", 7)
+	cache.Batch(func(tx *Txn) { tx.Remove("f") })
+	cache.Set("g", 8)
+	cache.Reset(2)
+	assert.Equal(t, []evicted{{"f", 7, ReasonDeleted}, {"g", 8, ReasonDeleted}}, got)
+
+	got = nil
+	cache.Set("h", 9)
+	cache.Set("i", 10)
+	cache.Get("i")
+	cache.Resize(1)
+	assert.Equal(t, []evicted{{"h", 9, ReasonCapacity}}, got)
+}
+
+func TestEvictReason_String(t *testing.T) {
+	assert.Equal(t, "capacity", ReasonCapacity.String())
+	assert.Equal(t, "evicted", ReasonEvicted.String())
+	assert.Equal(t, "deleted", ReasonDeleted.String())
+	assert.Equal(t, "expired", ReasonExpired.String())
+	assert.Equal(t, "unknown", EvictReason(-1).String())
+}
+
+func TestCache_EvictionBatch(t *testing.T) {
+	cache := New(4, WithEvictionBatch(3))
+	for _, k := range []string{"a", "b", "c", "d"} {
+		cache.Set(k, k)
+	}
+	cache.Get("d")
+
+	evicted, k := cache.SetReport("e", "e")
+	assert.True(t, evicted)
+	assert.Equal(t, "a", k)
+	assert.Equal(t, []string{"e", "d"}, cache.Keys())
+	cache.Set("f", "f")
+	cache.Set("g", "g")
+	assert.Equal(t, 4, cache.Size())
+	stats := cache.Stats()
+	assert.Equal(t, uint64(1), stats.EvictionRuns)
+	assert.Equal(t, uint64(3), stats.Evictions)
+	assert.Equal(t, 3, stats.EvictionBatch)
+}
+
+func TestCache_LowWatermark(t *testing.T) {
+	cache := New(10, WithLowWatermark(0.7), WithEvictionBatch(2))
+	for i := 0; i < 11; i++ {
+		cache.Set(string(rune('a'+i)), i)
+	}
+	assert.Equal(t, 7, cache.Size())
+	assert.Equal(t, uint64(4), cache.Stats().Evictions)
+	assert.Equal(t, 0.7, cache.Stats().LowWatermark)
+
+	// the larger batch wins, and it follows Resize
+	cache.Resize(20)
+	for i := 0; i < 14; i++ {
+		cache.Set(string(rune('A'+i)), i)
+	}
+	assert.Equal(t, 14, cache.Size())
+	assert.Equal(t, uint64(2), cache.Stats().EvictionRuns)
+	assert.NoError(t, cache.Verify())
+}
@@ -0,0 +1,45 @@
+package lfu
+
+import "container/heap"
+
+// expiryHeap holds the items with a TTL, the soonest to expire first, so
+// RemoveExpired finds the expired items without walking the others. Every
+// item in it knows its index, see kvItem.expiring.
+type expiryHeap []*kvItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].expireAt.Before(h[j].expireAt) }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].expiring, h[j].expiring = i+1, j+1
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*kvItem)
+	*h = append(*h, item)
+	item.expiring = len(*h)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	item.expiring = 0
+	return item
+}
+
+// expireAfter files item in the expiry heap, once its expireAt is set, or
+// takes it out if it no longer expires. The caller must hold the lock.
+func (c *Cache) expireAfter(item *kvItem) {
+	switch {
+	case item.expireAt.IsZero() && item.expiring != 0:
+		heap.Remove(&c.expiries, item.expiring-1)
+	case item.expireAt.IsZero():
+	case item.expiring == 0:
+		heap.Push(&c.expiries, item)
+	default:
+		heap.Fix(&c.expiries, item.expiring-1)
+	}
+}
@@ -0,0 +1,36 @@
+package lfu
+
+import "os"
+
+// FileCache caches file contents keyed by path.
+type FileCache struct {
+	cache *Cache
+}
+
+// NewFileCache creates a FileCache holding the contents of at most cap files.
+// A non-positive cap means the cache won't do any eviction.
+func NewFileCache(cap int) *FileCache {
+	return &FileCache{cache: New(cap)}
+}
+
+// GetFile returns the contents of the file at path, reading and caching them
+// on a miss. The returned bytes are shared with the cache and must not be
+// modified. Changes to the file are not seen until path is invalidated.
+func (fc *FileCache) GetFile(path string) ([]byte, error) {
+	if v, ok := fc.cache.Get(path); ok {
+		return v.([]byte), nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fc.cache.Set(path, b)
+	return b, nil
+}
+
+// Invalidate drops the cached contents of the file at path, so the next
+// GetFile reads it again. It reports whether path was cached.
+func (fc *FileCache) Invalidate(path string) bool {
+	return fc.cache.Remove(path)
+}
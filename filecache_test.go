@@ -0,0 +1,44 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCache_GetFile(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+	assert.NoError(t, os.WriteFile(a, []byte("a1"), 0600))
+	assert.NoError(t, os.WriteFile(b, []byte("b1"), 0600))
+
+	fc := NewFileCache(1)
+
+	_, err := fc.GetFile(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, 0, fc.cache.Size())
+
+	data, err := fc.GetFile(a)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a1"), data)
+
+	// served from cache: the change isn't seen until invalidated
+	assert.NoError(t, os.WriteFile(a, []byte("a2"), 0600))
+	data, err = fc.GetFile(a)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a1"), data)
+	assert.Equal(t, 2, fc.cache.kv[a].parent.Value.(*freqNode).freq)
+
+	assert.True(t, fc.Invalidate(a))
+	assert.False(t, fc.Invalidate(a))
+	data, err = fc.GetFile(a)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("a2"), data)
+
+	data, err = fc.GetFile(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("b1"), data)
+	assert.Equal(t, 1, fc.cache.Size())
+}
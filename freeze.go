@@ -0,0 +1,64 @@
+package lfu
+
+import (
+	"context"
+	"sync"
+)
+
+// Freeze makes cache read-only until Unfreeze is called, e.g. to export a
+// consistent state without holding the lock for the whole export.
+//
+// While frozen, reads proceed but don't count as accesses, so frequencies
+// stay as they were, and mutations (Set, Evict, Restore, ...) block until the
+// cache is unfrozen. Freezing a frozen cache is a no-op.
+func (c *Cache) Freeze() {
+	c.lock()
+	defer c.unlock()
+
+	if c.unfrozen == nil {
+		c.unfrozen = sync.NewCond(&c.mu)
+	}
+	if !c.frozen {
+		c.thawed = make(chan struct{})
+	}
+	c.frozen = true
+}
+
+// Unfreeze makes a frozen cache writable again and wakes up the mutations
+// blocked by Freeze.
+func (c *Cache) Unfreeze() {
+	c.lock()
+	defer c.unlock()
+
+	if !c.frozen {
+		return
+	}
+	c.frozen = false
+	c.unfrozen.Broadcast()
+	close(c.thawed)
+}
+
+// waitWritable blocks until cache isn't frozen. The caller must hold the lock.
+func (c *Cache) waitWritable() {
+	for c.frozen {
+		c.unfrozen.Wait()
+	}
+}
+
+// waitWritableCtx works like waitWritable, and stops waiting when ctx is
+// done, returning ctx.Err(). The caller must hold the lock, and still holds
+// it when it returns.
+func (c *Cache) waitWritableCtx(ctx context.Context) error {
+	for c.frozen {
+		thawed := c.thawed
+		c.mu.Unlock()
+		select {
+		case <-thawed:
+		case <-ctx.Done():
+			c.mu.Lock()
+			return ctx.Err()
+		}
+		c.mu.Lock()
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_Freeze(t *testing.T) {
+	cache := New(2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+
+	cache.Freeze()
+	cache.Freeze()
+
+	v, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	done := make(chan struct{})
+	go func() {
+		cache.Set("c", 3)
+		cache.Evict(1)
+		close(done)
+	}()
+
+	exported := map[string]interface{}{}
+	cache.ForEach(func(k string, v interface{}) {
+		exported[k] = v
+	})
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 2}, exported)
+
+	select {
+	case <-done:
+		t.Fatal("mutation proceeded while frozen")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.Equal(t, 2, cache.Size())
+
+	cache.Unfreeze()
+	<-done
+	assert.Equal(t, 1, cache.Size())
+
+	cache.Get("a")
+	assert.Equal(t, 3, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	cache.Unfreeze()
+	cache.Set("d", 4)
+	assert.Equal(t, 2, cache.Size())
+}
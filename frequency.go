@@ -0,0 +1,223 @@
+package lfu
+
+import (
+	"container/list"
+	"math/bits"
+	"time"
+)
+
+// ResetFrequencies sets the frequency count of every item back to 1 while
+// keeping the values, so cache relearns which keys are hot, e.g. after the
+// workload changed.
+func (c *Cache) ResetFrequencies() {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	if len(c.kv) == 0 {
+		return
+	}
+
+	node := newFreqNode(1)
+	c.freqList = list.New()
+	e := c.freqList.PushFront(node)
+	for _, item := range c.kv {
+		item.parent = e
+		node.push(item)
+		if c.window != nil {
+			c.window.reset(item, c.epoch(), 1)
+		}
+	}
+}
+
+// DecayFrequencies halves the frequency count of every item, rounding down
+// but keeping it at least 1, so keys hot long ago fall back towards new ones
+// while the order of the items is kept. Config.FrequencyDecayInterval does it
+// periodically. It costs O(number of distinct frequencies), plus the items
+// of the frequencies merging together.
+func (c *Cache) DecayFrequencies() {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	c.halveFrequencies()
+}
+
+// halveFrequencies halves the frequency of every freq node, merging the nodes
+// ending up with the same one. The caller must hold the lock.
+func (c *Cache) halveFrequencies() {
+	var prev *list.Element
+	for e := c.freqList.Front(); e != nil; {
+		next := e.Next()
+		node := e.Value.(*freqNode)
+		if node.freq /= 2; node.freq < 1 {
+			node.freq = 1
+		}
+		if prev != nil && prev.Value.(*freqNode).freq == node.freq {
+			mergeNodes(prev, e)
+			c.freqList.Remove(e)
+		} else {
+			prev = e
+		}
+		e = next
+	}
+
+	if c.window != nil {
+		epoch := c.epoch()
+		for _, item := range c.kv {
+			c.window.reset(item, epoch, item.parent.Value.(*freqNode).freq)
+		}
+	}
+}
+
+// decayIfDue halves the frequencies once for every FrequencyDecayInterval
+// passed since they were last halved. The caller must hold the lock.
+func (c *Cache) decayIfDue() {
+	if c.decayEvery <= 0 {
+		return
+	}
+	passed := c.now().Sub(c.decayedAt) / c.decayEvery
+	if passed <= 0 {
+		return
+	}
+	c.decayedAt = c.decayedAt.Add(passed * c.decayEvery)
+
+	// halving as many times as an int has bits brings any frequency down to
+	// 1, so a long idle period doesn't cost more
+	if passed > bits.UintSize {
+		passed = bits.UintSize
+	}
+	for i := time.Duration(0); i < passed; i++ {
+		c.halveFrequencies()
+	}
+}
+
+// GetFrequency returns the frequency count of k, including the hits
+// LazyPromotions still has queued, e.g. for tests and debugging tools to tell
+// how hot k is. It doesn't count as an access. ok is false if k isn't in
+// cache.
+func (c *Cache) GetFrequency(k string) (freq int, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return 0, false
+	}
+	return item.parent.Value.(*freqNode).freq + item.queuedHits, true
+}
+
+// GetFrequencyRank returns how far k is from eviction: the number of items
+// with a lower frequency count than k, so rank 0 means k is among the least
+// frequently used items, next in line for eviction. It doesn't count as an
+// access. It walks the freq nodes below k, so it costs O(number of distinct
+// frequencies) and is meant for debugging rather than hot paths.
+func (c *Cache) GetFrequencyRank(k string) (rank int, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return
+	}
+
+	for e := c.freqList.Front(); e != item.parent; e = e.Next() {
+		rank += e.Value.(*freqNode).n
+	}
+	return
+}
+
+// IsEvictionCandidate reports whether k is among the items Evict would pick
+// next: those with the lowest frequency count in cache. It is false for a k
+// that isn't in cache or is pinned. Unlike GetFrequencyRank, it costs O(1),
+// and it doesn't count as an access either.
+func (c *Cache) IsEvictionCandidate(k string) bool {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	return ok && !item.pinned && item.parent == c.freqList.Front()
+}
+
+// SetFrequency moves k to exactly the given frequency count, up or down,
+// and reports whether k is in cache. A freq below 1 is treated as 1. It
+// doesn't count as an access and leaves the value alone.
+func (c *Cache) SetFrequency(k string, freq int) bool {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return false
+	}
+
+	if freq < 1 {
+		c.invalid("SetFrequency of %q to frequency %d", k, freq)
+		freq = 1
+	}
+	c.setFrequency(item, freq)
+	return true
+}
+
+// FreqListLength returns the number of distinct frequency counts among the
+// items in cache, which is what the memory of freq nodes grows with.
+func (c *Cache) FreqListLength() int {
+	c.lock()
+	defer c.unlock()
+
+	return c.freqList.Len()
+}
+
+// Touch counts one access to k, like a Get of k would, without returning
+// the value, e.g. to keep a key hot that is read through another path. It
+// reports whether k is in cache. Unlike a Get, it doesn't count as a hit in
+// Stats, and a frozen cache leaves the frequency alone.
+func (c *Cache) Touch(k string) bool {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if ok {
+		c.increment(item)
+	}
+	return ok
+}
+
+// TouchBy counts delta accesses to k at once, e.g. for a read standing for
+// several logical ones, moving k straight to its new frequency count. It
+// reports whether k is in cache. A non-positive delta, or a frozen cache,
+// leaves the frequency alone.
+func (c *Cache) TouchBy(k string, delta int) bool {
+	c.lock()
+	defer c.unlock()
+
+	if delta < 0 {
+		c.invalid("TouchBy of %q by %d", k, delta)
+	}
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return false
+	}
+	if delta > 0 && !c.frozen {
+		c.touch(item, delta)
+	}
+	return true
+}
+
+// touch counts n accesses of item at once. The caller must hold the lock.
+func (c *Cache) touch(item *kvItem, n int) {
+	if c.window != nil {
+		c.touchWindow(item, n)
+		return
+	}
+	c.moveTo(item, item.parent.Value.(*freqNode).freq+n)
+}
+
+// setFrequency moves item to exactly freq. The caller must hold the lock.
+func (c *Cache) setFrequency(item *kvItem, freq int) {
+	if c.window != nil {
+		c.window.reset(item, c.epoch(), freq)
+	}
+	c.moveTo(item, freq)
+}
@@ -0,0 +1,267 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache_ResetFrequencies(t *testing.T) {
+	cache := New(3)
+	cache.ResetFrequencies()
+	assert.Equal(t, 0, cache.freqList.Len())
+
+	cache.Set("a", 1)
+	cache.SetWithFrequency("b", 2, 5)
+	cache.SetWithFrequency("c", 3, 9)
+
+	cache.ResetFrequencies()
+	assert.Equal(t, 3, cache.Size())
+	assert.Equal(t, 1, cache.freqList.Len())
+	front := cache.freqList.Front()
+	assert.Equal(t, 1, front.Value.(*freqNode).freq)
+	assert.Equal(t, 3, front.Value.(*freqNode).n)
+	for k, v := range map[string]int{"a": 1, "b": 2, "c": 3} {
+		assert.Equal(t, v, cache.kv[k].v)
+		assert.Equal(t, front, cache.kv[k].parent)
+	}
+
+	cache.Get("a")
+	cache.Get("c")
+	cache.Evict(1)
+	_, ok := cache.kv["b"]
+	assert.False(t, ok)
+}
+
+func TestCache_GetFrequency(t *testing.T) {
+	cache := New(0, WithKeyNormalizer(strings.ToLower))
+	_, ok := cache.GetFrequency("a")
+	assert.False(t, ok)
+
+	cache.Set("a", 1)
+	cache.Get("a")
+	freq, ok := cache.GetFrequency("A")
+	assert.True(t, ok)
+	assert.Equal(t, 2, freq)
+	cache.Peek("a")
+	freq, _ = cache.GetFrequency("a")
+	assert.Equal(t, 2, freq)
+	assert.Equal(t, CacheStats{Hits: 1, Size: 1}, cache.Stats())
+
+	// queued hits count before they are applied
+	cache = New(0, WithLazyPromotions(10))
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+	freq, _ = cache.GetFrequency("a")
+	assert.Equal(t, 3, freq)
+	cache.Flush()
+	freq, _ = cache.GetFrequency("a")
+	assert.Equal(t, 3, freq)
+}
+
+func TestCache_GetFrequencyRank(t *testing.T) {
+	cache := New(0)
+	_, ok := cache.GetFrequencyRank("a")
+	assert.False(t, ok)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetWithFrequency("c", 3, 2)
+	cache.SetWithFrequency("d", 4, 5)
+	cache.SetWithFrequency("e", 5, 5)
+
+	for k, want := range map[string]int{"a": 0, "b": 0, "c": 2, "d": 3, "e": 3} {
+		rank, ok := cache.GetFrequencyRank(k)
+		assert.True(t, ok)
+		assert.Equal(t, want, rank, k)
+	}
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	cache.Get("a")
+	cache.Get("a")
+	rank, _ := cache.GetFrequencyRank("a")
+	assert.Equal(t, 2, rank)
+	rank, _ = cache.GetFrequencyRank("d")
+	assert.Equal(t, 3, rank)
+}
+
+func TestCache_SetFrequency(t *testing.T) {
+	cache := New(3)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+
+	assert.False(t, cache.SetFrequency("d", 5))
+
+	// raising a takes it out of the eviction order's front
+	assert.True(t, cache.SetFrequency("a", 5))
+	assert.True(t, cache.SetFrequency("b", 3))
+	assert.NoError(t, cache.checkInvariants())
+	cache.Evict(1)
+	_, ok := cache.kv["c"]
+	assert.False(t, ok)
+
+	// lowering a below b makes it next in line
+	assert.True(t, cache.SetFrequency("a", 0))
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.NoError(t, cache.checkInvariants())
+	cache.Evict(1)
+	_, ok = cache.kv["a"]
+	assert.False(t, ok)
+	_, ok = cache.kv["b"]
+	assert.True(t, ok)
+	assert.Equal(t, 1, cache.freqList.Len())
+}
+
+func TestCache_FreqListLength(t *testing.T) {
+	cache := New(0)
+	assert.Equal(t, 0, cache.FreqListLength())
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	assert.Equal(t, 1, cache.FreqListLength())
+
+	cache.Get("b")
+	cache.Get("c")
+	cache.Get("c")
+	assert.Equal(t, 3, cache.FreqListLength())
+
+	// b catching up with c merges their nodes, and a leaving empties its own
+	cache.Get("b")
+	assert.Equal(t, 2, cache.FreqListLength())
+	cache.Remove("a")
+	assert.Equal(t, 1, cache.FreqListLength())
+}
+
+func TestCache_Touch(t *testing.T) {
+	cache := New(2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+
+	assert.False(t, cache.Touch("c"))
+	assert.True(t, cache.Touch("a"))
+	assert.True(t, cache.Touch("a"))
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 3, freq)
+	assert.Equal(t, uint64(0), cache.Stats().Hits)
+
+	// the touched key outlives the untouched one
+	cache.Set("c", 3)
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	cache.Freeze()
+	assert.True(t, cache.Touch("a"))
+	freq, _ = cache.GetFrequency("a")
+	assert.Equal(t, 4, freq)
+	cache.Unfreeze()
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_TouchBy(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+
+	assert.False(t, cache.TouchBy("c", 1))
+
+	assert.True(t, cache.TouchBy("a", 10))
+	assert.Equal(t, 11, cache.kv["a"].parent.Value.(*freqNode).freq)
+	// no intermediate nodes
+	assert.Equal(t, 2, cache.freqList.Len())
+
+	assert.True(t, cache.TouchBy("b", 9))
+	assert.Equal(t, cache.kv["a"].parent, cache.kv["b"].parent)
+	assert.Equal(t, 1, cache.freqList.Len())
+
+	assert.True(t, cache.TouchBy("b", 0))
+	assert.True(t, cache.TouchBy("b", -3))
+	assert.Equal(t, 11, cache.kv["b"].parent.Value.(*freqNode).freq)
+
+	assert.True(t, cache.TouchBy("b", 1))
+	assert.Equal(t, 12, cache.kv["b"].parent.Value.(*freqNode).freq)
+	assert.NoError(t, cache.checkInvariants())
+}
+
+func TestCache_IsEvictionCandidate(t *testing.T) {
+	cache := New(0)
+	assert.False(t, cache.IsEvictionCandidate("a"))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetWithFrequency("c", 3, 4)
+	assert.True(t, cache.IsEvictionCandidate("a"))
+	assert.True(t, cache.IsEvictionCandidate("b"))
+	assert.False(t, cache.IsEvictionCandidate("c"))
+	assert.False(t, cache.IsEvictionCandidate("x"))
+
+	// a climbs out of the front tier, leaving b alone in it
+	cache.Get("a")
+	assert.False(t, cache.IsEvictionCandidate("a"))
+	assert.True(t, cache.IsEvictionCandidate("b"))
+
+	// the front tier moves up once b is gone
+	cache.Remove("b")
+	assert.True(t, cache.IsEvictionCandidate("a"))
+	assert.False(t, cache.IsEvictionCandidate("c"))
+
+	// pinned items are never evicted
+	cache.Pin("a")
+	assert.False(t, cache.IsEvictionCandidate("a"))
+}
+
+func TestCache_DecayFrequencies(t *testing.T) {
+	cache := New(0)
+	cache.DecayFrequencies()
+
+	for k, freq := range map[string]int{"a": 1, "b": 2, "c": 3, "d": 8, "e": 9} {
+		cache.SetWithFrequency(k, k, freq)
+	}
+	cache.DecayFrequencies()
+	freqs := map[string]int{}
+	for _, e := range cache.Snapshot() {
+		freqs[e.Key] = e.Freq
+	}
+	assert.Equal(t, map[string]int{"a": 1, "b": 1, "c": 1, "d": 4, "e": 4}, freqs)
+	assert.Equal(t, 2, cache.FreqListLength())
+	assert.NoError(t, cache.Verify())
+
+	cache.DecayFrequencies()
+	cache.DecayFrequencies()
+	assert.Equal(t, 1, cache.FreqListLength())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_FrequencyDecayInterval(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(2, WithClock(clock), WithFrequencyDecay(time.Hour))
+
+	// a was hot last week
+	cache.SetWithFrequency("a", 1, 100)
+	clock.Advance(30 * time.Minute)
+	cache.Get("a")
+	assert.Equal(t, 101, cache.Snapshot()[0].Freq)
+
+	clock.Advance(30 * time.Minute)
+	cache.Get("a")
+	assert.Equal(t, 51, cache.Snapshot()[0].Freq)
+
+	// a week later, b used a few times now outweighs a
+	clock.Advance(7 * 24 * time.Hour)
+	cache.Set("b", 2)
+	for i := 0; i < 3; i++ {
+		cache.Get("b")
+	}
+	cache.Set("c", 3)
+	_, ok := cache.Peek("a")
+	assert.False(t, ok)
+	_, ok = cache.Peek("b")
+	assert.True(t, ok)
+	assert.NoError(t, cache.Verify())
+}
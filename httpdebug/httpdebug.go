@@ -0,0 +1,158 @@
+// Package httpdebug serves an HTTP admin API for an lfu cache, to triage it
+// in production without redeploying: its stats, its hottest keys, lookups of
+// single keys, and deleting keys or emptying it.
+//
+// The handler does no authentication of its own: wrap it in the middleware
+// of the service, e.g. to require an admin role, before mounting it.
+package httpdebug
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ZhengHe-MD/lfu"
+)
+
+// defaultTop is the number of hottest keys /top serves when the request
+// doesn't say, like DebugHandler does for /stats.
+const defaultTop = 10
+
+// Key is a key and its frequency count, as /top serves them, and /stats the
+// hottest and coldest ones.
+type Key = lfu.DebugEntry
+
+// Entry is what /key serves. Value is the value of the key if it encodes to
+// JSON, and its fmt.Sprint otherwise.
+type Entry struct {
+	Key
+	Value          interface{} `json:"value"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	LastAccessedAt *time.Time  `json:"last_accessed_at,omitempty"`
+	ExpiresAt      *time.Time  `json:"expires_at,omitempty"`
+}
+
+// Handler returns the admin API of c, with paths relative to where it is
+// mounted, e.g. under http.StripPrefix("/debug/cache", Handler(c)):
+//
+//	GET  /stats?n=10     the lfu.DebugState of c, as c.DebugHandler serves it
+//	GET  /top?n=10       the n hottest keys, from the hottest, as Keys
+//	GET  /key?k=...      the Entry of k, without counting it as an access
+//	POST /delete?k=...   removes k, serving whether it was in c
+//	POST /purge          empties c
+//
+// Every response is JSON. A key that isn't in c is a 404, and the wrong
+// method a 405.
+func Handler(c *lfu.Cache) http.Handler {
+	mux := http.NewServeMux()
+	debug := c.DebugHandler()
+	mux.HandleFunc("/stats", only(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		// checked here for the error to be JSON too
+		if _, ok := count(w, r); ok {
+			debug.ServeHTTP(w, r)
+		}
+	}))
+	mux.HandleFunc("/top", only(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		n, ok := count(w, r)
+		if !ok {
+			return
+		}
+		entries := c.SnapshotTopN(n)
+		keys := make([]Key, len(entries))
+		for i, e := range entries {
+			keys[i] = Key{Key: e.Key, Freq: e.Freq}
+		}
+		serve(w, http.StatusOK, keys)
+	}))
+	mux.HandleFunc("/key", only(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		k, ok := key(w, r)
+		if !ok {
+			return
+		}
+		info, ok := c.GetEntry(k)
+		if !ok {
+			fail(w, http.StatusNotFound, "not in cache: "+k)
+			return
+		}
+		serve(w, http.StatusOK, entryOf(info))
+	}))
+	mux.HandleFunc("/delete", only(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		if k, ok := key(w, r); ok {
+			serve(w, http.StatusOK, map[string]bool{"removed": c.Remove(k)})
+		}
+	}))
+	mux.HandleFunc("/purge", only(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		c.Purge()
+		serve(w, http.StatusOK, map[string]bool{"purged": true})
+	}))
+	return mux
+}
+
+// only lets requests of method through to h, failing the others.
+func only(method string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			fail(w, http.StatusMethodNotAllowed, "method not allowed: "+r.Method)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// count returns the n query parameter of r, defaultTop if it is missing,
+// failing r if it isn't a count.
+func count(w http.ResponseWriter, r *http.Request) (int, bool) {
+	s := r.URL.Query().Get("n")
+	if s == "" {
+		return defaultTop, true
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		fail(w, http.StatusBadRequest, "invalid n: "+s)
+		return 0, false
+	}
+	return n, true
+}
+
+// key returns the k query parameter of r, failing r if it is missing.
+func key(w http.ResponseWriter, r *http.Request) (string, bool) {
+	k := r.URL.Query().Get("k")
+	if k == "" {
+		fail(w, http.StatusBadRequest, "missing k")
+		return "", false
+	}
+	return k, true
+}
+
+func entryOf(info lfu.EntryInfo) Entry {
+	e := Entry{
+		Key:       Key{Key: info.Key, Freq: info.Freq},
+		Value:     info.Value,
+		CreatedAt: info.CreatedAt,
+		UpdatedAt: info.UpdatedAt,
+	}
+	if _, err := json.Marshal(info.Value); err != nil {
+		e.Value = fmt.Sprint(info.Value)
+	}
+	if !info.LastAccessedAt.IsZero() {
+		e.LastAccessedAt = &info.LastAccessedAt
+	}
+	if !info.ExpiresAt.IsZero() {
+		e.ExpiresAt = &info.ExpiresAt
+	}
+	return e
+}
+
+func serve(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func fail(w http.ResponseWriter, status int, msg string) {
+	serve(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,80 @@
+package httpdebug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ZhengHe-MD/lfu"
+	"github.com/stretchr/testify/assert"
+)
+
+func do(h http.Handler, method, url string, body interface{}) int {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, url, nil))
+	if body != nil {
+		json.NewDecoder(w.Body).Decode(body)
+	}
+	return w.Code
+}
+
+func TestHandler(t *testing.T) {
+	cache := lfu.New(2)
+	cache.Set("a", 1)
+	cache.Set("b", map[string]int{"x": 2})
+	cache.Get("b")
+	cache.Get("b")
+	cache.Get("missing")
+	h := Handler(cache)
+
+	// the same as DebugHandler
+	var stats lfu.DebugState
+	assert.Equal(t, http.StatusOK, do(h, http.MethodGet, "/stats?n=1", &stats))
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, 2, stats.Cap)
+	assert.Equal(t, uint64(2), stats.Hits)
+	assert.InDelta(t, 2.0/3, stats.HitRatio, 1e-9)
+	assert.Equal(t, []lfu.DebugTier{{Freq: 1, Items: 1}, {Freq: 3, Items: 1}}, stats.Frequencies)
+	assert.Equal(t, []Key{{Key: "b", Freq: 3}}, stats.Top)
+	assert.Equal(t, http.StatusMethodNotAllowed, do(h, http.MethodPost, "/stats", nil))
+	var failed map[string]string
+	assert.Equal(t, http.StatusBadRequest, do(h, http.MethodGet, "/stats?n=x", &failed))
+	assert.Equal(t, map[string]string{"error": "invalid n: x"}, failed)
+
+	var top []Key
+	assert.Equal(t, http.StatusOK, do(h, http.MethodGet, "/top?n=1", &top))
+	assert.Equal(t, []Key{{Key: "b", Freq: 3}}, top)
+	assert.Equal(t, http.StatusBadRequest, do(h, http.MethodGet, "/top?n=x", nil))
+
+	// a lookup doesn't count as an access
+	var e Entry
+	assert.Equal(t, http.StatusOK, do(h, http.MethodGet, "/key?k=a", &e))
+	assert.Equal(t, Key{Key: "a", Freq: 1}, e.Key)
+	assert.Equal(t, 1.0, e.Value)
+	assert.Nil(t, e.ExpiresAt)
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 1, freq)
+	assert.Equal(t, http.StatusNotFound, do(h, http.MethodGet, "/key?k=c", nil))
+	assert.Equal(t, http.StatusBadRequest, do(h, http.MethodGet, "/key", nil))
+
+	var removed map[string]bool
+	assert.Equal(t, http.StatusMethodNotAllowed, do(h, http.MethodGet, "/delete?k=a", nil))
+	assert.Equal(t, http.StatusOK, do(h, http.MethodPost, "/delete?k=a", &removed))
+	assert.Equal(t, map[string]bool{"removed": true}, removed)
+	assert.Equal(t, 1, cache.Size())
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(h, http.MethodGet, "/purge", nil))
+	assert.Equal(t, http.StatusOK, do(h, http.MethodPost, "/purge", nil))
+	assert.Equal(t, 0, cache.Size())
+}
+
+func TestHandler_ValueNotJSON(t *testing.T) {
+	cache := lfu.New(0)
+	cache.Set("ch", make(chan int))
+	h := http.StripPrefix("/debug/cache", Handler(cache))
+
+	var e Entry
+	assert.Equal(t, http.StatusOK, do(h, http.MethodGet, "/debug/cache/key?k=ch", &e))
+	assert.IsType(t, "", e.Value)
+}
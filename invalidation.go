@@ -0,0 +1,176 @@
+package lfu
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+)
+
+// Invalidation is a removal from cache broadcast by a replica to the others,
+// through an Invalidator. Either Key or Tag is set.
+type Invalidation struct {
+	// Key is a key to remove, see Remove.
+	Key string `json:"key,omitempty"`
+	// Tag is a tag to invalidate, see InvalidateTag.
+	Tag string `json:"tag,omitempty"`
+	// Origin identifies the Replicated cache that published it.
+	Origin string `json:"origin"`
+}
+
+// Invalidator is a bus carrying Invalidations between the replicas of a
+// cache, e.g. the instances of a service holding the same keys, so a write
+// handled by one of them drops the stale copies of the others. ChanBus is
+// one for replicas in a process, and package redisbus one over Redis
+// pub/sub.
+type Invalidator interface {
+	// Publish broadcasts inv to every subscriber, the own ones included.
+	Publish(inv Invalidation) error
+	// Subscribe calls fn with every Invalidation published from then on, in
+	// the order they were, until stop is called. stop waits for a call of fn
+	// under way to return.
+	Subscribe(fn func(inv Invalidation)) (stop func() error, err error)
+}
+
+// Replicated is a *Cache whose writes and removals are broadcast through an
+// Invalidator, and which applies those of the other replicas subscribed to
+// it. Writes and reads not made through Replicated stay local.
+//
+// Invalidation is eventual: a replica serves its copy of k until the
+// Invalidation of a sibling's write reaches it.
+type Replicated struct {
+	c      *Cache
+	bus    Invalidator
+	origin string
+	stop   func() error
+}
+
+// NewReplicated subscribes c to bus, returning the Replicated cache that
+// publishes to it. Close unsubscribes it.
+func NewReplicated(c *Cache, bus Invalidator) (*Replicated, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	r := &Replicated{c: c, bus: bus, origin: hex.EncodeToString(id[:])}
+	stop, err := bus.Subscribe(r.apply)
+	if err != nil {
+		return nil, err
+	}
+	r.stop = stop
+	return r, nil
+}
+
+// Get returns the v related to k, like Cache.Get.
+func (r *Replicated) Get(k string) (v interface{}, ok bool) {
+	return r.c.Get(k)
+}
+
+// Set stores the kv pair, and has the other replicas remove k, whose copy
+// would be stale. It returns the error of the Invalidator, the kv pair being
+// stored regardless.
+func (r *Replicated) Set(k string, v interface{}) error {
+	r.c.Set(k, v)
+	return r.publish(Invalidation{Key: k})
+}
+
+// Remove deletes k from every replica, reporting whether it was in this one.
+func (r *Replicated) Remove(k string) (bool, error) {
+	removed := r.c.Remove(k)
+	return removed, r.publish(Invalidation{Key: k})
+}
+
+// InvalidateTag deletes the keys tagged with tag from every replica,
+// returning how many this one deleted.
+func (r *Replicated) InvalidateTag(tag string) (int, error) {
+	n := r.c.InvalidateTag(tag)
+	return n, r.publish(Invalidation{Tag: tag})
+}
+
+// Local returns the cache of this replica.
+func (r *Replicated) Local() *Cache {
+	return r.c
+}
+
+// Close unsubscribes the cache from the Invalidator.
+func (r *Replicated) Close() error {
+	return r.stop()
+}
+
+func (r *Replicated) publish(inv Invalidation) error {
+	inv.Origin = r.origin
+	return r.bus.Publish(inv)
+}
+
+// apply removes what inv invalidates, unless this replica published it and
+// thus applied it already.
+func (r *Replicated) apply(inv Invalidation) {
+	if inv.Origin == r.origin {
+		return
+	}
+	if inv.Key != "" {
+		r.c.Remove(inv.Key)
+	}
+	if inv.Tag != "" {
+		r.c.InvalidateTag(inv.Tag)
+	}
+}
+
+// ChanBus is an Invalidator over channels, between the replicas of a
+// process, e.g. for tests. Every subscriber has a goroutine of its own and a
+// buffer of Invalidations, and Publish blocks while the buffer of one is
+// full, so fn must not publish.
+type ChanBus struct {
+	mu     sync.Mutex
+	buffer int
+	subs   map[*chanSub]struct{}
+}
+
+type chanSub struct {
+	ch   chan Invalidation
+	done chan struct{}
+}
+
+// NewChanBus creates a ChanBus buffering up to buffer Invalidations per
+// subscriber.
+func NewChanBus(buffer int) *ChanBus {
+	return &ChanBus{buffer: buffer, subs: make(map[*chanSub]struct{})}
+}
+
+// Publish sends inv to every subscriber.
+func (b *ChanBus) Publish(inv Invalidation) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs {
+		s.ch <- inv
+	}
+	return nil
+}
+
+// Subscribe starts the goroutine calling fn with what is published.
+func (b *ChanBus) Subscribe(fn func(inv Invalidation)) (stop func() error, err error) {
+	s := &chanSub{ch: make(chan Invalidation, b.buffer), done: make(chan struct{})}
+	b.mu.Lock()
+	b.subs[s] = placeholder
+	b.mu.Unlock()
+
+	go func() {
+		defer close(s.done)
+		for inv := range s.ch {
+			fn(inv)
+		}
+	}()
+
+	var once sync.Once
+	return func() error {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, s)
+			close(s.ch)
+			b.mu.Unlock()
+		})
+		<-s.done
+		return nil
+	}, nil
+}
@@ -0,0 +1,50 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestReplicated(t *testing.T) {
+	bus := NewChanBus(4)
+	a, err := NewReplicated(New(0), bus)
+	assert.NoError(t, err)
+	b, err := NewReplicated(New(0), bus)
+	assert.NoError(t, err)
+
+	cached := func(r *Replicated, k string) func() bool {
+		return func() bool {
+			_, ok := r.Get(k)
+			return ok
+		}
+	}
+
+	// a write drops the stale copies of the siblings, not the own one
+	b.Local().Set("k", 1)
+	assert.NoError(t, a.Set("k", 2))
+	assert.Eventually(t, func() bool { return !cached(b, "k")() }, time.Second, time.Millisecond)
+	v, ok := a.Get("k")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+
+	removed, err := b.Remove("k")
+	assert.False(t, removed)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return !cached(a, "k")() }, time.Second, time.Millisecond)
+
+	a.Local().SetWithTags("x", 1, "t")
+	b.Local().SetWithTags("y", 2, "t")
+	n, err := a.InvalidateTag("t")
+	assert.Equal(t, 1, n)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return !cached(b, "y")() }, time.Second, time.Millisecond)
+
+	// a closed replica no longer hears of the others
+	assert.NoError(t, b.Close())
+	assert.NoError(t, b.Close())
+	b.Local().Set("z", 1)
+	assert.NoError(t, a.Set("z", 2))
+	assert.NoError(t, a.Close())
+	assert.True(t, cached(b, "z")())
+}
@@ -0,0 +1,134 @@
+package lfu
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// Verify checks the internal consistency of cache: that every item is in the
+// freq node of its frequency, the freq nodes are ordered and none is empty,
+// and the sizes add up. It returns an error describing the first
+// inconsistency found, or nil if there is none, which is always the case
+// unless cache has a bug. It takes the lock and walks every item, so it is
+// meant for debug builds and tests rather than hot paths.
+func (c *Cache) Verify() error {
+	c.lock()
+	defer c.unlock()
+
+	return c.checkInvariants()
+}
+
+// checkInvariants returns an error describing the first inconsistency found
+// between kv and freqList, or nil if there is none. The caller must hold the
+// lock.
+func (c *Cache) checkInvariants() error {
+	nodes := make(map[*list.Element]struct{}, c.freqList.Len())
+	items, prev := 0, 0
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*freqNode)
+		if node.freq <= prev {
+			return fmt.Errorf("freq node %d follows freq node %d", node.freq, prev)
+		}
+		if node.n == 0 {
+			return fmt.Errorf("freq node %d is empty", node.freq)
+		}
+		count := 0
+		var last *kvItem
+		for item := node.head; item != nil; item = item.next {
+			if item.prev != last {
+				return fmt.Errorf("item %q in freq node %d is linked to another previous item", item.k, node.freq)
+			}
+			if item.parent != e {
+				return fmt.Errorf("item %q in freq node %d has another parent", item.k, node.freq)
+			}
+			if c.kv[item.k] != item {
+				return fmt.Errorf("item %q in freq node %d is not in kv", item.k, node.freq)
+			}
+			last = item
+			count++
+		}
+		if count != node.n || last != node.tail {
+			return fmt.Errorf("freq node %d links %d items, counts %d", node.freq, count, node.n)
+		}
+		nodes[e] = placeholder
+		items += count
+		prev = node.freq
+	}
+
+	if items != len(c.kv) {
+		return fmt.Errorf("freq nodes hold %d items, kv holds %d", items, len(c.kv))
+	}
+
+	var bytes int64
+	protected, expiring, dirty := 0, 0, 0
+	for k, item := range c.kv {
+		if item.k != k {
+			return fmt.Errorf("item %q is stored under key %q", item.k, k)
+		}
+		if _, ok := nodes[item.parent]; !ok {
+			return fmt.Errorf("item %q has a parent not in freqList", k)
+		}
+		bytes += item.size
+		if item.protected {
+			protected++
+		}
+		if !item.expireAt.IsZero() {
+			expiring++
+		}
+		if item.dirty != 0 {
+			dirty++
+		}
+		for _, tag := range item.tags {
+			if _, ok := c.tags[tag][item]; !ok {
+				return fmt.Errorf("item %q is not indexed under its tag %q", k, tag)
+			}
+		}
+	}
+	if bytes != c.bytes {
+		return fmt.Errorf("items take %d bytes, cache counts %d", bytes, c.bytes)
+	}
+	if dirty != c.dirty {
+		return fmt.Errorf("%d items are dirty, cache counts %d", dirty, c.dirty)
+	}
+	if expiring != len(c.expiries) {
+		return fmt.Errorf("%d items expire, the expiry heap holds %d", expiring, len(c.expiries))
+	}
+	for i, item := range c.expiries {
+		if c.kv[item.k] != item || item.expiring != i+1 {
+			return fmt.Errorf("item %q in the expiry heap is not in cache at index %d", item.k, i)
+		}
+	}
+	for tag, items := range c.tags {
+		for item := range items {
+			if c.kv[item.k] != item {
+				return fmt.Errorf("item %q tagged %q is not in cache", item.k, tag)
+			}
+		}
+	}
+	if b := c.reads; b != nil {
+		published := 0
+		b.index.Range(func(k, _ interface{}) bool {
+			published++
+			return true
+		})
+		if published != len(c.kv) {
+			return fmt.Errorf("read index holds %d keys, kv holds %d", published, len(c.kv))
+		}
+	}
+	if s := c.segments; s != nil && protected != s.protected {
+		return fmt.Errorf("%d items are protected, cache counts %d", protected, s.protected)
+	}
+
+	if a := c.admission; a != nil {
+		if a.window.Len() > a.size {
+			return fmt.Errorf("admission window holds %d items, more than %d", a.window.Len(), a.size)
+		}
+		for e := a.window.Front(); e != nil; e = e.Next() {
+			item := e.Value.(*kvItem)
+			if c.kv[item.k] != item || item.windowed != e {
+				return fmt.Errorf("item %q in admission window is not in cache", item.k)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package lfu
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func FuzzCache(f *testing.F) {
+	f.Add([]byte{2, 0, 1, 0, 2, 1, 0, 1, 1, 2, 3, 0, 1})
+	f.Add([]byte{0, 0, 0, 0, 1, 0, 2, 0, 3, 1, 1, 1, 1, 2, 9})
+	f.Add([]byte{3, 0, 7, 1, 7, 1, 7, 0, 6, 3, 7, 2, 1, 0, 5})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		if len(ops) == 0 {
+			return
+		}
+
+		cache := New(int(ops[0] % 5))
+		for i := 1; i+1 < len(ops); i += 2 {
+			k := fmt.Sprintf("k%d", ops[i+1]%8)
+			switch ops[i] % 4 {
+			case 0:
+				cache.Set(k, i)
+			case 1:
+				cache.Get(k)
+			case 2:
+				cache.Evict(int(ops[i+1] % 4))
+			case 3:
+				cache.Remove(k)
+			}
+
+			if err := cache.checkInvariants(); err != nil {
+				t.Fatalf("after op %d: %v", i/2, err)
+			}
+			if cache.cap > 0 && cache.Size() > cache.cap {
+				t.Fatalf("after op %d: size %d exceeds cap %d", i/2, cache.Size(), cache.cap)
+			}
+		}
+	})
+}
+
+func TestCache_IncrementOrphaned(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+
+	// a lookup racing with a removal: the item is gone by the time it is
+	// counted
+	item := cache.kv["a"]
+	cache.Remove("a")
+	assert.NotPanics(t, func() { cache.increment(item) })
+	assert.NoError(t, cache.checkInvariants())
+	assert.Equal(t, 1, cache.Size())
+
+	// a removed item stored again under the same key is a different item
+	cache.Set("a", 3)
+	assert.NotPanics(t, func() { cache.increment(item) })
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	// an item whose node was dropped from under it
+	item = cache.kv["b"]
+	cache.freqList.Remove(item.parent)
+	assert.NotPanics(t, func() { cache.increment(item) })
+	assert.Nil(t, item.parent.Next())
+}
+
+func TestCache_Verify(t *testing.T) {
+	cache := New(0)
+	assert.NoError(t, cache.Verify())
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	assert.NoError(t, cache.Verify())
+
+	// out of order
+	cache.freqList.Back().Value.(*freqNode).freq = 1
+	assert.EqualError(t, cache.Verify(), "freq node 1 follows freq node 1")
+	cache.freqList.Back().Value.(*freqNode).freq = 2
+
+	// an item missing from its node
+	item := cache.kv["a"]
+	node := item.parent.Value.(*freqNode)
+	node.unlink(item)
+	assert.EqualError(t, cache.Verify(), "freq node 1 is empty")
+	node.push(item)
+
+	// a node miscounting its items
+	node.n++
+	assert.EqualError(t, cache.Verify(), "freq node 1 links 1 items, counts 2")
+	node.n--
+
+	// an item missing from kv
+	delete(cache.kv, "b")
+	assert.EqualError(t, cache.Verify(), `item "b" in freq node 2 is not in kv`)
+}
@@ -0,0 +1,66 @@
+package lfu
+
+import (
+	"sync"
+	"time"
+)
+
+// janitor runs work every interval of its clock, until stopped, e.g. to
+// remove the expired entries of cache.
+type janitor struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+func startJanitor(clock Clock, interval time.Duration, work func()) *janitor {
+	j := &janitor{stop: make(chan struct{})}
+	// the first wait starts before returning, so a test advancing a
+	// ManualClock right after New doesn't race the goroutine
+	tick := clock.After(interval)
+	go func() {
+		for {
+			select {
+			case <-tick:
+				work()
+				tick = clock.After(interval)
+			case <-j.stop:
+				return
+			}
+		}
+	}()
+	return j
+}
+
+// close stops j, if any. Closing it more than once is a no-op.
+func (j *janitor) close() {
+	if j != nil {
+		j.once.Do(func() { close(j.stop) })
+	}
+}
+
+// RemoveExpired removes every entry that outlived its TTL, leaving live ones
+// alone, and returns how many it removed. Expired entries are never returned
+// anyway, and are removed when looked up, so it only matters to free the
+// memory of those nobody looks up again, which Config.ExpirySweepInterval
+// does periodically. The entries with a TTL are kept ordered by expiry, so
+// it costs O(log n) per expired entry, and nothing for the live ones.
+func (c *Cache) RemoveExpired() int {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	n := 0
+	for len(c.expiries) > 0 && c.expired(c.expiries[0]) {
+		c.deleteItem(c.expiries[0], ReasonExpired)
+		n++
+	}
+	return n
+}
+
+// Close stops the goroutines started for Config.ExpirySweepInterval and
+// Config.HeapLimit, if any. Cache stays usable, with expired entries removed
+// when looked up. Calling Close more than once is a no-op.
+func (c *Cache) Close() {
+	c.janitor.close()
+	c.heapWatch.close()
+}
@@ -0,0 +1,101 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCache_RemoveExpired(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	cache.SetWithTTL("a", 1, time.Minute)
+	cache.SetWithTTL("b", 2, time.Hour)
+	cache.Set("c", 3)
+
+	assert.Equal(t, 0, cache.RemoveExpired())
+	clock.Advance(time.Minute)
+	assert.Equal(t, 1, cache.RemoveExpired())
+	assert.Equal(t, 2, cache.Size())
+	assert.Nil(t, cache.Verify())
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, 1, cache.RemoveExpired())
+	assert.Equal(t, []string{"c"}, cache.Keys())
+}
+
+func TestCache_ExpirySweep(t *testing.T) {
+	cache := New(0, WithExpirySweep(time.Millisecond))
+	defer cache.Close()
+
+	cache.SetWithTTL("a", 1, time.Millisecond)
+	cache.Set("b", 2)
+	assert.Eventually(t, func() bool { return cache.Size() == 1 }, time.Second, time.Millisecond)
+	_, ok := cache.Peek("b")
+	assert.True(t, ok)
+
+	cache.Close()
+	cache.Close()
+	// nothing sweeps anymore, but expired entries still aren't returned
+	cache.SetWithTTL("c", 3, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 2, cache.Size())
+	_, ok = cache.Get("c")
+	assert.False(t, ok)
+
+	// a cache without a sweep has nothing to close
+	New(0).Close()
+}
+
+func TestCache_ExpirySweepWithClock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithExpirySweep(time.Minute))
+	defer cache.Close()
+
+	cache.SetWithTTL("a", 1, time.Second)
+	cache.SetWithTTL("b", 2, 2*time.Minute)
+	assert.Equal(t, 1, clock.Waiters())
+
+	// a is expired, but not swept before the sweep interval
+	clock.Advance(time.Minute - time.Nanosecond)
+	assert.Equal(t, 2, cache.Size())
+
+	clock.Advance(time.Nanosecond)
+	assert.Eventually(t, func() bool { return cache.Size() == 1 }, time.Second, time.Millisecond)
+
+	// the next sweep waits another interval
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	clock.Advance(time.Minute)
+	assert.Eventually(t, func() bool { return cache.Size() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestCache_RemoveExpiredIndex(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	for i := 0; i < 10; i++ {
+		cache.SetWithTTL(strconv.Itoa(i), i, time.Duration(10-i)*time.Second)
+	}
+	cache.Set("live", 0)
+	// a new TTL moves 0 from last to expire to first, and 9 loses its TTL
+	cache.SetWithTTL("0", 0, time.Second/2)
+	cache.SetWithTTL("9", 9, 0)
+	cache.Remove("5")
+	assert.Nil(t, cache.Verify())
+
+	clock.Advance(time.Second / 2)
+	assert.Equal(t, 1, cache.RemoveExpired())
+	_, ok := cache.Peek("0")
+	assert.False(t, ok)
+
+	clock.Advance(3 * time.Second)
+	// 7 and 8 expired
+	assert.Equal(t, 2, cache.RemoveExpired())
+	assert.Equal(t, 7, cache.Size())
+	assert.Nil(t, cache.Verify())
+
+	clock.Advance(time.Hour)
+	assert.Equal(t, 5, cache.RemoveExpired())
+	assert.ElementsMatch(t, []string{"live", "9"}, cache.Keys())
+	assert.Nil(t, cache.Verify())
+}
@@ -0,0 +1,37 @@
+package lfu
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// keyLockStripes is the number of mutexes the keys of LockKey share.
+const keyLockStripes = 256
+
+// LockKey locks k, waiting until no other caller holds it, and returns the
+// function unlocking it, e.g. to recompute or refresh the value of k once
+// while other callers wait, without holding the lock of cache meanwhile. It
+// doesn't lock k against the other methods of cache, which may still store
+// or remove k; it only serializes the callers of LockKey and WithKeyLock.
+//
+// Keys share a fixed number of mutexes, so holding k may hold up an unrelated
+// key, and locking two keys at once may deadlock, even in a single goroutine:
+// lock one key at a time.
+func (c *Cache) LockKey(k string) (unlock func()) {
+	c.keyLocksOnce.Do(func() {
+		c.keyLocks = make([]sync.Mutex, keyLockStripes)
+	})
+
+	h := fnv.New32a()
+	h.Write([]byte(c.key(k)))
+	m := &c.keyLocks[h.Sum32()%keyLockStripes]
+	m.Lock()
+	return m.Unlock
+}
+
+// WithKeyLock calls fn holding LockKey(k), and unlocks k once fn returns or
+// panics.
+func (c *Cache) WithKeyLock(k string, fn func()) {
+	defer c.LockKey(k)()
+	fn()
+}
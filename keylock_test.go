@@ -0,0 +1,54 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCache_LockKey(t *testing.T) {
+	cache := New(0, WithKeyNormalizer(strings.ToLower))
+
+	var wg sync.WaitGroup
+	running, overlapped, calls := 0, false, 0
+	var mu sync.Mutex
+	for i := 0; i < 50; i++ {
+		k := "k"
+		if i%2 == 0 {
+			k = "K"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cache.WithKeyLock(k, func() {
+				mu.Lock()
+				running++
+				overlapped = overlapped || running > 1
+				mu.Unlock()
+
+				// the lock of cache is free meanwhile
+				v, _ := cache.Get("k")
+				n, _ := v.(int)
+				cache.Set("k", n+1)
+
+				mu.Lock()
+				running--
+				calls++
+				mu.Unlock()
+			})
+		}()
+	}
+	wg.Wait()
+	assert.False(t, overlapped)
+	assert.Equal(t, 50, calls)
+	v, _ := cache.Get("k")
+	assert.Equal(t, 50, v)
+
+	// a panicking fn leaves k unlocked
+	assert.Panics(t, func() {
+		cache.WithKeyLock("k", func() { panic("oops") })
+	})
+	unlock := cache.LockKey("K")
+	unlock()
+}
@@ -0,0 +1,80 @@
+package lfu
+
+// promotionQueue holds the hits of cache not yet counted in freqList, which
+// a goroutine started on demand applies in batches, so a hit costs an append
+// instead of splicing freqList. It is guarded by the lock of cache, which
+// the hits are queued under anyway.
+type promotionQueue struct {
+	items    []*kvItem
+	draining bool
+}
+
+func newPromotionQueue(buffer int) *promotionQueue {
+	return &promotionQueue{items: make([]*kvItem, 0, buffer)}
+}
+
+// promote counts a hit of item, right away or later with LazyPromotions.
+// The caller must hold the lock.
+func (c *Cache) promote(item *kvItem) {
+	q := c.promotions
+	if q == nil || c.frozen {
+		c.increment(item)
+		return
+	}
+
+	// an item queued already only counts one more hit
+	if item.queuedHits == 0 {
+		if len(q.items) == cap(q.items) {
+			// the drainer fell behind: catch up rather than dropping the hit
+			c.applyPromotions()
+		}
+		q.items = append(q.items, item)
+	}
+	item.queuedHits++
+
+	if !q.draining {
+		q.draining = true
+		go func() {
+			c.lock()
+			defer c.unlock()
+
+			c.applyPromotions()
+			q.draining = false
+		}()
+	}
+}
+
+// applyPromotions counts the queued hits in freqList, moving each item once
+// however many hits it had. Hits of items gone since, or queued before
+// cache got frozen, are dropped. The caller must hold the lock.
+func (c *Cache) applyPromotions() {
+	q := c.promotions
+	if q == nil {
+		return
+	}
+
+	for i, item := range q.items {
+		q.items[i] = nil
+		n := item.queuedHits
+		item.queuedHits = 0
+		switch {
+		case c.frozen || c.orphaned(item):
+		case n == 1:
+			c.increment(item)
+		default:
+			c.touch(item, n*c.weightOf(item))
+		}
+	}
+	q.items = q.items[:0]
+}
+
+// Flush applies the promotions queued with LazyPromotions and counts the
+// hits buffered by ReadBuffer, so frequencies account for every hit so far,
+// e.g. before Evict or Snapshot. It is a no-op without either.
+func (c *Cache) Flush() {
+	c.lock()
+	defer c.unlock()
+
+	c.drainReads()
+	c.applyPromotions()
+}
@@ -0,0 +1,53 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_LazyPromotions(t *testing.T) {
+	cache := New(0, WithLazyPromotions(2))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+
+	freq := func(k string) int {
+		cache.lock()
+		defer cache.unlock()
+		return cache.kv[k].parent.Value.(*freqNode).freq
+	}
+
+	// hits of a queued item add up, and a full queue is applied in place
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+	cache.Get("c")
+	cache.Flush()
+	assert.Equal(t, 4, freq("a"))
+	assert.Equal(t, 2, freq("b"))
+	assert.Equal(t, 2, freq("c"))
+	assert.Empty(t, cache.promotions.items)
+	assert.NoError(t, cache.Verify())
+
+	// applied without Flush eventually
+	cache.Get("b")
+	assert.Eventually(t, func() bool { return freq("b") == 3 }, time.Second, time.Millisecond)
+
+	// hits of items gone in the meantime are dropped
+	cache.Get("c")
+	cache.Remove("c")
+	cache.Set("c", 30)
+	cache.Flush()
+	assert.Equal(t, 1, freq("c"))
+	assert.NoError(t, cache.Verify())
+
+	// Flush is a no-op without lazy promotions
+	cache = New(0)
+	cache.Set("a", 1)
+	cache.Get("a")
+	assert.Equal(t, 2, freq("a"))
+	cache.Flush()
+	assert.Equal(t, 2, freq("a"))
+}
@@ -1,158 +1,1363 @@
 package lfu
 
 import (
+	"container/heap"
 	"container/list"
+	"math/rand"
+	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // LFU interface defines the operations that an lfu implementation should support
 type LFU interface {
+	// Set stores the given kv pair.
 	Set(k string, v interface{})
+	// Get returns the v related to k. The ok indicates whether it is found.
 	Get(k string) (v interface{}, ok bool)
+	// Evict evicts up to n least frequently used items, fewer if there
+	// aren't that many. Evict(0) and a negative n are no-ops, they never
+	// flush the cache.
 	Evict(n int)
+	// Size returns the number of items.
 	Size() int
+	// Remove deletes k, reporting whether it was there. Removing a key is
+	// not an eviction.
+	Remove(k string) bool
+	// Purge deletes every item.
+	Purge()
 }
 
+// Config holds the settings of a Cache created by NewWithConfig.
+type Config struct {
+	// Capacity is the maximum number of items in the cache. A non-positive
+	// Capacity means the cache won't do any eviction. Nothing is allocated
+	// up front for it: the cache grows as items are stored, so a Capacity
+	// as large as math.MaxInt only bounds the number of items.
+	Capacity int
+	// Freshness is the age after which GetWithFreshness reports a value as no
+	// longer fresh. Zero means values are always fresh.
+	Freshness time.Duration
+	// Clock is the source of time of cache, for its ages and for what runs
+	// periodically, like the ExpirySweepInterval. It defaults to the real
+	// time, and is meant for tests to control time without sleeping, e.g.
+	// with a ManualClock.
+	Clock Clock
+	// TrackMisses is the number of most missed keys reported by
+	// HottestMissedKeys. Zero disables miss tracking.
+	TrackMisses int
+	// EvictionComparator reports whether a should be evicted before b. It
+	// orders the candidates sharing the lowest frequency, e.g. to evict the
+	// costliest or oldest of them first. When nil, TiePolicy picks which of
+	// them goes first.
+	EvictionComparator func(a, b Entry) bool
+	// TiePolicy picks which of the candidates sharing the lowest frequency
+	// is evicted first, LRUTies when nil.
+	TiePolicy TiePolicy
+	// Sizer returns the size in bytes of a value. When set, cache keeps track
+	// of the total size of its values, see Bytes and TrimToMemory.
+	Sizer func(v interface{}) int64
+	// MaxBytes is the maximum total size of the values in cache, as measured
+	// by Sizer or given to SetWithCost. A non-positive MaxBytes, or values of
+	// unknown size, mean the cache won't evict by size. Storing a value never
+	// evicts the value itself, so a value larger than MaxBytes stays in
+	// cache, alone.
+	MaxBytes int64
+	// Metrics receives the hits, misses, evictions and size changes of cache.
+	// It defaults to NopMetrics.
+	Metrics MetricsSink
+	// CloneFunc, when set, copies the values returned by the Get methods, so
+	// callers can't corrupt a cached value by mutating what they got, e.g. a
+	// slice or a map. It runs under the lock on every hit, making lookups as
+	// slow as the copy, except for the hits served by a ReadBuffer: those
+	// call it without the lock, possibly for the same v at once, so it must
+	// be safe for concurrent use and only read v. When nil, callers share
+	// the cached value.
+	CloneFunc func(v interface{}) interface{}
+	// MaxConcurrentLoads is the maximum number of loaders GetOrLoad runs at
+	// once across all keys; the callers beyond it wait for a slot. A
+	// non-positive MaxConcurrentLoads means there is no limit.
+	MaxConcurrentLoads int
+	// KeyNormalizer, when set, maps every key passed to cache before it is
+	// used, e.g. strings.ToLower for case-insensitive keys, so keys
+	// normalizing alike refer to the same item. Keys reported by cache are
+	// the normalized ones. When nil, keys are used as they are.
+	KeyNormalizer func(k string) string
+	// RecycleBuffers, when set, keeps the []byte values of evicted items in a
+	// pool that GetBuffer draws from, to spare the allocations of caches
+	// churning through byte values. It is only safe when nobody references
+	// a value once it's evicted: neither callers of the Get methods, unless
+	// CloneFunc copies the values, nor other items sharing the buffer.
+	RecycleBuffers bool
+	// AccessRecorder, when set, is called with every lookup of the Get
+	// methods, e.g. to write a trace to replay against other capacities or
+	// policies. It runs on a goroutine of its own, outside the lock, and
+	// receives the lookups in order through a buffer of AccessBuffer events.
+	// When the recorder falls behind and the buffer is full, new lookups
+	// are dropped rather than slowing cache down, see DroppedAccesses.
+	AccessRecorder func(e AccessEvent)
+	// AccessBuffer is the number of lookups buffered for AccessRecorder. It
+	// defaults to 1024.
+	AccessBuffer int
+	// TTLJitter spreads the expiry of entries stored with a TTL, so entries
+	// stored together don't all expire, and get reloaded, at once: an entry
+	// stored with ttl expires at a random point of [ttl*(1-TTLJitter), ttl].
+	// It ranges from 0, the default, meaning no jitter, to 1.
+	TTLJitter float64
+	// DefaultTTL is the TTL of the entries stored without one, e.g. by Set,
+	// as if stored by SetWithTTL. Zero, the default, means they don't
+	// expire. An explicit TTL, even a non-positive one, takes precedence.
+	DefaultTTL time.Duration
+	// RandSource is the source of every randomized behavior of cache, e.g.
+	// TTLJitter. It defaults to a source seeded with the current time;
+	// tests, or a service being debugged, may set a seeded one, see
+	// WithSeed, to get the same outcome on every run. Features drawing
+	// random numbers must draw them from it.
+	RandSource rand.Source
+	// OnFull is what storing a new key in a full cache does. It defaults to
+	// EvictLFU.
+	OnFull FullPolicy
+	// HitRatioWindow is the number of latest lookups RecentHitRatio covers.
+	// It defaults to 1000.
+	HitRatioWindow int
+	// MaxKeyLength is the maximum length in bytes of a key, to guard against
+	// pathological keys bloating memory. Storing a longer key is rejected,
+	// see TrySet, unless TruncateLongKeys is set. Zero means no limit.
+	MaxKeyLength int
+	// TruncateLongKeys makes cache cut keys down to MaxKeyLength instead of
+	// rejecting them, for every method taking a key, so keys sharing their
+	// first MaxKeyLength bytes refer to the same item.
+	TruncateLongKeys bool
+	// ValueEncoder and ValueDecoder convert values to bytes and back for
+	// WriteTo and ReadFrom, which fail with ErrNoCodec without them.
+	ValueEncoder func(v interface{}) ([]byte, error)
+	ValueDecoder func(data []byte) (interface{}, error)
+	// FailureThreshold is the number of consecutive failed loads of a key
+	// after which GetOrLoad stops calling its loader for CooldownDuration,
+	// returning ErrCircuitOpen instead. Zero disables the circuit breaker.
+	FailureThreshold int
+	// CooldownDuration is how long GetOrLoad fails fast for a key past
+	// FailureThreshold before trying its loader again.
+	CooldownDuration time.Duration
+	// ValueEqual reports whether two values are the same, for SetIfChanged.
+	// When nil, values of comparable types are compared with ==, and other
+	// values, e.g. slices, always count as changed.
+	ValueEqual func(a, b interface{}) bool
+	// MaxTiers is the maximum number of distinct frequency counts, which the
+	// features walking the freq nodes, e.g. SnapshotTopN or GetFrequencyRank,
+	// cost grows with. Past it, cache merges the adjacent frequencies holding
+	// the fewest items into the lower one, trading exact frequency counts for
+	// approximate ones. Zero means no limit.
+	MaxTiers int
+	// LazyPromotions, when positive, makes the Get methods queue the hits
+	// they count, up to LazyPromotions of them, for a goroutine to apply to
+	// the frequencies in batches, instead of moving items between freq nodes
+	// under the lock of every Get. Frequencies are then eventually
+	// consistent: until the queue is applied, Evict may pick an item whose
+	// hits are still queued, and Snapshot and the like report frequencies
+	// short of them. Flush applies the queue right away. Zero means hits are
+	// counted as they happen.
+	LazyPromotions int
+	// ReadBuffer, when positive, lets Get find the values in cache without
+	// taking its lock, which Get then takes once every ReadBuffer hits or
+	// so, to count them in a batch, rather than for every hit, trading
+	// frequencies and Stats lagging behind the hits still buffered for the
+	// throughput of read-mostly workloads. Misses still take the lock. It
+	// costs a copy of the value and expiry of every key, replaced whenever
+	// they change. Flush counts the buffered hits right away. Zero means
+	// every Get takes the lock.
+	ReadBuffer int
+	// Strict makes cache panic, with an error wrapping ErrInvalidOperation,
+	// on calls that can only be bugs of the caller, rather than handling
+	// them leniently: evicting a negative number of items, setting a
+	// frequency below 1, touching by a negative delta, or storing a key
+	// longer than MaxKeyLength without TruncateLongKeys. It is meant for
+	// tests and debug builds, to catch such calls where they happen.
+	Strict bool
+	// FrequencyWindow and FrequencyBuckets, when both positive, make
+	// frequency counts age with time instead of growing forever: accesses
+	// are counted in buckets FrequencyWindow wide, and the frequency of an
+	// item is the sum of its counts of the latest FrequencyBuckets buckets,
+	// each weighted by how recent it is, from 1 for the current bucket down
+	// to 1/FrequencyBuckets for the oldest, so a key hot an hour ago falls
+	// below a key hot now. Items are scored again when an access or an
+	// eviction finds a new bucket started, and each keeps FrequencyBuckets
+	// counts.
+	FrequencyWindow  time.Duration
+	FrequencyBuckets int
+	// IncrementWeight, when set, returns the number of accesses an access of
+	// v, a hit or a Set of a key in cache, counts as, e.g. more for values
+	// costly to recompute, so they climb away from eviction faster. It runs
+	// under the lock on every access, and weights below 1 count as 1. When
+	// nil, every access counts as one.
+	IncrementWeight func(v interface{}) int
+	// InitialFrequency is the frequency count new keys start at, e.g. above
+	// 1 to give freshly stored keys a grace period before they become
+	// eviction candidates, for keys written before they are read. It
+	// defaults to 1. Keys stored with an explicit frequency, e.g. by
+	// SetWithFrequency or Restore, start at that one.
+	InitialFrequency int
+	// LockWaitObserver, when set, is called with how long every call that
+	// found cache locked by another waited for the lock, e.g. to feed a
+	// histogram telling whether the lock is a bottleneck, and the sharded
+	// variants would serve better. Calls that get the lock right away
+	// aren't reported. It is called holding the lock, so it must be quick
+	// and must not use cache.
+	LockWaitObserver func(wait time.Duration)
+	// Observability bounds the memory of the structures cache keeps about
+	// its own behavior, e.g. for HottestMissedKeys or AccessRecorder, see
+	// ObservabilityBytes. The zero value leaves them as configured above.
+	Observability ObservabilityConfig
+	// ExpirySweepInterval, when positive, starts a goroutine removing the
+	// expired entries every ExpirySweepInterval, see RemoveExpired, so
+	// entries nobody looks up again don't hold on to memory until they are
+	// evicted. Close stops it; until then, it keeps cache from being
+	// garbage collected. Expired entries are never returned either way.
+	ExpirySweepInterval time.Duration
+	// EvictionCallback, when set, is called with every item leaving cache
+	// and why, e.g. to close a file or a connection held by the value. It
+	// runs after the call that removed the item released the lock, so it
+	// may use cache. Items handed over to the caller, like those DrainTo
+	// sends, or values replaced by a Set, aren't reported. With
+	// RecycleBuffers, the callback must not keep a []byte value.
+	EvictionCallback func(k string, v interface{}, reason EvictReason)
+	// AdmissionWindow, when positive, makes cache follow the W-TinyLFU
+	// admission policy rather than always evicting for a new key, to keep
+	// keys that are used once and never again from pushing out popular
+	// ones: new keys enter a window of the latest AdmissionWindow stored
+	// ones, where they are evicted by recency, and when the oldest of them
+	// leaves the window of a full cache, it only replaces the least
+	// frequently used item out of the window if it was looked up more
+	// often, as estimated by a count-min sketch of every lookup, hit or
+	// miss. The sketch halves its counts every 10*Capacity lookups, so old
+	// popularity fades. It applies to keys stored one at a time, and
+	// requires a positive Capacity; a few percent of it is typical.
+	AdmissionWindow int
+	// FrequencyDecayInterval, when positive, halves the frequency count of
+	// every item every FrequencyDecayInterval, see DecayFrequencies, so keys
+	// that were hot last week don't keep winning over the keys hot now. It
+	// is applied lazily, by the first lookup or eviction once an interval
+	// passed, as measured by Clock, so an idle cache costs nothing. Unlike
+	// FrequencyWindow, it keeps a single count per item.
+	FrequencyDecayInterval time.Duration
+	// ProtectedRatio, when positive, splits cache into a probation and a
+	// protected segment, see SegmentSizes: new keys enter probation, and
+	// move to protected on their first hit. Eviction takes the least
+	// frequently used item on probation, and only takes a protected item
+	// when probation is empty, so a scan of keys read once can't push out
+	// keys read twice. ProtectedRatio is the share of Capacity protected
+	// holds at most, between 0 and 1: when it's full, its least frequently
+	// used item is demoted back to probation. It requires a positive
+	// Capacity to bound protected; 0.8 is typical.
+	ProtectedRatio float64
+	// RefreshAfter, when positive, is the age after which GetWithRefresh
+	// reloads a value in the background while still returning it, see
+	// SetRefreshAfter for a per-key one. RefreshWorkers bounds the number of
+	// refreshes running at once, and defaults to 1: a refresh due while all
+	// of them are busy is skipped, and tried again by the next lookup.
+	RefreshAfter   time.Duration
+	RefreshWorkers int
+	// HeapLimit, when positive, makes cache shrink under memory pressure
+	// rather than grow until the process runs out of memory: every
+	// HeapCheckInterval, one second by default, a goroutine stopped by
+	// Close checks the heap usage, and while it's above HeapLimit bytes,
+	// evicts the items of the lowest frequency count, see
+	// EvictLeastFrequent. HeapUsage returns the heap usage in bytes, e.g. a
+	// soft limit of the container; it defaults to the bytes of live and
+	// not yet swept heap objects, as read by runtime/metrics, in which case
+	// nothing more is evicted until a GC cycle ran since the last eviction,
+	// for the usage to reflect it.
+	HeapLimit         uint64
+	HeapCheckInterval time.Duration
+	HeapUsage         func() uint64
+	// TrackAccessTime makes cache record the time of the latest hit of every
+	// item, reported by GetEntry, at the cost of reading Clock on every hit.
+	TrackAccessTime bool
+	// Warmup, when set, is called by NewWithConfig before it returns, to
+	// store the kv pairs it passes to add like Set does, e.g. from a
+	// snapshot or a bootstrap query, so a service can report ready once its
+	// cache is warm. add must not be called after Warmup returns.
+	Warmup func(add func(k string, v interface{}))
+	// WriteBack writes the dirty entries stored by SetDirty that cache
+	// evicts to the store behind cache, under the lock of cache, before they
+	// are dropped; without it, they are dropped unwritten. Eviction picks
+	// clean entries first, so it only evicts a dirty one when every candidate
+	// is dirty. Dirty entries expiring are written too, those removed
+	// aren't. The entry is dropped even if WriteBack fails. MaxDirty, when
+	// positive, bounds the number of dirty entries, see SetDirty.
+	WriteBack func(k string, v interface{}) error
+	MaxDirty  int
+	// WriteThrough makes SetDirty call WriteBack right away, without the
+	// lock, and store the entry clean once it succeeded, e.g. to turn write
+	// back off while the store is being migrated.
+	WriteThrough bool
+	// EvictionBatch is the number of items a full cache evicts at once to
+	// make room for a new key, so eviction runs once every EvictionBatch
+	// inserts rather than on each of them. It defaults to 1.
+	EvictionBatch int
+	// Shards is the number of shards NewShardedChecked splits cache into.
+	// NewWithConfig, which makes a single Cache, ignores it, and NewChecked
+	// fails with it.
+	Shards int
+	// LowWatermark, when in (0, 1), is the fraction of Capacity a full cache
+	// evicts down to, new key included, e.g. 0.9 to evict a tenth of cache
+	// at once. With EvictionBatch too, the larger batch wins. Neither applies
+	// with AdmissionWindow, which admits the new keys one at a time.
+	LowWatermark float64
+}
+
+// FullPolicy is what storing a new key in a cache holding Capacity items
+// does. Updating a key already in cache always succeeds.
+type FullPolicy int
+
+const (
+	// EvictLFU evicts the least frequently used item to make room.
+	EvictLFU FullPolicy = iota
+	// RejectNew drops the new key instead, keeping the items in cache, like
+	// a bounded buffer. TrySet reports whether a key was stored.
+	RejectNew
+)
+
 // New create a new lfu-cache that support the LFU interface. The cap parameter
-// specifies the capacity of the LFU cache
-func New(cap int) LFU {
-	return &cache{
-		cap:      cap,
-		kv:       make(map[string]*kvItem),
-		freqList: list.New(),
+// specifies the capacity of the LFU cache, and opts tune the rest of its
+// Config, which is left at its defaults otherwise. Options are applied after
+// cap, so WithCapacity overrides it.
+func New(cap int, opts ...Option) *Cache {
+	return NewWithConfig(configOf(cap, opts))
+}
+
+// configOf returns the Config of capacity cap set by opts.
+func configOf(cap int, opts []Option) Config {
+	cfg := Config{Capacity: cap}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// NewWithConfig creates a new lfu-cache with the given configuration.
+func NewWithConfig(cfg Config) *Cache {
+	c := &Cache{
+		cap:         cfg.Capacity,
+		freshness:   cfg.Freshness,
+		evictBefore: cfg.EvictionComparator,
+		ties:        cfg.TiePolicy,
+		sizer:       cfg.Sizer,
+		maxBytes:    cfg.MaxBytes,
+		metrics:     cfg.Metrics,
+		cloneFunc:   cfg.CloneFunc,
+		normalize:   cfg.KeyNormalizer,
+		ttlJitter:   cfg.TTLJitter,
+		defTTL:      cfg.DefaultTTL,
+		onFull:      cfg.OnFull,
+		hitWindow:   bounded(cfg.HitRatioWindow, defaultRecentWindow, cfg.Observability.MaxHitRatioWindow),
+		maxKeyLen:   cfg.MaxKeyLength,
+		encode:      cfg.ValueEncoder,
+		decode:      cfg.ValueDecoder,
+		kv:          make(map[string]*kvItem),
+		freqList:    list.New(),
+	}
+	if cfg.Clock != nil {
+		c.clock = cfg.Clock.Now
+	}
+	if cfg.MaxConcurrentLoads > 0 {
+		c.loadSlots = make(chan struct{}, cfg.MaxConcurrentLoads)
+	}
+	c.truncateKeys = cfg.TruncateLongKeys
+	c.valueEqual = cfg.ValueEqual
+	c.maxTiers = cfg.MaxTiers
+	c.strict = cfg.Strict
+	c.weight = cfg.IncrementWeight
+	c.initFreq = cfg.InitialFrequency
+	c.lockWait = cfg.LockWaitObserver
+	c.onEvicted = cfg.EvictionCallback
+	if cfg.FrequencyWindow > 0 && cfg.FrequencyBuckets > 0 {
+		c.window = &frequencyWindow{width: cfg.FrequencyWindow, buckets: cfg.FrequencyBuckets}
+	}
+	if cfg.LazyPromotions > 0 {
+		c.promotions = newPromotionQueue(cfg.LazyPromotions)
+	}
+	if cfg.ReadBuffer > 0 {
+		c.reads = newReadBuffer(cfg.ReadBuffer)
+	}
+	c.failureThreshold, c.cooldown = cfg.FailureThreshold, cfg.CooldownDuration
+	c.refreshAfter, c.refreshWorkers = cfg.RefreshAfter, cfg.RefreshWorkers
+	c.stampHits = cfg.TrackAccessTime
+	c.writeBackFn, c.maxDirty = cfg.WriteBack, cfg.MaxDirty
+	c.writeThrough = cfg.WriteThrough
+	c.evictionBatch, c.lowWatermark = cfg.EvictionBatch, cfg.LowWatermark
+	if cfg.AdmissionWindow > 0 && cfg.Capacity > 0 {
+		c.admission = newAdmission(cfg.AdmissionWindow, cfg.Capacity)
+	}
+	if cfg.ProtectedRatio > 0 {
+		c.segments = &segments{ratio: cfg.ProtectedRatio}
+	}
+	if cfg.FrequencyDecayInterval > 0 {
+		c.decayEvery, c.decayedAt = cfg.FrequencyDecayInterval, c.now()
+	}
+	clock := cfg.Clock
+	if clock == nil {
+		clock = realClock{}
 	}
+	if cfg.ExpirySweepInterval > 0 {
+		c.janitor = startJanitor(clock, cfg.ExpirySweepInterval, func() { c.RemoveExpired() })
+	}
+	if cfg.HeapLimit > 0 {
+		c.heapWatch = startHeapWatch(c, clock, cfg.HeapLimit, cfg.HeapCheckInterval, cfg.HeapUsage)
+	}
+	if cfg.RandSource != nil {
+		c.rng = rand.New(cfg.RandSource)
+	}
+	if cfg.AccessRecorder != nil {
+		buffer := bounded(cfg.AccessBuffer, defaultAccessBuffer, cfg.Observability.MaxAccessBuffer)
+		c.accesses = newAccessLog(cfg.AccessRecorder, buffer)
+	}
+	if cfg.RecycleBuffers {
+		c.buffers = &sync.Pool{}
+	}
+	if size := bounded(cfg.TrackMisses, 0, cfg.Observability.MaxTrackedMisses); size > 0 {
+		obs := cfg.Observability
+		c.misses = newMissTracker(size, obs.SketchWidth, obs.SketchDepth)
+	}
+	if cfg.Warmup != nil {
+		cfg.Warmup(c.Set)
+	}
+	return c
 }
 
 var (
 	placeholder = struct{}{}
 )
 
-type cache struct {
-	sync.Mutex
+var _ LFU = (*Cache)(nil)
+
+// Cache is the LFU implementation returned by New and NewWithConfig.
+type Cache struct {
+	// hits, missed and evictions count the lookups that found their key,
+	// those that didn't, and the evictions, and size is the number of
+	// items as of the last unlock. They are written under the lock, but
+	// atomically, so Stats can read them without it. lockWaits and
+	// lockWaited count the calls that waited for the lock and how long,
+	// written by lock, and evictionRuns the calls of makeRoom that evicted.
+	// Being first makes them 64-bit aligned on 32-bit platforms too.
+	hits, missed, evictions uint64
+	lockWaits, evictionRuns uint64
+	size, lockWaited        int64
+
+	// mu guards everything else, taken by lock and released by unlock.
+	mu sync.Mutex
+
+	cap         int
+	freshness   time.Duration
+	clock       func() time.Time
+	evictBefore func(a, b Entry) bool
+	ties        TiePolicy
+	tied        []*kvItem
+	tiedInfo    []EntryInfo
+	sizer       func(v interface{}) int64
+	maxBytes    int64
+	bytes       int64
+	keyBytes    int64
+	kv          map[string]*kvItem
+	kvPeak      int
+	freqList    *list.List
+
+	frozen   bool
+	unfrozen *sync.Cond
+	thawed   chan struct{}
+
+	spaceFreed chan struct{}
+	keysStored chan struct{}
+
+	misses  *missTracker
+	evicted evictedFrequencies
+
+	recent    recentLookups
+	hitWindow int
+	adaptive  *capacityController
+	pressure  func() int
+
+	promoteHooks []promoteHook
+	reach        map[string][]reachTrigger
+	tags         map[string]map[*kvItem]struct{}
+	watchers     map[string]func(v interface{}, hit bool)
+	onEvicted    func(k string, v interface{}, reason EvictReason)
+	after        []func()
+
+	metrics MetricsSink
+	pending metricEvents
+
+	cloneFunc func(v interface{}) interface{}
+	normalize func(k string) string
+	buffers   *sync.Pool
+	accesses  *accessLog
+	ttlJitter float64
+	defTTL    time.Duration
+	onFull    FullPolicy
+	rng       *rand.Rand
+	loadSlots chan struct{}
+
+	maxKeyLen    int
+	truncateKeys bool
+
+	encode func(v interface{}) ([]byte, error)
+	decode func(data []byte) (interface{}, error)
 
-	cap      int
-	kv       map[string]*kvItem
-	freqList *list.List
+	valueEqual func(a, b interface{}) bool
+	maxTiers   int
+	promotions *promotionQueue
+	reads      *readBuffer
+	window     *frequencyWindow
+	weight     func(v interface{}) int
+	initFreq   int
+	lockWait   func(wait time.Duration)
+	strict     bool
+	janitor    *janitor
+	stampHits  bool
+	expiries   expiryHeap
+	heapWatch  *janitor
+	segments   *segments
+	admission  *admission
+	decayEvery time.Duration
+	decayedAt  time.Time
+
+	failureThreshold int
+	cooldown         time.Duration
+	breakers         map[string]*breaker
+	breakersSwept    int
+	loads            map[string]*loadCall
+	refreshAfter     time.Duration
+	refreshWorkers   int
+	refreshSlots     chan struct{}
+	// refreshes maps the keys being refreshed to their item, or to nil once
+	// the item got a new value meanwhile.
+	refreshes map[string]*kvItem
+
+	collecting bool
+	collected  []Entry
+
+	writeBackFn func(k string, v interface{}) error
+	maxDirty    int
+	dirty       int
+	dirtySeq    uint64
+	// writeThrough is Config.WriteThrough.
+	writeThrough bool
+
+	// keyLocks are the mutexes of LockKey, made on its first call.
+	keyLocks     []sync.Mutex
+	keyLocksOnce sync.Once
+
+	evictionBatch int
+	lowWatermark  float64
 }
 
 type kvItem struct {
-	k      string
-	v      interface{}
-	parent *list.Element
+	k         string
+	v         interface{}
+	parent    *list.Element
+	updatedAt time.Time
+	createdAt time.Time
+	pinned    bool
+	protected bool
+	size      int64
+	refresh   time.Duration
+	expireAt  time.Time
+	meta      map[string]string
+
+	queuedHits int
+	window     windowCounts
+	windowed   *list.Element
+	// expiring is 1 + the index of item in the expiry heap, or 0 if it
+	// doesn't expire.
+	expiring int
+	// accessedAt is the time of the latest hit, with TrackAccessTime.
+	accessedAt time.Time
+	// prev and next are the neighbours of item in the freq node of parent.
+	prev, next *kvItem
+	tags       []string
+	// dirty is the sequence number of the SetDirty that made item dirty, or
+	// 0 if it is clean.
+	dirty uint64
+}
+
+// entry returns a copy of item as an Entry.
+func (item *kvItem) entry() Entry {
+	return Entry{Key: item.k, Value: item.v, Freq: item.parent.Value.(*freqNode).freq, ExpiresAt: item.expireAt}
 }
 
+// freqNode holds the items of a frequency count in a list linked through the
+// items themselves, oldest first, so moving an item from node to node, as
+// every hit does, costs O(1) and allocates nothing.
 type freqNode struct {
-	freq  int
-	items map[*kvItem]interface{}
+	freq       int
+	head, tail *kvItem
+	n          int
+}
+
+// push appends item to node.
+func (node *freqNode) push(item *kvItem) {
+	item.prev, item.next = node.tail, nil
+	if node.tail == nil {
+		node.head = item
+	} else {
+		node.tail.next = item
+	}
+	node.tail = item
+	node.n++
+}
+
+// unlink removes item from node.
+func (node *freqNode) unlink(item *kvItem) {
+	if item.prev == nil {
+		node.head = item.next
+	} else {
+		item.prev.next = item.next
+	}
+	if item.next == nil {
+		node.tail = item.prev
+	} else {
+		item.next.prev = item.prev
+	}
+	item.prev, item.next = nil, nil
+	node.n--
+}
+
+// holds reports whether item, whose parent is node, is still linked into it.
+func (node *freqNode) holds(item *kvItem) bool {
+	return item.prev != nil || node.head == item
+}
+
+// mergeNodes moves the items of the freq node from to the end of the freq
+// node into, leaving from empty for the caller to remove from freqList.
+func mergeNodes(into, from *list.Element) {
+	intoNode, fromNode := into.Value.(*freqNode), from.Value.(*freqNode)
+	if fromNode.head == nil {
+		return
+	}
+	for item := fromNode.head; item != nil; item = item.next {
+		item.parent = into
+	}
+	if intoNode.tail == nil {
+		intoNode.head = fromNode.head
+	} else {
+		intoNode.tail.next = fromNode.head
+		fromNode.head.prev = intoNode.tail
+	}
+	intoNode.tail = fromNode.tail
+	intoNode.n += fromNode.n
+	fromNode.head, fromNode.tail, fromNode.n = nil, nil, 0
 }
 
 // Set stores the given kv pair. If the cache has seen k before, the corresponding
 // v will be updated and the frequency count be incremented. If the cache has never
 // seen k before and full, the least frequently used k,v will be evicted.
-func (c *cache) Set(k string, v interface{}) {
-	if c.cap > 0 && len(c.kv) >= c.cap {
-		c.Evict(1)
+//
+// The cache keeps one copy of each key: the string passed by the first Set of
+// k. Later Sets of k only look it up and never retain the caller's string, so
+// keys built per request (e.g. by concatenation) don't pile up in memory.
+func (c *Cache) Set(k string, v interface{}) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	c.set(c.key(k), v)
+}
+
+// SetReport works like Set, and additionally reports whether storing k evicted
+// another item and, if so, which key was evicted. Updating a key seen before
+// never evicts.
+func (c *Cache) SetReport(k string, v interface{}) (evicted bool, evictedKey string) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	if victim := c.set(c.key(k), v); victim != nil {
+		return true, victim.k
 	}
+	return
+}
 
-	c.Lock()
-	defer c.Unlock()
+// SetIfChanged works like Set, except that storing the value already stored
+// for k does nothing at all, so refreshing a key with an unchanged value
+// doesn't count as an access. It reports whether the kv pair was stored. Values
+// are compared with the configured ValueEqual.
+func (c *Cache) SetIfChanged(k string, v interface{}) bool {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
 
-	var item *kvItem
+	k = c.key(k)
+	item, ok := c.lookup(k)
+	if ok && c.equal(item.v, v) || !ok && c.rejects(k) {
+		return false
+	}
+	c.set(k, v)
+	return true
+}
 
-	if item, ok := c.kv[k]; ok {
-		item.v = v
+// equal reports whether a and b are the same value, as configured by
+// ValueEqual.
+func (c *Cache) equal(a, b interface{}) bool {
+	if c.valueEqual != nil {
+		return c.valueEqual(a, b)
+	}
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	return ta == tb && ta.Comparable() && a == b
+}
+
+// TrySet works like Set, and reports whether the kv pair was stored: it is
+// false when k is new and the cache is full and configured to RejectNew, or
+// when k is longer than MaxKeyLength.
+func (c *Cache) TrySet(k string, v interface{}) bool {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	if _, ok := c.lookup(k); !ok && c.rejects(k) {
+		return false
+	}
+	c.set(k, v)
+	return true
+}
+
+// set stores the kv pair and returns the item evicted to make room for it, if
+// any. The caller must hold the lock.
+func (c *Cache) set(k string, v interface{}) (evicted *kvItem) {
+	return c.setCost(k, v, measured)
+}
+
+// setCost works like set, with cost as the size of v, or measured for the
+// configured Sizer to measure it. The caller must hold the lock.
+func (c *Cache) setCost(k string, v interface{}, cost int64) (evicted *kvItem) {
+	item, ok := c.lookup(k)
+	if ok {
+		c.update(item, v, cost)
 		c.increment(item)
-		return
+	} else if c.rejects(k) {
+		return nil
+	} else {
+		evicted = c.makeRoom()
+		item = c.insert(k, v, c.initialFrequency(), cost)
 	}
 
-	front := c.freqList.Front()
-	if c.freqList.Len() == 0 || front.Value.(*freqNode).freq != 1 {
-		node := &freqNode{
-			freq:  1,
-			items: map[*kvItem]interface{}{},
-		}
+	if _, first := c.fitBytes(item); evicted == nil {
+		evicted = first
+	}
+	return
+}
 
-		c.freqList.PushFront(node)
+// update replaces the value of item, of the given cost as resize takes it.
+// The caller must hold the lock.
+func (c *Cache) update(item *kvItem, v interface{}, cost int64) {
+	item.v = v
+	item.updatedAt = c.now()
+	if _, ok := c.refreshes[item.k]; ok {
+		c.refreshes[item.k] = nil
+	}
+	c.setTTL(item, c.defTTL)
+	item.meta = nil
+	c.clean(item)
+	if item.tags != nil {
+		c.untag(item)
+	}
+	c.resize(item, cost)
+}
 
-		item = &kvItem{
-			k:      k,
-			v:      v,
-			parent: c.freqList.Front(),
+// rejects reports whether the new key k must be dropped, either for being
+// longer than MaxKeyLength, or the cache being full and configured to
+// RejectNew. The caller must hold the lock.
+func (c *Cache) rejects(k string) bool {
+	if c.longKey(k) {
+		return true
+	}
+	return c.onFull == RejectNew && c.cap > 0 && len(c.kv) >= c.cap
+}
+
+// longKey reports whether k is longer than MaxKeyLength, as a call that is
+// invalid in Strict mode. The caller must hold the lock.
+func (c *Cache) longKey(k string) bool {
+	if c.maxKeyLen > 0 && len(k) > c.maxKeyLen {
+		c.invalid("key of %d bytes exceeds MaxKeyLength %d", len(k), c.maxKeyLen)
+		return true
+	}
+	return false
+}
+
+// makeRoom evicts the least frequently used item if the cache is full, and
+// returns it, along with the next ones of the batch, if any. The caller must
+// hold the lock.
+func (c *Cache) makeRoom() *kvItem {
+	if c.cap <= 0 || len(c.kv) < c.cap {
+		return nil
+	}
+	if c.admission != nil {
+		return c.admit()
+	}
+
+	n := c.batchSize()
+	victim := c.victim(nil)
+	if victim == nil {
+		return nil
+	}
+	c.evictItem(victim, ReasonCapacity)
+	atomic.AddUint64(&c.evictionRuns, 1)
+	for ; n > 1; n-- {
+		next := c.victim(nil)
+		if next == nil {
+			break
 		}
+		c.evictItem(next, ReasonCapacity)
+	}
+	return victim
+}
 
-		node.items[item] = placeholder
-	} else {
-		item = &kvItem{
-			k:      k,
-			v:      v,
-			parent: front,
+// batchSize returns the number of items makeRoom evicts at once, as
+// configured by EvictionBatch and LowWatermark. The caller must hold the
+// lock.
+func (c *Cache) batchSize() int {
+	n := c.evictionBatch
+	if c.lowWatermark > 0 && c.lowWatermark < 1 {
+		if low := len(c.kv) - int(c.lowWatermark*float64(c.cap)) + 1; low > n {
+			n = low
 		}
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// initialFrequency returns the frequency count new keys start at.
+func (c *Cache) initialFrequency() int {
+	if c.initFreq > 1 {
+		return c.initFreq
+	}
+	return 1
+}
 
-		front.Value.(*freqNode).items[item] = placeholder
+// insert adds a new item holding the kv pair to the node of the given
+// frequency, of the given cost as resize takes it. The caller must hold the
+// lock and make sure k isn't in cache.
+func (c *Cache) insert(k string, v interface{}, freq int, cost int64) *kvItem {
+	now := c.now()
+	item := &kvItem{
+		k:         k,
+		v:         v,
+		parent:    c.nodeAt(freq),
+		updatedAt: now,
+		createdAt: now,
+	}
+	item.parent.Value.(*freqNode).push(item)
+	if c.window != nil {
+		c.window.reset(item, c.epoch(), freq)
 	}
 	c.kv[k] = item
-	return
+	c.keyBytes += int64(len(k))
+	if len(c.kv) > c.kvPeak {
+		c.kvPeak = len(c.kv)
+	}
+	if c.keysStored != nil {
+		close(c.keysStored)
+		c.keysStored = nil
+	}
+	c.setTTL(item, c.defTTL)
+	c.resize(item, cost)
+	if c.admission != nil {
+		c.enterWindow(item)
+	}
+	c.pending.resized = true
+	return item
 }
 
 // Get returns the v related to k. The ok indicates whether it is found in cache.
-func (c *cache) Get(k string) (vv interface{}, ok bool) {
-	c.Lock()
-	defer c.Unlock()
+//
+// Returning v doesn't box or copy the value: Set boxes it into an interface
+// once, and Get returns that interface, which is two words however large the
+// value is. What Get does allocate is a new freq node when k leaves a node
+// shared with other items for a frequency nobody else has; a k alone in its
+// node, like the hottest keys usually are, just has the node bumped.
+func (c *Cache) Get(k string) (vv interface{}, ok bool) {
+	if c.reads != nil {
+		if vv, ok = c.getBuffered(k); ok {
+			return
+		}
+	}
+
+	c.lock()
+	defer c.unlock()
+	k = c.key(k)
 
-	v, ok := c.kv[k]
+	v, ok := c.lookup(k)
 	if !ok {
+		c.miss(k)
 		return
 	}
 
-	vv = v.v
+	vv = c.clone(v.v)
 
-	c.increment(v)
+	c.hit(v)
 	return
 }
 
-// Evict evicts given number of items out of cache.
-func (c *cache) Evict(n int) {
-	c.Lock()
-	defer c.Unlock()
+// GetCopy works like Get, and returns the value as copied by copyFn rather
+// than the configured CloneFunc, e.g. to copy only the values of the reads
+// that go on to mutate them. copyFn runs under the lock, so it must be quick
+// and must not use cache. A nil copyFn makes it behave like Get.
+func (c *Cache) GetCopy(k string, copyFn func(v interface{}) interface{}) (vv interface{}, ok bool) {
+	c.lock()
+	defer c.unlock()
+	k = c.key(k)
 
-	if n <= 0 {
+	v, ok := c.lookup(k)
+	if !ok {
+		c.miss(k)
 		return
 	}
 
+	if copyFn != nil {
+		vv = copyFn(v.v)
+	} else {
+		vv = c.clone(v.v)
+	}
+
+	c.hit(v)
+	return
+}
+
+// Peek returns the v related to k like Get, without counting it as an access:
+// neither the frequency count of k nor the hit and miss counts change.
+func (c *Cache) Peek(k string) (v interface{}, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return nil, false
+	}
+	return c.clone(item.v), true
+}
+
+// Contains reports whether k is in cache, without counting it as an access.
+func (c *Cache) Contains(k string) bool {
+	c.lock()
+	defer c.unlock()
+
+	_, ok := c.lookup(c.key(k))
+	return ok
+}
+
+// GetOrDefault returns the v related to k, or def if k is not in cache. A miss
+// doesn't store def.
+func (c *Cache) GetOrDefault(k string, def interface{}) interface{} {
+	if v, ok := c.Get(k); ok {
+		return v
+	}
+	return def
+}
+
+// GetOrSet returns the v related to k if it is in cache, with loaded true.
+// Otherwise it stores the given v and returns it, with loaded false.
+func (c *Cache) GetOrSet(k string, v interface{}) (actual interface{}, loaded bool) {
+	actual, loaded, _ = c.GetOrSetReport(k, v)
+	return
+}
+
+// GetOrSetReport works like GetOrSet, and additionally reports whether
+// storing v evicted another item. evicted is always false when loaded.
+func (c *Cache) GetOrSetReport(k string, v interface{}) (actual interface{}, loaded bool, evicted bool) {
+	c.lock()
+	defer c.unlock()
+
+	return c.getOrSet(c.key(k), v)
+}
+
+// getOrSet works like GetOrSetReport. The caller must hold the lock.
+func (c *Cache) getOrSet(k string, v interface{}) (actual interface{}, loaded bool, evicted bool) {
+	if item, ok := c.lookup(k); ok {
+		c.hit(item)
+		return c.clone(item.v), true, false
+	}
+
+	c.miss(k)
+	c.waitWritable()
+	if item, ok := c.lookup(k); ok {
+		// stored by someone else while waiting for Unfreeze
+		c.hit(item)
+		return c.clone(item.v), true, false
+	}
+	return v, false, c.set(k, v) != nil
+}
+
+// GetWithFreshness works like Get, and additionally reports whether the value
+// was written within the configured Freshness. A value that is no longer fresh
+// is still returned and counted as an access, so the caller can serve it while
+// refreshing it in the background. Unlike a TTL, Freshness never removes a
+// value: an expired entry is a miss, a stale one is a hit with fresh false.
+func (c *Cache) GetWithFreshness(k string) (vv interface{}, fresh bool, ok bool) {
+	c.lock()
+	defer c.unlock()
+	k = c.key(k)
+
+	v, ok := c.lookup(k)
+	if !ok {
+		c.miss(k)
+		return
+	}
+
+	vv = c.clone(v.v)
+	fresh = c.freshness <= 0 || c.now().Sub(v.updatedAt) < c.freshness
+
+	c.hit(v)
+	return
+}
+
+// GetWithAge works like Get, and additionally returns the age of the value:
+// the time since it was stored by the latest Set-like call of k. Lookups don't
+// reset the age, so it tells how old the value is, not how long k was idle.
+func (c *Cache) GetWithAge(k string) (vv interface{}, age time.Duration, ok bool) {
+	c.lock()
+	defer c.unlock()
+	k = c.key(k)
+
+	v, ok := c.lookup(k)
+	if !ok {
+		c.miss(k)
+		return
+	}
+
+	vv = c.clone(v.v)
+	age = c.now().Sub(v.updatedAt)
+
+	c.hit(v)
+	return
+}
+
+// Evict evicts given number of items out of cache. A non-positive n evicts
+// nothing.
+func (c *Cache) Evict(n int) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	if n < 0 {
+		c.invalid("Evict of %d items", n)
+	}
+	c.evict(n, ReasonEvicted)
+}
+
+// EvictWithResult works like Evict, and returns the evicted entries, least
+// frequently used first, e.g. to write dirty values back to their store
+// before they are gone. Their values aren't recycled by RecycleBuffers.
+func (c *Cache) EvictWithResult(n int) []Entry {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	if n < 0 {
+		c.invalid("Evict of %d items", n)
+	}
+	c.collecting = true
+	c.evict(n, ReasonEvicted)
+	evicted := c.collected
+	c.collecting, c.collected = false, nil
+	return evicted
+}
+
+// evict removes up to n least frequently used items for the given reason and
+// returns how many were removed. The caller must hold the lock.
+func (c *Cache) evict(n int, reason EvictReason) int {
 	i := 0
 
-	for {
-		if i == n || c.freqList.Len() == 0 {
+	// every pass removes one item, so the loop ends after at most len(c.kv)
+	// passes however large n is.
+	for ; i < n; i++ {
+		victim := c.victim(nil)
+		if victim == nil {
 			break
 		}
+		c.evictItem(victim, reason)
+	}
+	return i
+}
+
+// victim returns the item to be evicted next, leaving out the items skip
+// returns true for. It returns nil if cache is empty or every item is pinned
+// or skipped. Clean items go before the dirty ones of SetDirty, and with
+// segments, items on probation go first.
+func (c *Cache) victim(skip func(item *kvItem) bool) *kvItem {
+	c.decayIfDue()
+	if c.window != nil {
+		c.age(c.epoch())
+	}
+	if c.dirty > 0 && c.dirty < len(c.kv) {
+		victim := c.segmentVictim(func(item *kvItem) bool {
+			return item.dirty != 0 || (skip != nil && skip(item))
+		})
+		if victim != nil {
+			return victim
+		}
+	}
+	return c.segmentVictim(skip)
+}
 
-		front := c.freqList.Front()
-		frontNode := front.Value.(*freqNode)
+// segmentVictim returns the victim among the items skip leaves, those on
+// probation first.
+func (c *Cache) segmentVictim(skip func(item *kvItem) bool) *kvItem {
+	if c.segments != nil && c.segments.protected > 0 && c.segments.protected < len(c.kv) {
+		victim := c.victimOf(func(item *kvItem) bool {
+			return item.protected || (skip != nil && skip(item))
+		})
+		if victim != nil {
+			return victim
+		}
+	}
+	return c.victimOf(skip)
+}
 
-		for item := range frontNode.items {
-			delete(c.kv, item.k)
-			delete(frontNode.items, item)
-			i += 1
-			if i == n {
-				break
+// victimOf returns the least frequently used item that isn't pinned nor
+// skipped, the EvictionComparator or TiePolicy breaking ties, or nil if there
+// is none.
+func (c *Cache) victimOf(skip func(item *kvItem) bool) *kvItem {
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		var victim *kvItem
+		for item := e.Value.(*freqNode).head; item != nil; item = item.next {
+			if item.pinned || (skip != nil && skip(item)) {
+				continue
+			}
+			switch {
+			case c.evictBefore != nil:
+				if victim == nil || c.evictBefore(item.entry(), victim.entry()) {
+					victim = item
+				}
+			case c.breaksTies():
+				c.tied = append(c.tied, item)
+			default:
+				return item
 			}
 		}
+		if len(c.tied) > 0 {
+			victim = c.tieBreak()
+		}
+		if victim != nil {
+			return victim
+		}
+	}
+	return nil
+}
+
+// evictItem removes item from cache as an eviction, recording its frequency.
+func (c *Cache) evictItem(item *kvItem, reason EvictReason) {
+	c.evicted.record(item.parent.Value.(*freqNode).freq)
+	atomic.AddUint64(&c.evictions, 1)
+	c.pending.evictions++
+	if c.collecting {
+		c.collected = append(c.collected, item.entry())
+	}
+	c.writeBack(item)
+	c.removeItem(item)
+	c.notifyEvicted(item, reason)
+	c.recycle(item)
+}
+
+// removeItem deletes item from kv and from its freq node, dropping the node
+// once it's empty.
+func (c *Cache) removeItem(item *kvItem) {
+	delete(c.kv, item.k)
+	delete(c.reach, item.k)
+	c.unpublish(item.k)
+	c.clean(item)
+	if item.tags != nil {
+		c.untag(item)
+	}
+	if item.windowed != nil {
+		c.leaveWindow(item)
+	}
+	if item.protected {
+		c.segments.protected--
+	}
+	if item.expiring != 0 {
+		heap.Remove(&c.expiries, item.expiring-1)
+	}
+	c.bytes -= item.size
+	c.keyBytes -= int64(len(item.k))
+	c.pending.resized = true
+
+	node := item.parent.Value.(*freqNode)
+	node.unlink(item)
+	if node.n == 0 {
+		c.freqList.Remove(item.parent)
+	}
+}
+
+// SetWithFrequency stores the given kv pair with the given frequency count,
+// e.g. to warm the cache with heat learned elsewhere. A freq below 1 is
+// treated as 1. If the cache has never seen k before and is full, the least
+// frequently used k,v will be evicted first.
+func (c *Cache) SetWithFrequency(k string, v interface{}, freq int) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	if freq < 1 {
+		c.invalid("SetWithFrequency of %q to frequency %d", k, freq)
+	}
+	c.setWithFrequency(c.key(k), v, freq)
+}
+
+// setWithFrequency stores the kv pair with the given frequency, and returns
+// its item, or nil if cache rejected k. The caller must hold the lock.
+func (c *Cache) setWithFrequency(k string, v interface{}, freq int) *kvItem {
+	if freq < 1 {
+		freq = 1
+	}
+
+	item, ok := c.lookup(k)
+	if ok {
+		c.update(item, v, measured)
+		c.setFrequency(item, freq)
+	} else if c.rejects(k) {
+		return nil
+	} else {
+		c.makeRoom()
+		item = c.insert(k, v, freq, measured)
+	}
+	c.fitBytes(item)
+	return item
+}
+
+// Resize changes the capacity of cache to cap, evicting least frequently used
+// items if it holds more. A non-positive cap means the cache won't do any
+// eviction.
+func (c *Cache) Resize(cap int) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	c.resizeCap(cap)
+}
+
+func (c *Cache) resizeCap(cap int) {
+	c.cap = cap
+	if cap > 0 && len(c.kv) > cap {
+		c.evict(len(c.kv)-cap, ReasonCapacity)
+	}
+}
+
+// Reset empties cache and sets its capacity to cap in one go, with the hit
+// and miss counts, the miss tracking and the evicted frequencies starting over,
+// e.g. to reuse cache between test cases. The configuration and hooks are
+// kept.
+func (c *Cache) Reset(cap int) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	for _, item := range c.kv {
+		c.notifyEvicted(item, ReasonDeleted)
+	}
+	c.kv = make(map[string]*kvItem)
+	c.unpublishAll()
+	c.kvPeak = 0
+	c.reach = nil
+	c.tags = nil
+	c.dirty = 0
+	c.breakers, c.breakersSwept = nil, 0
+	c.freqList = list.New()
+	c.expiries = nil
+	c.bytes, c.keyBytes = 0, 0
+	c.cap = cap
+
+	atomic.StoreUint64(&c.hits, 0)
+	atomic.StoreUint64(&c.missed, 0)
+	atomic.StoreUint64(&c.evictions, 0)
+	atomic.StoreUint64(&c.evictionRuns, 0)
+	atomic.StoreUint64(&c.lockWaits, 0)
+	atomic.StoreInt64(&c.lockWaited, 0)
+	c.recent = recentLookups{}
+	c.evicted = evictedFrequencies{}
+	if c.misses != nil {
+		c.misses.reset()
+	}
+	if a := c.admission; a != nil {
+		c.admission = newAdmission(a.size, cap)
+	}
+	if s := c.segments; s != nil {
+		s.protected = 0
+	}
+	if a := c.adaptive; a != nil {
+		a.hits, a.lookups = 0, 0
+		c.cap = clampCap(cap, a.minCap, a.maxCap)
+	}
+	c.pending.resized = true
+}
+
+// Remove deletes k from cache. It reports whether k was in cache.
+func (c *Cache) Remove(k string) bool {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	item, ok := c.kv[c.key(k)]
+	if !ok {
+		return false
+	}
+	c.deleteItem(item, ReasonDeleted)
+	return true
+}
 
-		if len(frontNode.items) == 0 {
-			c.freqList.Remove(front)
+// Purge deletes every item from cache, keeping its capacity and its counts,
+// unlike Reset. Items are reported to the EvictionCallback as deleted.
+func (c *Cache) Purge() {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	for _, item := range c.kv {
+		c.deleteItem(item, ReasonDeleted)
+	}
+}
+
+// RemoveByPrefix deletes every key starting with prefix from cache, e.g. to
+// invalidate all the keys under a hierarchical parent, and returns how many
+// it deleted. It scans every key, so it costs O(size of cache).
+func (c *Cache) RemoveByPrefix(prefix string) int {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	prefix = c.key(prefix)
+	n := 0
+	for k, item := range c.kv {
+		if strings.HasPrefix(k, prefix) {
+			c.deleteItem(item, ReasonDeleted)
+			n++
 		}
 	}
-	return
+	return n
 }
 
 // Size returns the number of items in cache
-func (c *cache) Size() int {
-	c.Lock()
-	defer c.Unlock()
+func (c *Cache) Size() int {
+	c.lock()
+	defer c.unlock()
 	return len(c.kv)
 }
 
-func (c *cache) increment(item *kvItem) {
+func (c *Cache) increment(item *kvItem) {
+	if c.frozen || c.orphaned(item) {
+		return
+	}
+	if w := c.weightOf(item); w > 1 || c.window != nil {
+		c.touch(item, w)
+		return
+	}
+
 	curr := item.parent
 	currNode := curr.Value.(*freqNode)
 
@@ -162,25 +1367,169 @@ func (c *cache) increment(item *kvItem) {
 		nextNode = next.Value.(*freqNode)
 	}
 
+	// item alone in its node with no node right above it: bumping the node
+	// in place keeps the list ordered and saves re-splicing it, which is
+	// what keeps happening to the hottest keys of skewed workloads.
+	if currNode.n == 1 && (next == nil || currNode.freq+1 != nextNode.freq) {
+		currNode.freq++
+		c.promoted(item, currNode.freq-1)
+		return
+	}
+
 	if next == nil || (currNode.freq+1 != nextNode.freq) {
-		node := &freqNode{
-			freq: currNode.freq + 1,
-			items: map[*kvItem]interface{}{
-				item: placeholder,
-			},
-		}
-		c.freqList.InsertAfter(node, curr)
-	} else {
-		nextNode.items[item] = placeholder
+		nextNode = newFreqNode(currNode.freq + 1)
+		c.freqList.InsertAfter(nextNode, curr)
 	}
 
+	// move kvItem from current freq node to the next one
+	currNode.unlink(item)
+	nextNode.push(item)
 	item.parent = curr.Next()
-
-	// remove kvItem from current freq node
-	delete(currNode.items, item)
-	if len(currNode.items) == 0 {
+	if currNode.n == 0 {
 		c.freqList.Remove(curr)
 	}
 
+	c.promoted(item, currNode.freq)
 	return
 }
+
+// orphaned reports whether item is no longer part of cache, e.g. because it
+// was removed after the caller looked it up, so splicing it back would
+// corrupt freqList. The caller must hold the lock.
+func (c *Cache) orphaned(item *kvItem) bool {
+	if c.kv[item.k] != item || item.parent == nil {
+		return true
+	}
+	// an element removed from its list has neither neighbour, like the only
+	// element of a list has, but that one is also the front
+	e := item.parent
+	if e.Prev() == nil && e.Next() == nil && c.freqList.Front() != e {
+		return true
+	}
+	return !e.Value.(*freqNode).holds(item)
+}
+
+// nodeAt returns the element of freqList holding freq, inserting a new node
+// at the right position if there is none.
+func (c *Cache) nodeAt(freq int) *list.Element {
+	// most items arrive at the front, and Restore inserts them in ascending
+	// order, which the back gets to without walking the list
+	if back := c.freqList.Back(); back != nil && back.Value.(*freqNode).freq <= freq {
+		return c.nodeFrom(back, freq)
+	}
+	return c.nodeFrom(c.freqList.Front(), freq)
+}
+
+// nodeFrom works like nodeAt, walking freqList from e, in whichever direction
+// freq is, so moving an item by a few frequencies costs a few steps.
+func (c *Cache) nodeFrom(e *list.Element, freq int) *list.Element {
+	if e == nil {
+		return c.freqList.PushBack(newFreqNode(freq))
+	}
+	for e.Value.(*freqNode).freq < freq {
+		if e = e.Next(); e == nil {
+			return c.freqList.PushBack(newFreqNode(freq))
+		}
+	}
+	for e.Value.(*freqNode).freq > freq {
+		prev := e.Prev()
+		if prev == nil || prev.Value.(*freqNode).freq < freq {
+			return c.freqList.InsertBefore(newFreqNode(freq), e)
+		}
+		e = prev
+	}
+	return e
+}
+
+// weightOf returns the number of accesses a hit of item counts as, see
+// IncrementWeight.
+func (c *Cache) weightOf(item *kvItem) int {
+	if c.weight == nil || negative(item.v) {
+		return 1
+	}
+	if w := c.weight(item.v); w > 1 {
+		return w
+	}
+	return 1
+}
+
+// moveTo moves item to the node holding freq.
+func (c *Cache) moveTo(item *kvItem, freq int) {
+	curr := item.parent
+	currNode := curr.Value.(*freqNode)
+	if currNode.freq == freq {
+		return
+	}
+
+	item.parent = c.nodeFrom(curr, freq)
+	currNode.unlink(item)
+	item.parent.Value.(*freqNode).push(item)
+
+	if currNode.n == 0 {
+		c.freqList.Remove(curr)
+	}
+
+	c.promoted(item, currNode.freq)
+}
+
+// unlock keeps the freq nodes within MaxTiers, wakes up WaitForSpace if there
+// is space, releases the lock, then runs the callbacks queued by the operation
+// that held it, so they are free to use cache.
+func (c *Cache) unlock() {
+	c.coalesce()
+	c.signalSpace()
+	after := c.after
+	c.after = nil
+	events, size := c.pending, len(c.kv)
+	c.pending = metricEvents{}
+	atomic.StoreInt64(&c.size, int64(size))
+	c.mu.Unlock()
+
+	if c.metrics != nil {
+		events.report(c.metrics, size)
+	}
+	for _, fn := range after {
+		fn()
+	}
+}
+
+// clone returns a copy of v made by the configured CloneFunc, or v itself
+// without one.
+func (c *Cache) clone(v interface{}) interface{} {
+	if c.cloneFunc == nil || negative(v) {
+		return v
+	}
+	return c.cloneFunc(v)
+}
+
+// key returns k as configured by KeyNormalizer and TruncateLongKeys.
+func (c *Cache) key(k string) string {
+	if c.normalize != nil {
+		k = c.normalize(k)
+	}
+	if c.truncateKeys && c.maxKeyLen > 0 && len(k) > c.maxKeyLen {
+		k = k[:c.maxKeyLen]
+	}
+	return k
+}
+
+// random returns the random number generator of cache, seeding one with the
+// current time if none was configured. The caller must hold the lock.
+func (c *Cache) random() *rand.Rand {
+	if c.rng == nil {
+		c.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return c.rng
+}
+
+// now returns the current time of the configured clock.
+func (c *Cache) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock()
+}
+
+func newFreqNode(freq int) *freqNode {
+	return &freqNode{freq: freq}
+}
@@ -1,9 +1,18 @@
 package lfu
 
 import (
+	"bytes"
 	"container/list"
 	"github.com/stretchr/testify/assert"
+	"math"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 func TestLFU(t *testing.T) {
@@ -41,7 +50,7 @@ func TestLFU(t *testing.T) {
 }
 
 func TestCache_Set(t *testing.T) {
-	cache := &cache{
+	cache := &Cache{
 		cap:      2,
 		kv:       make(map[string]*kvItem),
 		freqList: list.New(),
@@ -53,8 +62,8 @@ func TestCache_Set(t *testing.T) {
 	assert.Equal(t, 1, cache.freqList.Len())
 	frontNode := cache.freqList.Front().Value.(*freqNode)
 	assert.Equal(t, 1, frontNode.freq)
-	assert.Equal(t, 1, len(frontNode.items))
-	_, ok := frontNode.items[cache.kv["a"]]
+	assert.Equal(t, 1, frontNode.n)
+	ok := inNode(frontNode, cache.kv["a"])
 	assert.True(t, ok)
 
 	// set "a" again
@@ -63,8 +72,8 @@ func TestCache_Set(t *testing.T) {
 	assert.Equal(t, 1, cache.freqList.Len())
 	frontNode = cache.freqList.Front().Value.(*freqNode)
 	assert.Equal(t, 2, frontNode.freq)
-	assert.Equal(t, 1, len(frontNode.items))
-	_, ok = frontNode.items[cache.kv["a"]]
+	assert.Equal(t, 1, frontNode.n)
+	ok = inNode(frontNode, cache.kv["a"])
 	assert.True(t, ok)
 
 	// set "b"
@@ -73,13 +82,13 @@ func TestCache_Set(t *testing.T) {
 	assert.Equal(t, 2, cache.freqList.Len())
 	frontNode = cache.freqList.Front().Value.(*freqNode)
 	assert.Equal(t, 1, frontNode.freq)
-	assert.Equal(t, 1, len(frontNode.items))
-	_, ok = frontNode.items[cache.kv["b"]]
+	assert.Equal(t, 1, frontNode.n)
+	ok = inNode(frontNode, cache.kv["b"])
 	assert.True(t, ok)
 	nextNode := cache.freqList.Front().Next().Value.(*freqNode)
 	assert.Equal(t, 2, nextNode.freq)
-	assert.Equal(t, 1, len(nextNode.items))
-	_, ok = nextNode.items[cache.kv["a"]]
+	assert.Equal(t, 1, nextNode.n)
+	ok = inNode(nextNode, cache.kv["a"])
 	assert.True(t, ok)
 
 	// set "c" should evict "b"
@@ -88,13 +97,13 @@ func TestCache_Set(t *testing.T) {
 	assert.Equal(t, 2, cache.freqList.Len())
 	frontNode = cache.freqList.Front().Value.(*freqNode)
 	assert.Equal(t, 1, frontNode.freq)
-	assert.Equal(t, 1, len(frontNode.items))
-	_, ok = frontNode.items[cache.kv["c"]]
+	assert.Equal(t, 1, frontNode.n)
+	ok = inNode(frontNode, cache.kv["c"])
 	assert.True(t, ok)
 }
 
 func TestCache_Get(t *testing.T) {
-	cache := &cache{
+	cache := &Cache{
 		kv:       make(map[string]*kvItem),
 		freqList: list.New(),
 	}
@@ -114,13 +123,13 @@ func TestCache_Get(t *testing.T) {
 	frontNode := cache.freqList.Front().Value.(*freqNode)
 	nextNode := cache.freqList.Front().Next().Value.(*freqNode)
 	assert.Equal(t, 1, frontNode.freq)
-	assert.Equal(t, 1, len(frontNode.items))
+	assert.Equal(t, 1, frontNode.n)
 	assert.Equal(t, frontNode, cache.kv["b"].parent.Value.(*freqNode))
-	_, ok = frontNode.items[cache.kv["b"]]
+	ok = inNode(frontNode, cache.kv["b"])
 	assert.True(t, ok)
 	assert.Equal(t, 2, nextNode.freq)
-	assert.Equal(t, 1, len(nextNode.items))
-	_, ok = nextNode.items[cache.kv["a"]]
+	assert.Equal(t, 1, nextNode.n)
+	ok = inNode(nextNode, cache.kv["a"])
 	assert.True(t, ok)
 	assert.Equal(t, nextNode, cache.kv["a"].parent.Value.(*freqNode))
 
@@ -130,10 +139,10 @@ func TestCache_Get(t *testing.T) {
 	assert.Equal(t, 1, cache.freqList.Len())
 	frontNode = cache.freqList.Front().Value.(*freqNode)
 	assert.Equal(t, 2, frontNode.freq)
-	assert.Equal(t, 2, len(frontNode.items))
-	_, ok = frontNode.items[cache.kv["a"]]
+	assert.Equal(t, 2, frontNode.n)
+	ok = inNode(frontNode, cache.kv["a"])
 	assert.True(t, ok)
-	_, ok = frontNode.items[cache.kv["b"]]
+	ok = inNode(frontNode, cache.kv["b"])
 	assert.True(t, ok)
 	assert.Equal(t, frontNode, cache.kv["a"].parent.Value.(*freqNode))
 	assert.Equal(t, nextNode, cache.kv["b"].parent.Value.(*freqNode))
@@ -144,7 +153,7 @@ func TestCache_Get(t *testing.T) {
 }
 
 func TestCache_Evict(t *testing.T) {
-	cache := &cache{
+	cache := &Cache{
 		kv:       make(map[string]*kvItem),
 		freqList: list.New(),
 	}
@@ -171,7 +180,7 @@ func TestCache_Evict(t *testing.T) {
 	assert.Equal(t, 1, vv)
 	frontNode := cache.freqList.Front().Value.(*freqNode)
 	assert.Equal(t, 3, frontNode.freq)
-	assert.Equal(t, 1, len(frontNode.items))
+	assert.Equal(t, 1, frontNode.n)
 
 	cache.Evict(10)
 	assert.Equal(t, 0, len(cache.kv))
@@ -189,8 +198,29 @@ func TestCache_Evict(t *testing.T) {
 	assert.Equal(t, 0, cache.freqList.Len())
 }
 
+func TestCache_EvictWithResult(t *testing.T) {
+	cache := New(0, WithRecycleBuffers())
+	assert.Empty(t, cache.EvictWithResult(1))
+
+	cache.Set("a", []byte("1"))
+	cache.Set("b", []byte("2"))
+	cache.Set("c", []byte("3"))
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+
+	assert.Empty(t, cache.EvictWithResult(0))
+	assert.Equal(t, []Entry{
+		{Key: "c", Value: []byte("3"), Freq: 1},
+		{Key: "b", Value: []byte("2"), Freq: 2},
+	}, cache.EvictWithResult(2))
+	assert.Equal(t, []Entry{{Key: "a", Value: []byte("1"), Freq: 3}}, cache.EvictWithResult(10))
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, uint64(3), cache.Stats().Evictions)
+}
+
 func TestCache_Size(t *testing.T) {
-	cache := &cache{
+	cache := &Cache{
 		kv:       make(map[string]*kvItem),
 		freqList: list.New(),
 	}
@@ -206,3 +236,789 @@ func TestCache_Size(t *testing.T) {
 	cache.Evict(10)
 	assert.Equal(t, 0, cache.Size())
 }
+
+func TestCache_GetWithFreshness(t *testing.T) {
+	cache := NewWithConfig(Config{Capacity: 2, Freshness: time.Minute})
+
+	v, fresh, ok := cache.GetWithFreshness("a")
+	assert.False(t, ok)
+	assert.False(t, fresh)
+	assert.Nil(t, v)
+
+	cache.Set("a", 1)
+	v, fresh, ok = cache.GetWithFreshness("a")
+	assert.True(t, ok)
+	assert.True(t, fresh)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	cache.kv["a"].updatedAt = time.Now().Add(-time.Hour)
+	v, fresh, ok = cache.GetWithFreshness("a")
+	assert.True(t, ok)
+	assert.False(t, fresh)
+	assert.Equal(t, 1, v)
+
+	cache.Set("a", 2)
+	v, fresh, ok = cache.GetWithFreshness("a")
+	assert.True(t, ok)
+	assert.True(t, fresh)
+	assert.Equal(t, 2, v)
+
+	cache = New(2)
+	cache.Set("a", 1)
+	cache.kv["a"].updatedAt = time.Now().Add(-time.Hour)
+	_, fresh, ok = cache.GetWithFreshness("a")
+	assert.True(t, ok)
+	assert.True(t, fresh)
+}
+
+func TestCache_EvictHugeN(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Get("a")
+
+	done := make(chan struct{})
+	go func() {
+		cache.Evict(1 << 30)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Evict(1<<30) did not return")
+	}
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 0, cache.freqList.Len())
+
+	cache.Set("a", 1)
+	cache.Evict(-1 << 30)
+	assert.Equal(t, 1, cache.Size())
+	assert.Equal(t, 1, cache.freqList.Len())
+}
+
+func TestCache_SetReport(t *testing.T) {
+	cache := New(2)
+
+	evicted, evictedKey := cache.SetReport("a", 1)
+	assert.False(t, evicted)
+	assert.Equal(t, "", evictedKey)
+
+	evicted, evictedKey = cache.SetReport("b", 2)
+	assert.False(t, evicted)
+	assert.Equal(t, "", evictedKey)
+
+	// updating a seen key on a full cache evicts nothing
+	evicted, evictedKey = cache.SetReport("a", 3)
+	assert.False(t, evicted)
+	assert.Equal(t, "", evictedKey)
+	assert.Equal(t, 2, cache.Size())
+
+	evicted, evictedKey = cache.SetReport("c", 4)
+	assert.True(t, evicted)
+	assert.Equal(t, "b", evictedKey)
+	assert.Equal(t, 2, cache.Size())
+	_, ok := cache.kv["b"]
+	assert.False(t, ok)
+
+	unbounded := New(0)
+	for _, k := range []string{"a", "b", "c"} {
+		evicted, _ = unbounded.SetReport(k, 1)
+		assert.False(t, evicted)
+	}
+	assert.Equal(t, 3, unbounded.Size())
+}
+
+func TestCache_SetKeepsStoredKey(t *testing.T) {
+	cache := New(2)
+
+	first := strings.Repeat("k", 16)
+	cache.Set(first, 1)
+
+	second := strings.Repeat("k", 16)
+	assert.NotEqual(t, stringData(first), stringData(second))
+	cache.Set(second, 2)
+
+	item := cache.kv[first]
+	assert.Equal(t, 2, item.v)
+	assert.Equal(t, stringData(first), stringData(item.k))
+	for k := range cache.kv {
+		assert.Equal(t, stringData(first), stringData(k))
+	}
+}
+
+func stringData(s string) uintptr {
+	return (*reflect.StringHeader)(unsafe.Pointer(&s)).Data
+}
+
+func TestCache_Clock(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := NewWithConfig(Config{Capacity: 2, Freshness: time.Minute, Clock: clock})
+
+	cache.Set("a", 1)
+	assert.Equal(t, time.Unix(0, 0), cache.kv["a"].updatedAt)
+
+	clock.Advance(time.Minute - time.Nanosecond)
+	_, fresh, _ := cache.GetWithFreshness("a")
+	assert.True(t, fresh)
+
+	clock.Advance(time.Nanosecond)
+	_, fresh, _ = cache.GetWithFreshness("a")
+	assert.False(t, fresh)
+
+	cache.Set("a", 2)
+	assert.Equal(t, time.Unix(60, 0), cache.kv["a"].updatedAt)
+	_, fresh, _ = cache.GetWithFreshness("a")
+	assert.True(t, fresh)
+}
+
+func TestCache_GetOrDefault(t *testing.T) {
+	cache := New(2)
+
+	assert.Equal(t, "def", cache.GetOrDefault("a", "def"))
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 0, cache.freqList.Len())
+
+	cache.Set("a", 1)
+	assert.Equal(t, 1, cache.GetOrDefault("a", "def"))
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 1, cache.Size())
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	type large struct {
+		buf [1024]byte
+	}
+
+	for _, bc := range []struct {
+		name string
+		v    interface{}
+	}{
+		{"int", 42},
+		{"large", large{}},
+		{"pointer", &large{}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			cache := New(0)
+			cache.Set("a", bc.v)
+			cache.SetWithFrequency("b", bc.v, 1<<30)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cache.Get("b")
+			}
+		})
+	}
+}
+
+func TestCache_EvictionComparator(t *testing.T) {
+	cache := NewWithConfig(Config{
+		Capacity: 3,
+		EvictionComparator: func(a, b Entry) bool {
+			return a.Value.(int) > b.Value.(int)
+		},
+	})
+
+	cache.Set("a", 10)
+	cache.Set("b", 30)
+	cache.Set("c", 20)
+	cache.Get("b")
+
+	// "b" is the costliest, but only "a" and "c" share the lowest frequency
+	cache.Set("d", 5)
+	_, ok := cache.kv["c"]
+	assert.False(t, ok)
+
+	cache.Evict(1)
+	_, ok = cache.kv["a"]
+	assert.False(t, ok)
+
+	cache.SetMultiple(map[string]interface{}{"e": 1, "f": 2})
+	_, ok = cache.kv["d"]
+	assert.False(t, ok)
+	_, ok = cache.kv["b"]
+	assert.True(t, ok)
+	assert.Equal(t, 3, cache.Size())
+}
+
+func TestCache_Remove(t *testing.T) {
+	cache := New(2)
+	assert.False(t, cache.Remove("a"))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+
+	assert.True(t, cache.Remove("a"))
+	assert.False(t, cache.Remove("a"))
+	assert.Equal(t, 1, cache.Size())
+	assert.Equal(t, 1, cache.freqList.Len())
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+
+	assert.True(t, cache.Remove("b"))
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 0, cache.freqList.Len())
+}
+
+// inNode reports whether item is linked into node.
+func inNode(node *freqNode, item *kvItem) bool {
+	for x := node.head; x != nil; x = x.next {
+		if x == item {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCache_increment(t *testing.T) {
+	inList := func(l *list.List, e *list.Element) bool {
+		for x := l.Front(); x != nil; x = x.Next() {
+			if x == e {
+				return true
+			}
+		}
+		return false
+	}
+
+	cache := New(0)
+	cache.Set("a", 1)
+	item := cache.kv["a"]
+
+	// "a" is alone, so its node is replaced on every access
+	for freq := 2; freq <= 10; freq++ {
+		cache.Get("a")
+		assert.True(t, inList(cache.freqList, item.parent))
+		assert.Equal(t, freq, item.parent.Value.(*freqNode).freq)
+		assert.Equal(t, 1, cache.freqList.Len())
+		ok := inNode(item.parent.Value.(*freqNode), item)
+		assert.True(t, ok)
+	}
+
+	// "b" catches up with "a" and joins its node, leaving its own behind
+	cache.SetWithFrequency("b", 2, 9)
+	cache.Get("b")
+	assert.Equal(t, 1, cache.freqList.Len())
+	assert.Equal(t, item.parent, cache.kv["b"].parent)
+	assert.Equal(t, 2, item.parent.Value.(*freqNode).n)
+
+	cache.Get("a")
+	assert.True(t, inList(cache.freqList, item.parent))
+	assert.True(t, inList(cache.freqList, cache.kv["b"].parent))
+	assert.Equal(t, 11, item.parent.Value.(*freqNode).freq)
+	assert.Equal(t, 10, cache.kv["b"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 2, cache.freqList.Len())
+}
+
+func TestCache_Resize(t *testing.T) {
+	cache := New(4)
+	for i, k := range []string{"a", "b", "c", "d"} {
+		cache.SetWithFrequency(k, i, i+1)
+	}
+
+	cache.Resize(8)
+	assert.Equal(t, 8, cache.cap)
+	assert.Equal(t, 4, cache.Size())
+
+	cache.Resize(2)
+	assert.Equal(t, 2, cache.Size())
+	for _, k := range []string{"c", "d"} {
+		_, ok := cache.kv[k]
+		assert.True(t, ok)
+	}
+
+	cache.Resize(0)
+	for _, k := range []string{"e", "f", "g"} {
+		cache.Set(k, 0)
+	}
+	assert.Equal(t, 5, cache.Size())
+}
+
+func TestCache_CloneFunc(t *testing.T) {
+	cloneInts := func(v interface{}) interface{} {
+		return append([]int(nil), v.([]int)...)
+	}
+
+	shared := New(0)
+	shared.Set("a", []int{1, 2})
+	v, _ := shared.Get("a")
+	v.([]int)[0] = 9
+	assert.Equal(t, []int{9, 2}, shared.kv["a"].v)
+
+	cache := New(0, WithCloneFunc(cloneInts))
+	cache.Set("a", []int{1, 2})
+
+	v, _ = cache.Get("a")
+	v.([]int)[0] = 9
+	v, _, _ = cache.GetWithFreshness("a")
+	v.([]int)[1] = 9
+	found, _ := cache.GetBatch([]string{"a"})
+	found["a"].([]int)[0] = 8
+	cache.Batch(func(tx *Txn) {
+		v, _ := tx.Get("a")
+		v.([]int)[1] = 8
+	})
+	assert.Equal(t, []int{1, 2}, cache.kv["a"].v)
+	assert.Equal(t, []int{1, 2}, cache.GetOrDefault("a", nil))
+}
+
+func TestCache_EvictNonPositive(t *testing.T) {
+	cache := New(2)
+	for _, n := range []int{0, -5, 1, 10} {
+		assert.NotPanics(t, func() { cache.Evict(n) })
+		assert.Equal(t, 0, cache.Size())
+		assert.Equal(t, 0, cache.freqList.Len())
+	}
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	for _, n := range []int{0, -5} {
+		cache.Evict(n)
+		assert.Equal(t, 2, cache.Size())
+		assert.Equal(t, 2, cache.freqList.Len())
+		assert.Equal(t, 2, cache.kv["b"].parent.Value.(*freqNode).freq)
+	}
+}
+
+func TestCache_GetOrSetReport(t *testing.T) {
+	cache := New(2)
+
+	actual, loaded, evicted := cache.GetOrSetReport("a", 1)
+	assert.Equal(t, 1, actual)
+	assert.False(t, loaded)
+	assert.False(t, evicted)
+
+	actual, loaded, evicted = cache.GetOrSetReport("a", 2)
+	assert.Equal(t, 1, actual)
+	assert.True(t, loaded)
+	assert.False(t, evicted)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	actual, loaded = cache.GetOrSet("b", 3)
+	assert.Equal(t, 3, actual)
+	assert.False(t, loaded)
+
+	actual, loaded, evicted = cache.GetOrSetReport("c", 4)
+	assert.Equal(t, 4, actual)
+	assert.False(t, loaded)
+	assert.True(t, evicted)
+	_, ok := cache.kv["b"]
+	assert.False(t, ok)
+
+	// a full cache loading an existing key evicts nothing
+	actual, loaded, evicted = cache.GetOrSetReport("c", 5)
+	assert.Equal(t, 4, actual)
+	assert.True(t, loaded)
+	assert.False(t, evicted)
+	assert.Equal(t, 2, cache.Size())
+}
+
+func TestCache_KeyNormalizer(t *testing.T) {
+	cache := New(0, WithKeyNormalizer(func(k string) string {
+		return strings.ToLower(strings.TrimSpace(k))
+	}))
+
+	cache.Set("foo", 1)
+	v, ok := cache.Get("Foo")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	cache.Set(" FOO ", 2)
+	cache.SetMultiple(map[string]interface{}{"Bar": 3})
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, 2, cache.GetOrDefault("foo", nil))
+	assert.Equal(t, []string{"bar", "foo"}, cache.Keys())
+
+	assert.True(t, cache.Remove("BAR"))
+	assert.Equal(t, []string{"foo"}, cache.Keys())
+
+	// the identity by default
+	cache = New(0)
+	cache.Set("foo", 1)
+	_, ok = cache.Get("Foo")
+	assert.False(t, ok)
+}
+
+func TestCache_IncrementInPlace(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+
+	// b alone at the top: its node is bumped instead of replaced
+	node := cache.kv["b"].parent
+	cache.Get("b")
+	assert.Equal(t, node, cache.kv["b"].parent)
+	assert.Equal(t, 3, node.Value.(*freqNode).freq)
+
+	// a alone with a free frequency above it is bumped as well
+	node = cache.kv["a"].parent
+	cache.Get("a")
+	assert.Equal(t, node, cache.kv["a"].parent)
+	assert.Equal(t, 2, node.Value.(*freqNode).freq)
+
+	// moving next to b merges into b's node
+	cache.Get("a")
+	assert.Equal(t, cache.kv["b"].parent, cache.kv["a"].parent)
+	assert.Equal(t, 1, cache.freqList.Len())
+	assert.NoError(t, cache.checkInvariants())
+}
+
+func TestCache_GetWithAge(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+
+	_, _, ok := cache.GetWithAge("a")
+	assert.False(t, ok)
+
+	cache.Set("a", 1)
+	clock.Advance(time.Second)
+	cache.Get("a")
+	clock.Advance(time.Second)
+	v, age, ok := cache.GetWithAge("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2*time.Second, age)
+	assert.Equal(t, 3, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	cache.Set("a", 2)
+	clock.Advance(time.Second)
+	_, age, _ = cache.GetWithAge("a")
+	assert.Equal(t, time.Second, age)
+}
+
+func TestCache_RemoveByPrefix(t *testing.T) {
+	cache := New(0)
+	for _, k := range []string{"user:1", "user:1:profile", "user:12", "user:12:profile", "user:2"} {
+		cache.Set(k, k)
+	}
+	cache.Get("user:1")
+
+	assert.Equal(t, 2, cache.RemoveByPrefix("user:12"))
+	assert.Equal(t, 2, cache.RemoveByPrefix("user:1"))
+	assert.Equal(t, []string{"user:2"}, cache.Keys())
+	assert.Equal(t, 0, cache.RemoveByPrefix("user:1"))
+	assert.NoError(t, cache.checkInvariants())
+
+	assert.Equal(t, 1, cache.RemoveByPrefix(""))
+	assert.Equal(t, 0, cache.freqList.Len())
+}
+
+func TestCache_OnFull(t *testing.T) {
+	for _, tc := range []struct {
+		policy FullPolicy
+		stored bool
+		keys   []string
+	}{
+		{EvictLFU, true, []string{"c", "a"}},
+		{RejectNew, false, []string{"b", "a"}},
+	} {
+		cache := New(2, WithOnFull(tc.policy))
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.Get("a")
+
+		// updates succeed on a full cache either way
+		assert.True(t, cache.TrySet("b", 3))
+		assert.Equal(t, 3, cache.kv["b"].v)
+		cache.Get("a")
+
+		assert.Equal(t, tc.stored, cache.TrySet("c", 4))
+		assert.Equal(t, tc.keys, cache.Keys())
+
+		cache.Set("d", 5)
+		cache.SetWithFrequency("e", 6, 10)
+		cache.SetMultiple(map[string]interface{}{"f": 7})
+		assert.Equal(t, 2, cache.Size())
+		if tc.policy == RejectNew {
+			assert.Equal(t, tc.keys, cache.Keys())
+		}
+	}
+
+	cache := New(3, WithOnFull(RejectNew))
+	cache.Set("a", 1)
+	cache.SetMultiple(map[string]interface{}{"b": 2, "c": 3, "d": 4})
+	keys := cache.Keys()
+	sort.Strings(keys)
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+}
+
+func TestCache_MaxKeyLength(t *testing.T) {
+	long := strings.Repeat("k", 10)
+
+	cache := New(0, WithMaxKeyLength(8, false))
+	assert.False(t, cache.TrySet(long, 1))
+	cache.Set(long, 1)
+	cache.SetWithFrequency(long, 1, 3)
+	cache.SetMultiple(map[string]interface{}{long: 1, "short": 2})
+	_, ok := cache.Get(long)
+	assert.False(t, ok)
+	assert.Equal(t, []string{"short"}, cache.Keys())
+	assert.True(t, cache.TrySet(long[:8], 3))
+
+	cache = New(0, WithMaxKeyLength(8, true))
+	assert.True(t, cache.TrySet(long, 1))
+	v, ok := cache.Get(long)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	v, ok = cache.Get(long[:8])
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, []string{long[:8]}, cache.Keys())
+}
+
+func TestCache_NodeFrom(t *testing.T) {
+	cache := New(0)
+	for _, freq := range []int{2, 4, 6} {
+		cache.nodeAt(freq)
+	}
+	four := cache.freqList.Front().Next()
+
+	for _, freq := range []int{1, 2, 3, 4, 5, 6, 7} {
+		e := cache.nodeFrom(four, freq)
+		assert.Equal(t, freq, e.Value.(*freqNode).freq)
+	}
+	var freqs []int
+	for e := cache.freqList.Front(); e != nil; e = e.Next() {
+		freqs = append(freqs, e.Value.(*freqNode).freq)
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7}, freqs)
+}
+
+func TestCache_Reset(t *testing.T) {
+	cache := NewWithConfig(Config{Capacity: 2, TrackMisses: 2, Sizer: bytesSizer})
+	cache.Set("a", []byte("abc"))
+	cache.Set("b", []byte("de"))
+	cache.Set("c", []byte("f"))
+	cache.Get("c")
+	cache.Get("x")
+
+	cache.Reset(5)
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 5, cache.cap)
+	assert.Equal(t, 0, cache.freqList.Len())
+	assert.Equal(t, int64(0), cache.Bytes())
+	assert.Equal(t, int64(0), cache.EstimatedBytes())
+	assert.Equal(t, 0.0, cache.CurrentHitRatio())
+	assert.Equal(t, 0.0, cache.RecentHitRatio())
+	assert.Equal(t, uint64(0), cache.ColdMisses())
+	assert.Empty(t, cache.HottestMissedKeys(2))
+	assert.Equal(t, 0, cache.LastEvictedFrequency())
+	assert.Empty(t, cache.EvictedFrequencyHistogram())
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		cache.Set(k, []byte(k))
+	}
+	assert.Equal(t, 5, cache.Size())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_Purge(t *testing.T) {
+	cache := NewWithConfig(Config{Capacity: 2, Sizer: bytesSizer})
+	cache.Set("a", []byte("abc"))
+	cache.Set("b", []byte("de"))
+	cache.Get("a")
+	cache.Get("x")
+
+	cache.Purge()
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 2, cache.cap)
+	assert.Equal(t, 0, cache.freqList.Len())
+	assert.Equal(t, int64(0), cache.Bytes())
+	assert.Equal(t, 0.5, cache.CurrentHitRatio())
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+
+	for _, k := range []string{"a", "b", "c"} {
+		cache.Set(k, []byte(k))
+	}
+	assert.Equal(t, 2, cache.Size())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_GetCopy(t *testing.T) {
+	copyBytes := func(v interface{}) interface{} {
+		return append([]byte(nil), v.([]byte)...)
+	}
+	cache := New(0)
+	cache.Set("a", []byte("abc"))
+
+	_, ok := cache.GetCopy("b", copyBytes)
+	assert.False(t, ok)
+
+	v, ok := cache.GetCopy("a", copyBytes)
+	assert.True(t, ok)
+	v.([]byte)[0] = 'x'
+	assert.Equal(t, []byte("abc"), cache.kv["a"].v)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	// without copyFn, the value is shared
+	v, _ = cache.GetCopy("a", nil)
+	v.([]byte)[0] = 'x'
+	assert.Equal(t, []byte("xbc"), cache.kv["a"].v)
+}
+
+func TestCache_DroppedValueCollectable(t *testing.T) {
+	type large struct {
+		buf [1 << 16]byte
+	}
+
+	for _, tc := range []struct {
+		name string
+		drop func(cache *Cache)
+	}{
+		{"Evict", func(cache *Cache) { cache.Evict(1) }},
+		{"Set full", func(cache *Cache) { cache.Set("b", 1) }},
+		{"Set update", func(cache *Cache) { cache.Set("a", 1) }},
+		{"Remove", func(cache *Cache) { cache.Remove("a") }},
+		{"ResetFrequencies", func(cache *Cache) { cache.ResetFrequencies(); cache.Remove("a") }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cache := New(1)
+			collected := make(chan struct{})
+			func() {
+				v := &large{}
+				runtime.SetFinalizer(v, func(*large) { close(collected) })
+				cache.Set("a", v)
+				cache.Get("a")
+			}()
+
+			tc.drop(cache)
+			ok := false
+			for i := 0; i < 20 && !ok; i++ {
+				runtime.GC()
+				select {
+				case <-collected:
+					ok = true
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			assert.True(t, ok, "value still referenced")
+			runtime.KeepAlive(cache)
+		})
+	}
+}
+
+func TestCache_SetIfChanged(t *testing.T) {
+	cache := New(0)
+
+	// absent
+	assert.True(t, cache.SetIfChanged("a", 1))
+	assert.Equal(t, 1, cache.kv["a"].v)
+
+	// unchanged
+	assert.False(t, cache.SetIfChanged("a", 1))
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	// changed, including to a value of another type
+	assert.True(t, cache.SetIfChanged("a", 2))
+	assert.True(t, cache.SetIfChanged("a", "2"))
+	assert.Equal(t, 3, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	// values that can't be compared with == always change
+	cache.Set("b", []byte("x"))
+	assert.True(t, cache.SetIfChanged("b", []byte("x")))
+	assert.True(t, cache.SetIfChanged("b", nil))
+	assert.False(t, cache.SetIfChanged("b", nil))
+
+	cache = New(0, WithValueEqual(func(a, b interface{}) bool {
+		return bytes.Equal(a.([]byte), b.([]byte))
+	}))
+	cache.Set("b", []byte("x"))
+	assert.False(t, cache.SetIfChanged("b", []byte("x")))
+	assert.True(t, cache.SetIfChanged("b", []byte("y")))
+}
+
+func TestCache_IncrementWeight(t *testing.T) {
+	cache := New(2, WithIncrementWeight(func(v interface{}) int {
+		if v == "expensive" {
+			return 10
+		}
+		return 0
+	}))
+	cache.Set("a", "cheap")
+	cache.Set("b", "expensive")
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+	assert.Equal(t, 3, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 11, cache.kv["b"].parent.Value.(*freqNode).freq)
+
+	// without the weight, b would be the one evicted
+	cache.Set("c", "cheap")
+	assert.ElementsMatch(t, []string{"b", "c"}, cache.Keys())
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_InitialFrequency(t *testing.T) {
+	// without it, a fresh key is the first to go, even below a key read once
+	cache := New(0)
+	cache.SetWithFrequency("a", 1, 2)
+	cache.Set("b", 2)
+	cache.Evict(1)
+	assert.Equal(t, []string{"a"}, cache.Keys())
+
+	cache = New(0, WithInitialFrequency(3))
+	cache.SetWithFrequency("a", 1, 2)
+	cache.Set("b", 2)
+	assert.Equal(t, 3, cache.kv["b"].parent.Value.(*freqNode).freq)
+	cache.Evict(1)
+	assert.Equal(t, []string{"b"}, cache.Keys())
+
+	// new keys of a batch too, but not explicit frequencies
+	cache.SetMultiple(map[string]interface{}{"c": 3})
+	cache.SetWithFrequency("d", 4, 1)
+	assert.Equal(t, 3, cache.kv["c"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 1, cache.kv["d"].parent.Value.(*freqNode).freq)
+}
+
+func TestCache_EnormousCapacity(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	cache := New(math.MaxInt)
+	sharded := NewConsistentSharded(math.MaxInt, 3)
+	runtime.ReadMemStats(&after)
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+
+	assert.Equal(t, 0, cache.Size())
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i)
+		sharded.Set(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 100, cache.Size())
+	assert.Equal(t, 100, sharded.Size())
+	for _, shard := range sharded.shards {
+		assert.Equal(t, math.MaxInt/3+1, shard.cap)
+	}
+
+	// growing toward a target hit ratio stops at the maximum
+	cache = New(math.MaxInt - 1)
+	cache.SetTargetHitRatio(1, 1, math.MaxInt)
+	for i := 0; i < adaptWindow; i++ {
+		cache.Get("missing")
+	}
+	assert.Equal(t, math.MaxInt, cache.cap)
+}
+
+func TestCache_GetAllocs(t *testing.T) {
+	cache := New(0)
+	for i := 0; i < 100; i++ {
+		cache.Set(strconv.Itoa(i), i)
+	}
+	cache.Get("0")
+
+	// moving to a freq node that exists, or bumping a node alone, allocates
+	// nothing
+	i := 1
+	allocs := testing.AllocsPerRun(98, func() {
+		cache.Get(strconv.Itoa(i))
+		i++
+	})
+	assert.Equal(t, float64(0), allocs)
+	assert.Equal(t, 1, cache.FreqListLength())
+	assert.NoError(t, cache.Verify())
+}
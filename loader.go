@@ -0,0 +1,226 @@
+package lfu
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is returned by GetOrLoad instead of calling the loader of a
+// key whose loads failed FailureThreshold times in a row, until the
+// configured CooldownDuration has passed.
+var ErrCircuitOpen = errors.New("lfu: circuit open")
+
+// ErrLoaderPanicked is returned by GetOrLoad to the calls that waited for a
+// loader of another call which panicked. The call running the loader panics.
+var ErrLoaderPanicked = errors.New("lfu: loader panicked")
+
+// breaker counts the consecutive failed loads of a key, the latest of which
+// failed at failedAt.
+type breaker struct {
+	failures  int
+	failedAt  time.Time
+	openUntil time.Time
+	probing   bool
+}
+
+// minBreakerSweep is the number of breakers below which loadDone doesn't
+// bother sweeping the stale ones.
+const minBreakerSweep = 64
+
+// GetOrLoad returns the v related to k, calling loader to get it and storing
+// it on a miss. An error returned by loader is returned as is, and nothing is
+// stored. Concurrent calls missing the same k call loader once: the first
+// one runs it while the others wait, and all of them return what it
+// returned, error included. For a negative entry stored by StoreMiss, it
+// returns ErrNegativeCached without calling loader.
+//
+// With a FailureThreshold configured, once loader failed that many times in a
+// row for k, GetOrLoad fails fast with ErrCircuitOpen for CooldownDuration, sparing
+// the backend, then lets a single call try again: the circuit closes if it
+// succeeds, and stays open for another CooldownDuration if it fails. A
+// loader that panics counts as failing. The failures of a key are forgotten
+// once it went twice the CooldownDuration without any, so the counts of keys
+// no longer loaded don't pile up.
+func (c *Cache) GetOrLoad(k string, loader func(k string) (interface{}, error)) (interface{}, error) {
+	if v, ok := c.Get(k); ok {
+		return loaded(v)
+	}
+	return c.loadOnce(context.Background(), k, withoutContext(loader))
+}
+
+// withoutContext adapts a loader ignoring contexts to loadOnce.
+func withoutContext(loader func(k string) (interface{}, error)) func(ctx context.Context, k string) (interface{}, error) {
+	return func(_ context.Context, k string) (interface{}, error) {
+		return loader(k)
+	}
+}
+
+// loadCall is a load in flight, whose result the calls loading the same key
+// wait for instead of loading it again.
+type loadCall struct {
+	done chan struct{}
+	v    interface{}
+	err  error
+}
+
+// loadOnce loads k, unless a load of k is in flight already, in which case it
+// waits for its result, or until ctx is done.
+func (c *Cache) loadOnce(ctx context.Context, k string, loader func(ctx context.Context, k string) (interface{}, error)) (interface{}, error) {
+	c.lock()
+	key := c.key(k)
+	// a load may have finished since the caller missed
+	if item, ok := c.lookup(key); ok {
+		v := c.clone(item.v)
+		c.unlock()
+		return loaded(v)
+	}
+	if call, ok := c.loads[key]; ok {
+		c.unlock()
+		select {
+		case <-call.done:
+			return call.v, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	call := &loadCall{done: make(chan struct{}), err: ErrLoaderPanicked}
+	if c.loads == nil {
+		c.loads = make(map[string]*loadCall)
+	}
+	c.loads[key] = call
+	c.unlock()
+
+	defer func() {
+		c.lock()
+		delete(c.loads, key)
+		c.unlock()
+		close(call.done)
+	}()
+	call.v, call.err = c.load(ctx, k, loader)
+	return call.v, call.err
+}
+
+// load calls loader for k, within the configured MaxConcurrentLoads, and
+// stores what it returns. Waiting for a load slot ends when ctx is done.
+func (c *Cache) load(ctx context.Context, k string, loader func(ctx context.Context, k string) (interface{}, error)) (interface{}, error) {
+	if err := c.admitLoad(k); err != nil {
+		return nil, err
+	}
+	if c.loadSlots != nil {
+		select {
+		case c.loadSlots <- placeholder:
+		case <-ctx.Done():
+			c.loadAborted(k)
+			return nil, ctx.Err()
+		}
+		defer func() { <-c.loadSlots }()
+	}
+
+	v, err := c.callLoader(k, func() (interface{}, error) { return loader(ctx, k) })
+	if err != nil {
+		return nil, err
+	}
+	c.Set(k, v)
+	return v, nil
+}
+
+// callLoader calls load, the loader of k admitted by admitLoad, and records
+// its outcome, a panic being a failure, before the panic goes on.
+func (c *Cache) callLoader(k string, load func() (interface{}, error)) (v interface{}, err error) {
+	err = ErrLoaderPanicked
+	defer func() { c.loadDone(k, err) }()
+	return load()
+}
+
+// admitLoad returns ErrCircuitOpen if loading k must not be tried now.
+func (c *Cache) admitLoad(k string) error {
+	if c.failureThreshold <= 0 {
+		return nil
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	k = c.key(k)
+	b, ok := c.breakers[k]
+	if ok && c.stale(b) {
+		delete(c.breakers, k)
+		return nil
+	}
+	if !ok || b.failures < c.failureThreshold {
+		return nil
+	}
+	if b.probing || c.now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+	b.probing = true
+	return nil
+}
+
+// loadAborted lets another call probe the circuit of k, after admitLoad let
+// this one but it gave up before calling the loader.
+func (c *Cache) loadAborted(k string) {
+	if c.failureThreshold <= 0 {
+		return
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	if b, ok := c.breakers[c.key(k)]; ok {
+		b.probing = false
+	}
+}
+
+// loadDone records the outcome of loading k.
+func (c *Cache) loadDone(k string, err error) {
+	if c.failureThreshold <= 0 {
+		return
+	}
+
+	c.lock()
+	defer c.unlock()
+
+	k = c.key(k)
+	if err == nil {
+		delete(c.breakers, k)
+		return
+	}
+
+	b, ok := c.breakers[k]
+	if !ok {
+		if c.breakers == nil {
+			c.breakers = make(map[string]*breaker)
+		}
+		b = &breaker{}
+		c.breakers[k] = b
+	}
+	b.failures++
+	b.failedAt = c.now()
+	b.probing = false
+	if b.failures >= c.failureThreshold {
+		b.openUntil = b.failedAt.Add(c.cooldown)
+	}
+	if len(c.breakers) >= minBreakerSweep && len(c.breakers) >= 2*c.breakersSwept {
+		c.sweepBreakers()
+	}
+}
+
+// stale reports whether the failures b counts are old enough to forget. The
+// caller must hold the lock.
+func (c *Cache) stale(b *breaker) bool {
+	return !b.probing && c.now().Sub(b.failedAt) >= 2*c.cooldown
+}
+
+// sweepBreakers drops the stale breakers, once their number doubled since
+// the last sweep, so sweeping costs O(1) per failed load. The caller must
+// hold the lock.
+func (c *Cache) sweepBreakers() {
+	for k, b := range c.breakers {
+		if c.stale(b) {
+			delete(c.breakers, k)
+		}
+	}
+	c.breakersSwept = len(c.breakers)
+}
@@ -0,0 +1,246 @@
+package lfu
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_GetOrLoad(t *testing.T) {
+	cache := New(2)
+
+	loads := 0
+	loader := func(k string) (interface{}, error) {
+		loads++
+		if k == "bad" {
+			return nil, errors.New("boom")
+		}
+		return k + "!", nil
+	}
+
+	v, err := cache.GetOrLoad("a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "a!", v)
+	v, err = cache.GetOrLoad("a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "a!", v)
+	assert.Equal(t, 1, loads)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	v, err = cache.GetOrLoad("bad", loader)
+	assert.EqualError(t, err, "boom")
+	assert.Nil(t, v)
+	_, ok := cache.kv["bad"]
+	assert.False(t, ok)
+}
+
+func TestCache_GetOrLoadSingleFlight(t *testing.T) {
+	cache := New(0)
+
+	var loads int32
+	release := make(chan struct{})
+	loader := func(k string) (interface{}, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		if k == "bad" {
+			return nil, errors.New("boom")
+		}
+		return k + "!", nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		for _, k := range []string{"a", "bad"} {
+			wg.Add(1)
+			go func(k string) {
+				defer wg.Done()
+				v, err := cache.GetOrLoad(k, loader)
+				if k == "bad" {
+					assert.EqualError(t, err, "boom")
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, "a!", v)
+			}(k)
+		}
+	}
+	// let every call reach the load before it returns
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+	assert.Equal(t, 1, cache.Size())
+	assert.Empty(t, cache.loads)
+
+	// a failed load isn't shared with later calls
+	_, err := cache.GetOrLoad("bad", loader)
+	assert.EqualError(t, err, "boom")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&loads))
+}
+
+func TestCache_GetOrLoadPanic(t *testing.T) {
+	cache := New(0)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer func() { recover() }()
+		cache.GetOrLoad("a", func(k string) (interface{}, error) {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+
+	done := make(chan error)
+	go func() {
+		_, err := cache.GetOrLoad("a", func(k string) (interface{}, error) { return 1, nil })
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	assert.Equal(t, ErrLoaderPanicked, <-done)
+
+	v, err := cache.GetOrLoad("a", func(k string) (interface{}, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestCache_MaxConcurrentLoads(t *testing.T) {
+	cache := New(0, WithMaxConcurrentLoads(3))
+
+	var running, peak int32
+	loader := func(k string) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return k, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := fmt.Sprintf("k%d", i)
+			v, err := cache.GetOrLoad(k, loader)
+			assert.NoError(t, err)
+			assert.Equal(t, k, v)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&peak))
+	assert.Equal(t, 20, cache.Size())
+}
+
+func TestCache_GetOrLoadCircuitBreaker(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithCircuitBreaker(3, time.Minute))
+
+	down := errors.New("backend down")
+	calls := 0
+	fail := func(k string) (interface{}, error) {
+		calls++
+		return nil, down
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := cache.GetOrLoad("a", fail)
+		assert.Equal(t, down, err)
+	}
+	_, err := cache.GetOrLoad("a", fail)
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, 3, calls)
+
+	// other keys are unaffected
+	_, err = cache.GetOrLoad("b", fail)
+	assert.Equal(t, down, err)
+	assert.Equal(t, 4, calls)
+
+	// a single retry after the cooldown, which fails and reopens
+	clock.Advance(time.Minute)
+	_, err = cache.GetOrLoad("a", fail)
+	assert.Equal(t, down, err)
+	_, err = cache.GetOrLoad("a", fail)
+	assert.Equal(t, ErrCircuitOpen, err)
+	assert.Equal(t, 5, calls)
+
+	// a successful retry closes the circuit
+	clock.Advance(time.Minute)
+	v, err := cache.GetOrLoad("a", func(k string) (interface{}, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	cache.Remove("a")
+	_, err = cache.GetOrLoad("a", fail)
+	assert.Equal(t, down, err)
+	_, err = cache.GetOrLoad("a", fail)
+	assert.Equal(t, down, err)
+
+	// disabled by default
+	cache = New(0)
+	for i := 0; i < 10; i++ {
+		_, err = cache.GetOrLoad("a", fail)
+		assert.Equal(t, down, err)
+	}
+}
+
+func TestCache_CircuitBreakerProbePanic(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithCircuitBreaker(1, time.Minute))
+	down := errors.New("backend down")
+	fail := func(k string) (interface{}, error) { return nil, down }
+
+	_, err := cache.GetOrLoad("a", fail)
+	assert.Equal(t, down, err)
+	clock.Advance(time.Minute)
+	assert.Panics(t, func() {
+		cache.GetOrLoad("a", func(k string) (interface{}, error) { panic("boom") })
+	})
+
+	// the panic counts as a failure, and doesn't leave the circuit probing
+	_, err = cache.GetOrLoad("a", fail)
+	assert.Equal(t, ErrCircuitOpen, err)
+	clock.Advance(time.Minute)
+	v, err := cache.GetOrLoad("a", func(k string) (interface{}, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+}
+
+func TestCache_CircuitBreakerForgets(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithCircuitBreaker(2, time.Minute))
+	down := errors.New("backend down")
+	fail := func(k string) (interface{}, error) { return nil, down }
+
+	// a failure two cooldowns ago no longer counts
+	cache.GetOrLoad("a", fail)
+	clock.Advance(2 * time.Minute)
+	cache.GetOrLoad("a", fail)
+	_, err := cache.GetOrLoad("a", fail)
+	assert.Equal(t, down, err)
+	_, err = cache.GetOrLoad("a", fail)
+	assert.Equal(t, ErrCircuitOpen, err)
+
+	// the breakers of keys no longer loaded get swept
+	for i := 0; i < 1000; i++ {
+		cache.GetOrLoad(strconv.Itoa(i), fail)
+		clock.Advance(time.Second)
+	}
+	cache.lock()
+	n := len(cache.breakers)
+	cache.unlock()
+	assert.True(t, n <= 240, "%d breakers left", n)
+}
@@ -0,0 +1,113 @@
+package lfu
+
+import (
+	"container/list"
+	"sync"
+)
+
+// NewLRU creates a cache of the given capacity that evicts the least recently
+// used item rather than the least frequently used one, for workloads where
+// recency predicts reuse better than frequency, e.g. a key read a thousand
+// times yesterday and never since. A non-positive cap means the cache won't
+// do any eviction.
+//
+// It keeps its items in a container/list ordered by recency, moving an item
+// to the front on Set and Get, so the item at the back goes first, with no
+// count of how often each was used. Evict evicts the least recently used
+// items the same way.
+func NewLRU(cap int) LFU {
+	return &lru{cap: cap, kv: make(map[string]*list.Element), order: list.New()}
+}
+
+type lru struct {
+	sync.Mutex
+
+	cap   int
+	kv    map[string]*list.Element
+	order *list.List
+}
+
+type lruItem struct {
+	k string
+	v interface{}
+}
+
+// Set stores the given kv pair as the most recently used, evicting the least
+// recently used item if k is new and the cache is full.
+func (c *lru) Set(k string, v interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	if e, ok := c.kv[k]; ok {
+		e.Value.(*lruItem).v = v
+		c.order.MoveToFront(e)
+		return
+	}
+
+	if c.cap > 0 && len(c.kv) >= c.cap {
+		c.evict(1)
+	}
+	c.kv[k] = c.order.PushFront(&lruItem{k: k, v: v})
+}
+
+// Get returns the v related to k, making it the most recently used.
+func (c *lru) Get(k string) (v interface{}, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.kv[k]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruItem).v, true
+}
+
+// Evict evicts up to n least recently used items.
+func (c *lru) Evict(n int) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.evict(n)
+}
+
+func (c *lru) evict(n int) {
+	for i := 0; i < n; i++ {
+		e := c.order.Back()
+		if e == nil {
+			return
+		}
+		c.order.Remove(e)
+		delete(c.kv, e.Value.(*lruItem).k)
+	}
+}
+
+// Size returns the number of items.
+func (c *lru) Size() int {
+	c.Lock()
+	defer c.Unlock()
+
+	return len(c.kv)
+}
+
+// Remove deletes k from the cache.
+func (c *lru) Remove(k string) bool {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.kv[k]
+	if ok {
+		c.order.Remove(e)
+		delete(c.kv, k)
+	}
+	return ok
+}
+
+// Purge deletes every item.
+func (c *lru) Purge() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.kv = make(map[string]*list.Element)
+	c.order.Init()
+}
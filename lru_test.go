@@ -0,0 +1,71 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLRU(t *testing.T) {
+	lfu, lru := New(2), NewLRU(2)
+	for _, c := range []LFU{lfu, lru} {
+		// a is used often, but b more recently
+		c.Set("a", 1)
+		c.Get("a")
+		c.Get("a")
+		c.Set("b", 2)
+		c.Set("c", 3)
+	}
+
+	// LFU keeps the frequent a, LRU the recent b
+	_, ok := lfu.Get("a")
+	assert.True(t, ok)
+	_, ok = lfu.Get("b")
+	assert.False(t, ok)
+	_, ok = lru.Get("a")
+	assert.False(t, ok)
+	v, ok := lru.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+
+	// Get and Set both refresh recency: b is now the most recent, then c
+	lru.Set("c", 30)
+	lru.Get("b")
+	lru.Set("d", 4)
+	_, ok = lru.Get("c")
+	assert.False(t, ok)
+	assert.Equal(t, 2, lru.Size())
+
+	lru.Evict(1)
+	_, ok = lru.Get("d")
+	assert.True(t, ok)
+	_, ok = lru.Get("b")
+	assert.False(t, ok)
+	lru.Evict(5)
+	assert.Equal(t, 0, lru.Size())
+
+	// no capacity, no eviction
+	lru = NewLRU(0)
+	for _, k := range []string{"a", "b", "c"} {
+		lru.Set(k, k)
+	}
+	assert.Equal(t, 3, lru.Size())
+}
+
+func TestLRU_RemovePurge(t *testing.T) {
+	lru := NewLRU(2)
+	lru.Set("a", 1)
+	lru.Set("b", 2)
+
+	assert.True(t, lru.Remove("a"))
+	assert.False(t, lru.Remove("a"))
+	lru.Set("c", 3)
+	assert.Equal(t, 2, lru.Size())
+	_, ok := lru.Get("b")
+	assert.True(t, ok)
+
+	lru.Purge()
+	assert.Equal(t, 0, lru.Size())
+	lru.Set("d", 4)
+	lru.Evict(1)
+	assert.Equal(t, 0, lru.Size())
+}
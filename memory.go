@@ -0,0 +1,153 @@
+package lfu
+
+// measured is the cost to give resize for the configured Sizer to measure
+// the value.
+const measured = -1
+
+// resize sets the size of item to cost and updates the total size of cache.
+// A cost of measured has the value measured with the configured Sizer, or
+// the size left as is without one. The caller must hold the lock.
+func (c *Cache) resize(item *kvItem, cost int64) {
+	if cost == measured {
+		if c.sizer == nil {
+			return
+		}
+		cost = 0
+		if !negative(item.v) {
+			cost = c.sizer(item.v)
+		}
+	}
+
+	c.bytes += cost - item.size
+	item.size = cost
+}
+
+// fitBytes evicts least frequently used items other than keep until the
+// values fit in MaxBytes. It returns the number of items evicted and the
+// first of them. The caller must hold the lock.
+func (c *Cache) fitBytes(keep *kvItem) (int, *kvItem) {
+	if c.maxBytes <= 0 || c.bytes <= c.maxBytes {
+		return 0, nil
+	}
+
+	return c.trimBytes(c.maxBytes, func(item *kvItem) bool {
+		return item == keep
+	}, ReasonCapacity)
+}
+
+// trimBytes evicts least frequently used items, leaving out the items skip
+// returns true for, until the values fit in maxBytes. It returns the number
+// of items evicted and the first of them. The caller must hold the lock.
+func (c *Cache) trimBytes(maxBytes int64, skip func(item *kvItem) bool, reason EvictReason) (n int, first *kvItem) {
+	if maxBytes < 0 {
+		return
+	}
+
+	for c.bytes > maxBytes {
+		victim := c.victim(skip)
+		if victim == nil {
+			return
+		}
+		c.evictItem(victim, reason)
+		if first == nil {
+			first = victim
+		}
+		n++
+	}
+	return
+}
+
+// NewWithMaxBytes creates a cache bounded by the total size of its values
+// rather than their number: it holds any number of items, and evicts least
+// frequently used ones once their sizes add up to more than maxBytes, e.g.
+// for values ranging from bytes to megabytes. Sizes are given to SetWithCost,
+// or measured by a Sizer set with WithSizer for the other Set methods.
+func NewWithMaxBytes(maxBytes int64, opts ...Option) *Cache {
+	return New(0, append([]Option{WithMaxBytes(maxBytes)}, opts...)...)
+}
+
+// SetWithCost works like Set, with cost as the size of v instead of what the
+// configured Sizer measures, e.g. when the caller knows the size already or
+// v has no size a Sizer could tell, and evicts least frequently used items
+// other than k until the values fit in MaxBytes. The Sizer isn't called for
+// v. A negative cost counts as zero. A later Set of k measures the new value
+// with the Sizer, or keeps the cost without one.
+func (c *Cache) SetWithCost(k string, v interface{}, cost int64) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	if cost < 0 {
+		cost = 0
+	}
+	c.setCost(c.key(k), v, cost)
+}
+
+// Bytes returns the total size of the values in cache, as measured by the
+// configured Sizer or given to SetWithCost. It is always zero without either.
+func (c *Cache) Bytes() int64 {
+	c.lock()
+	defer c.unlock()
+
+	return c.bytes
+}
+
+// entryOverhead is roughly what cache spends on each item besides its key
+// and value: the item itself, and its entries in the key map and freq node.
+const entryOverhead = 192
+
+// EstimatedBytes returns a rough estimate of the memory cache takes: the keys,
+// a fixed overhead per item, and the values as measured by the configured
+// Sizer, if any. It ignores the freq nodes and what the maps keep allocated
+// after shrinking, so it is meant for dashboards rather than accounting.
+func (c *Cache) EstimatedBytes() int64 {
+	c.lock()
+	defer c.unlock()
+
+	return c.keyBytes + int64(len(c.kv))*entryOverhead + c.bytes
+}
+
+// TrimToMemory evicts least frequently used items until the values in cache
+// take at most maxBytes, as measured by the configured Sizer or given to
+// SetWithCost, e.g. to shed memory when the process is under pressure. It
+// returns the number of items evicted, and does nothing without sizes.
+// Pinned items are never evicted, so cache may be left above maxBytes.
+func (c *Cache) TrimToMemory(maxBytes int64) int {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	n, _ := c.trimBytes(maxBytes, nil, ReasonEvicted)
+	return n
+}
+
+// SetPressureFunc sets the function EvictUnderPressure asks how many items to
+// evict, e.g. a monitor of the memory of the system. A nil fn makes
+// EvictUnderPressure a no-op.
+func (c *Cache) SetPressureFunc(fn func() int) {
+	c.lock()
+	defer c.unlock()
+
+	c.pressure = fn
+}
+
+// EvictUnderPressure evicts as many least frequently used items as the
+// function set by SetPressureFunc returns, which is called outside the lock.
+// It returns the number of items evicted, which is less than asked for when
+// cache runs out of items that aren't pinned.
+func (c *Cache) EvictUnderPressure() int {
+	c.lock()
+	pressure := c.pressure
+	c.unlock()
+	if pressure == nil {
+		return 0
+	}
+
+	n := pressure()
+
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	return c.evict(n, ReasonEvicted)
+}
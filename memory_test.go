@@ -0,0 +1,163 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func bytesSizer(v interface{}) int64 {
+	return int64(len(v.([]byte)))
+}
+
+func TestCache_Bytes(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", []byte("abc"))
+	assert.Equal(t, int64(0), cache.Bytes())
+
+	cache = NewWithConfig(Config{Sizer: bytesSizer})
+	cache.Set("a", []byte("abc"))
+	cache.Set("b", []byte("de"))
+	assert.Equal(t, int64(5), cache.Bytes())
+
+	cache.Set("a", []byte("a"))
+	assert.Equal(t, int64(3), cache.Bytes())
+
+	cache.SetWithFrequency("b", []byte("defg"), 3)
+	cache.SetMultiple(map[string]interface{}{"c": []byte("hi")})
+	assert.Equal(t, int64(7), cache.Bytes())
+
+	cache.Remove("b")
+	cache.Evict(1)
+	assert.Equal(t, int64(1), cache.Bytes())
+}
+
+func TestCache_MaxBytes(t *testing.T) {
+	cache := NewWithConfig(Config{Sizer: bytesSizer, MaxBytes: 10})
+
+	cache.Set("a", make([]byte, 4))
+	cache.Set("b", make([]byte, 4))
+	cache.Get("b")
+	evicted, evictedKey := cache.SetReport("c", make([]byte, 4))
+	assert.True(t, evicted)
+	assert.Equal(t, "a", evictedKey)
+	assert.Equal(t, int64(8), cache.Bytes())
+
+	// growing a value evicts other items, never the value itself
+	cache.Set("c", make([]byte, 20))
+	assert.Equal(t, 1, cache.Size())
+	assert.Equal(t, int64(20), cache.Bytes())
+
+	cache.SetMultiple(map[string]interface{}{"d": make([]byte, 3), "e": make([]byte, 3)})
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, int64(6), cache.Bytes())
+}
+
+func TestCache_SetWithCost(t *testing.T) {
+	cache := NewWithMaxBytes(10)
+	for i := 0; i < 20; i++ {
+		cache.SetWithCost(strconv.Itoa(i), i, 0)
+	}
+	assert.Equal(t, 20, cache.Size())
+
+	cache.Purge()
+	cache.SetWithCost("a", "x", 4)
+	cache.SetWithCost("b", "y", 4)
+	cache.Get("b")
+	cache.SetWithCost("c", "z", 4)
+	assert.Equal(t, []string{"c", "b"}, cache.Keys())
+	assert.Equal(t, int64(8), cache.Bytes())
+
+	// a cost larger than MaxBytes keeps the value, alone
+	cache.SetWithCost("c", "zz", 30)
+	assert.Equal(t, 1, cache.Size())
+	assert.Equal(t, int64(30), cache.Bytes())
+
+	// without a Sizer, Set keeps the cost, and a negative cost counts as zero
+	cache.Set("c", "zzz")
+	assert.Equal(t, int64(30), cache.Bytes())
+	cache.SetWithCost("c", "zzz", -1)
+	assert.Equal(t, int64(0), cache.Bytes())
+	assert.Equal(t, 0, cache.TrimToMemory(0))
+	assert.NoError(t, cache.Verify())
+
+	// a Sizer measures the values of the other Set methods
+	cache = NewWithMaxBytes(10, WithSizer(bytesSizer))
+	cache.Set("a", make([]byte, 6))
+	cache.SetWithCost("b", "y", 3)
+	assert.Equal(t, int64(9), cache.Bytes())
+	cache.Set("b", make([]byte, 5))
+	assert.Equal(t, []string{"b"}, cache.Keys())
+	assert.Equal(t, int64(5), cache.Bytes())
+}
+
+func TestCache_TrimToMemory(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", []byte("abc"))
+	assert.Equal(t, 0, cache.TrimToMemory(0))
+	assert.Equal(t, 1, cache.Size())
+
+	cache = NewWithConfig(Config{Sizer: bytesSizer})
+	for i, k := range []string{"a", "b", "c", "d", "e"} {
+		cache.SetWithFrequency(k, make([]byte, 10), i+1)
+	}
+	assert.Equal(t, int64(50), cache.Bytes())
+
+	assert.Equal(t, 0, cache.TrimToMemory(50))
+	assert.Equal(t, 3, cache.TrimToMemory(25))
+	assert.Equal(t, int64(20), cache.Bytes())
+	for _, k := range []string{"d", "e"} {
+		_, ok := cache.kv[k]
+		assert.True(t, ok)
+	}
+
+	cache.Pin("e")
+	assert.Equal(t, 1, cache.TrimToMemory(0))
+	assert.Equal(t, int64(10), cache.Bytes())
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestCache_EstimatedBytes(t *testing.T) {
+	cache := New(0)
+	assert.Equal(t, int64(0), cache.EstimatedBytes())
+	cache.Set("ab", []byte("abc"))
+	assert.Equal(t, int64(2+entryOverhead), cache.EstimatedBytes())
+
+	cache = NewWithConfig(Config{Capacity: 1, Sizer: bytesSizer})
+	cache.Set("ab", make([]byte, 1000))
+	assert.Equal(t, int64(2+entryOverhead+1000), cache.EstimatedBytes())
+
+	cache.Set("cde", make([]byte, 10))
+	assert.Equal(t, int64(3+entryOverhead+10), cache.EstimatedBytes())
+	cache.Remove("cde")
+	assert.Equal(t, int64(0), cache.EstimatedBytes())
+}
+
+func TestCache_EvictUnderPressure(t *testing.T) {
+	cache := New(0)
+	for i := 0; i < 10; i++ {
+		cache.SetWithFrequency(strconv.Itoa(i), i, i+1)
+	}
+	assert.Equal(t, 0, cache.EvictUnderPressure())
+	assert.Equal(t, 10, cache.Size())
+
+	pressure := []int{3, 0, -1, 2, 100}
+	cache.SetPressureFunc(func() int {
+		n := pressure[0]
+		pressure = pressure[1:]
+		return n
+	})
+
+	assert.Equal(t, 3, cache.EvictUnderPressure())
+	assert.Equal(t, []string{"3", "4", "5", "6", "7", "8", "9"}, cache.Keys())
+	assert.Equal(t, 0, cache.EvictUnderPressure())
+	assert.Equal(t, 0, cache.EvictUnderPressure())
+	assert.Equal(t, 7, cache.Size())
+	assert.Equal(t, 2, cache.EvictUnderPressure())
+	assert.Equal(t, []string{"5", "6", "7", "8", "9"}, cache.Keys())
+	assert.Equal(t, 5, cache.EvictUnderPressure())
+	assert.Equal(t, 0, cache.Size())
+
+	cache.SetPressureFunc(nil)
+	assert.Equal(t, 0, cache.EvictUnderPressure())
+}
@@ -0,0 +1,57 @@
+package lfu
+
+// FrequencyMerge is how Merge combines the frequency counts of a key found
+// in both caches.
+type FrequencyMerge int
+
+const (
+	// SumFrequencies adds both counts up, as if every access went to one
+	// cache.
+	SumFrequencies FrequencyMerge = iota
+	// MaxFrequencies keeps the higher count, for caches that saw the same
+	// accesses.
+	MaxFrequencies
+)
+
+// Merge stores the entries of other into cache with their frequencies, e.g.
+// to join caches filled by forked computations, and returns the number of
+// entries merged. other must have a Snapshot method, as a *Cache does;
+// Merge can't read other LFUs and merges nothing from them.
+//
+// For a key in both caches, the value is what onConflict returns for the
+// existing and the incoming one, the incoming one if onConflict is nil, and
+// the frequencies are combined as freqs says. onConflict runs under the
+// lock, so it must not use cache. Entries are stored from the least to the
+// most frequently used, evicting as needed, so a cache too small for both
+// keeps the most frequently used. An incoming entry keeps expiring when it
+// did in other, and an expired one isn't merged.
+func (c *Cache) Merge(other LFU, onConflict func(existing, incoming interface{}) interface{}, freqs FrequencyMerge) int {
+	s, ok := other.(interface{ Snapshot() []Entry })
+	if !ok {
+		return 0
+	}
+	entries := s.Snapshot()
+
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	for _, e := range entries {
+		k, v, freq := c.key(e.Key), e.Value, e.Freq
+		if item, ok := c.lookup(k); ok {
+			if onConflict != nil {
+				v = onConflict(item.v, v)
+			}
+			existing := item.parent.Value.(*freqNode).freq
+			if freqs == MaxFrequencies {
+				if existing > freq {
+					freq = existing
+				}
+			} else {
+				freq += existing
+			}
+		}
+		c.restoreExpiry(c.setWithFrequency(k, v, freq), e.ExpiresAt)
+	}
+	return len(entries)
+}
@@ -0,0 +1,50 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_Merge(t *testing.T) {
+	newCaches := func() (*Cache, *Cache) {
+		c, other := New(0), New(0)
+		c.SetWithFrequency("a", 1, 2)
+		c.SetWithFrequency("b", 2, 5)
+		other.SetWithFrequency("b", 20, 3)
+		other.SetWithFrequency("c", 30, 4)
+		return c, other
+	}
+	sum := func(existing, incoming interface{}) interface{} {
+		return existing.(int) + incoming.(int)
+	}
+
+	c, other := newCaches()
+	assert.Equal(t, 2, c.Merge(other, sum, SumFrequencies))
+	assert.ElementsMatch(t, []Entry{
+		{Key: "a", Value: 1, Freq: 2},
+		{Key: "b", Value: 22, Freq: 8},
+		{Key: "c", Value: 30, Freq: 4},
+	}, c.Snapshot())
+	assert.Equal(t, 2, other.Size())
+
+	// the incoming value wins without onConflict
+	c, other = newCaches()
+	c.Merge(other, nil, MaxFrequencies)
+	assert.ElementsMatch(t, []Entry{
+		{Key: "a", Value: 1, Freq: 2},
+		{Key: "b", Value: 20, Freq: 5},
+		{Key: "c", Value: 30, Freq: 4},
+	}, c.Snapshot())
+
+	// a full cache keeps the most frequently used of both
+	c, other = newCaches()
+	c.Resize(2)
+	c.Merge(other, sum, SumFrequencies)
+	assert.ElementsMatch(t, []string{"b", "c"}, c.Keys())
+	assert.NoError(t, c.Verify())
+
+	// nothing to read from other LFUs
+	lru := NewLRU(0)
+	lru.Set("d", 4)
+	assert.Equal(t, 0, c.Merge(lru, nil, SumFrequencies))
+}
@@ -0,0 +1,43 @@
+package lfu
+
+// SetWithMeta works like Set, and attaches meta to the kv pair, e.g. the
+// headers of a cached HTTP response body, without wrapping the value. cache
+// keeps a copy of meta. The metadata belongs to the value: a later Set of k,
+// of any kind, replaces the value and clears it, like it clears a TTL, and
+// SetWithMeta replaces both.
+func (c *Cache) SetWithMeta(k string, v interface{}, meta map[string]string) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	c.set(k, v)
+	if item, ok := c.kv[k]; ok {
+		item.meta = copyMeta(meta)
+	}
+}
+
+// GetMeta returns a copy of the metadata attached to k by SetWithMeta, nil
+// if there is none. ok reports whether k is in cache. Like Peek, it doesn't
+// count as an access.
+func (c *Cache) GetMeta(k string) (meta map[string]string, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return nil, false
+	}
+	return copyMeta(item.meta), true
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	if len(meta) == 0 {
+		return nil
+	}
+	cp := make(map[string]string, len(meta))
+	for k, v := range meta {
+		cp[k] = v
+	}
+	return cp
+}
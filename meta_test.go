@@ -0,0 +1,42 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_SetWithMeta(t *testing.T) {
+	cache := New(0)
+	meta := map[string]string{"Content-Type": "text/plain", "Source": "origin"}
+	cache.SetWithMeta("a", "body", meta)
+
+	got, ok := cache.GetMeta("a")
+	assert.True(t, ok)
+	assert.Equal(t, meta, got)
+	v, _ := cache.Peek("a")
+	assert.Equal(t, "body", v)
+
+	// copies both ways, and not an access
+	meta["Source"] = "changed"
+	got["Content-Type"] = "changed"
+	got, _ = cache.GetMeta("a")
+	assert.Equal(t, map[string]string{"Content-Type": "text/plain", "Source": "origin"}, got)
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	// replaced by SetWithMeta, cleared by other Sets
+	cache.SetWithMeta("a", "body2", map[string]string{"Source": "replica"})
+	got, _ = cache.GetMeta("a")
+	assert.Equal(t, map[string]string{"Source": "replica"}, got)
+	cache.Set("a", "body3")
+	got, ok = cache.GetMeta("a")
+	assert.True(t, ok)
+	assert.Nil(t, got)
+
+	cache.Set("b", 2)
+	got, ok = cache.GetMeta("b")
+	assert.True(t, ok)
+	assert.Nil(t, got)
+	got, ok = cache.GetMeta("x")
+	assert.False(t, ok)
+	assert.Nil(t, got)
+}
@@ -0,0 +1,85 @@
+package lfu
+
+import "sync/atomic"
+
+// MetricsSink receives the events of a cache, to adapt them to any metrics
+// backend. Cache calls it after releasing its lock, possibly from many
+// goroutines at once.
+type MetricsSink interface {
+	// IncHit counts a lookup that found its key.
+	IncHit()
+	// IncMiss counts a lookup that didn't find its key.
+	IncMiss()
+	// IncEviction counts an item evicted from cache.
+	IncEviction()
+	// ObserveSize reports the number of items in cache after it changed.
+	ObserveSize(size int)
+}
+
+// NopMetrics is a MetricsSink that discards every event.
+type NopMetrics struct{}
+
+// IncHit does nothing.
+func (NopMetrics) IncHit() {}
+
+// IncMiss does nothing.
+func (NopMetrics) IncMiss() {}
+
+// IncEviction does nothing.
+func (NopMetrics) IncEviction() {}
+
+// ObserveSize does nothing.
+func (NopMetrics) ObserveSize(int) {}
+
+// InMemoryMetrics is a MetricsSink keeping counters in memory. It is safe for
+// concurrent use.
+type InMemoryMetrics struct {
+	hits, misses, evictions uint64
+	size                    int64
+}
+
+// IncHit counts a hit.
+func (m *InMemoryMetrics) IncHit() { atomic.AddUint64(&m.hits, 1) }
+
+// IncMiss counts a miss.
+func (m *InMemoryMetrics) IncMiss() { atomic.AddUint64(&m.misses, 1) }
+
+// IncEviction counts an eviction.
+func (m *InMemoryMetrics) IncEviction() { atomic.AddUint64(&m.evictions, 1) }
+
+// ObserveSize records the last reported size.
+func (m *InMemoryMetrics) ObserveSize(size int) { atomic.StoreInt64(&m.size, int64(size)) }
+
+// Hits returns the number of hits counted.
+func (m *InMemoryMetrics) Hits() uint64 { return atomic.LoadUint64(&m.hits) }
+
+// Misses returns the number of misses counted.
+func (m *InMemoryMetrics) Misses() uint64 { return atomic.LoadUint64(&m.misses) }
+
+// Evictions returns the number of evictions counted.
+func (m *InMemoryMetrics) Evictions() uint64 { return atomic.LoadUint64(&m.evictions) }
+
+// Size returns the last reported size.
+func (m *InMemoryMetrics) Size() int { return int(atomic.LoadInt64(&m.size)) }
+
+// metricEvents are the events of an operation, reported to the MetricsSink
+// once the operation releases the lock.
+type metricEvents struct {
+	hits, misses, evictions int
+	resized                 bool
+}
+
+func (e metricEvents) report(sink MetricsSink, size int) {
+	for i := 0; i < e.hits; i++ {
+		sink.IncHit()
+	}
+	for i := 0; i < e.misses; i++ {
+		sink.IncMiss()
+	}
+	for i := 0; i < e.evictions; i++ {
+		sink.IncEviction()
+	}
+	if e.resized {
+		sink.ObserveSize(size)
+	}
+}
@@ -0,0 +1,195 @@
+// Package metrics exports the counters of lfu caches to expvar, and to
+// Prometheus in its text exposition format.
+//
+// It doesn't depend on a Prometheus client: Handler serves what a scrape
+// expects, and the same Samples are what a prometheus.Collector would
+// collect.
+package metrics
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/ZhengHe-MD/lfu"
+)
+
+// Cache is an LFU exported under a name.
+type Cache struct {
+	Name string
+	LFU  lfu.LFU
+}
+
+// Sample is a point-in-time reading of the counters of a cache. Only Size is
+// known for every LFU; the other counters are read from LFUs with a Stats
+// method, like *lfu.Cache, or a ShardStats method, like *lfu.Sharded, and
+// are zero otherwise.
+type Sample struct {
+	Size            int      `json:"size"`
+	Hits            uint64   `json:"hits"`
+	Misses          uint64   `json:"misses"`
+	Evictions       uint64   `json:"evictions"`
+	HitRatio        float64  `json:"hit_ratio"`
+	LockWaits       uint64   `json:"lock_waits"`
+	LockWaitSeconds float64  `json:"lock_wait_seconds"`
+	Shards          []Sample `json:"shards,omitempty"`
+}
+
+// Read returns the current Sample of l. For sharded LFUs, it includes a
+// Sample of every shard, and their sum.
+func Read(l lfu.LFU) Sample {
+	switch l := l.(type) {
+	case interface{ ShardStats() []lfu.CacheStats }:
+		var s Sample
+		for _, stats := range l.ShardStats() {
+			shard := fromStats(stats)
+			s.Shards = append(s.Shards, shard)
+			s.Size += shard.Size
+			s.Hits += shard.Hits
+			s.Misses += shard.Misses
+			s.Evictions += shard.Evictions
+			s.LockWaits += shard.LockWaits
+			s.LockWaitSeconds += shard.LockWaitSeconds
+		}
+		s.HitRatio = hitRatio(s.Hits, s.Misses)
+		return s
+	case interface{ Stats() lfu.CacheStats }:
+		return fromStats(l.Stats())
+	default:
+		return Sample{Size: l.Size()}
+	}
+}
+
+func fromStats(stats lfu.CacheStats) Sample {
+	return Sample{
+		Size:            stats.Size,
+		Hits:            stats.Hits,
+		Misses:          stats.Misses,
+		Evictions:       stats.Evictions,
+		HitRatio:        hitRatio(stats.Hits, stats.Misses),
+		LockWaits:       stats.LockWaits,
+		LockWaitSeconds: stats.LockWaited.Seconds(),
+	}
+}
+
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// Publish publishes the Sample of c to expvar under c.Name, read anew every
+// time expvar is. Like expvar.Publish, it panics if the name is taken.
+func Publish(c Cache) {
+	expvar.Publish(c.Name, expvar.Func(func() interface{} {
+		return Read(c.LFU)
+	}))
+}
+
+// Handler serves the Samples of caches in the Prometheus text format, labeled
+// with the name of their cache, and their shard for the per-shard metrics.
+func Handler(caches ...Cache) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteText(w, caches...)
+	})
+}
+
+type metric struct {
+	name, kind, help string
+	value            func(s Sample) string
+}
+
+var metrics = []metric{
+	{"size", "gauge", "Number of items in cache.", func(s Sample) string {
+		return strconv.Itoa(s.Size)
+	}},
+	{"hits_total", "counter", "Lookups that found their key.", func(s Sample) string {
+		return formatUint(s.Hits)
+	}},
+	{"misses_total", "counter", "Lookups that didn't find their key.", func(s Sample) string {
+		return formatUint(s.Misses)
+	}},
+	{"evictions_total", "counter", "Items evicted to make room.", func(s Sample) string {
+		return formatUint(s.Evictions)
+	}},
+	{"hit_ratio", "gauge", "Fraction of the lookups that found their key.", func(s Sample) string {
+		return formatFloat(s.HitRatio)
+	}},
+	{"lock_waits_total", "counter", "Calls that found the lock taken.", func(s Sample) string {
+		return formatUint(s.LockWaits)
+	}},
+	{"lock_wait_seconds_total", "counter", "Time spent waiting for the lock.", func(s Sample) string {
+		return formatFloat(s.LockWaitSeconds)
+	}},
+}
+
+// WriteText writes the Samples of caches to w in the Prometheus text format,
+// as Handler serves them: every metric as lfu_<name>{cache="..."}, and, for
+// sharded caches, as lfu_shard_<name>{cache="...",shard="..."} too.
+func WriteText(w io.Writer, caches ...Cache) error {
+	samples := make([]Sample, len(caches))
+	sharded := false
+	for i, c := range caches {
+		samples[i] = Read(c.LFU)
+		sharded = sharded || samples[i].Shards != nil
+	}
+
+	ew := &errWriter{w: w}
+	for _, m := range metrics {
+		name := "lfu_" + m.name
+		ew.printf("# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind)
+		for i, c := range caches {
+			ew.printf("%s{cache=%s} %s\n", name, label(c.Name), m.value(samples[i]))
+		}
+	}
+	if !sharded {
+		return ew.err
+	}
+	for _, m := range metrics {
+		name := "lfu_shard_" + m.name
+		ew.printf("# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind)
+		for i, c := range caches {
+			for j, shard := range samples[i].Shards {
+				ew.printf("%s{cache=%s,shard=\"%d\"} %s\n", name, label(c.Name), j, m.value(shard))
+			}
+		}
+	}
+	return ew.err
+}
+
+// errWriter keeps the first error of a sequence of writes.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
+
+// label quotes a label value the way the text format escapes it: only
+// backslashes, double quotes and line feeds.
+func label(v string) string {
+	b := []byte{'"'}
+	for i := 0; i < len(v); i++ {
+		switch v[i] {
+		case '\\', '"':
+			b = append(b, '\\', v[i])
+		case '\n':
+			b = append(b, '\\', 'n')
+		default:
+			b = append(b, v[i])
+		}
+	}
+	return string(append(b, '"'))
+}
+
+func formatUint(x uint64) string { return strconv.FormatUint(x, 10) }
+
+func formatFloat(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) }
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ZhengHe-MD/lfu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRead(t *testing.T) {
+	cache := lfu.New(1)
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+	cache.Set("b", 2)
+
+	s := Read(cache)
+	assert.Equal(t, 1, s.Size)
+	assert.Equal(t, uint64(2), s.Hits)
+	assert.Equal(t, uint64(1), s.Misses)
+	assert.Equal(t, uint64(1), s.Evictions)
+	assert.InDelta(t, 2.0/3, s.HitRatio, 1e-9)
+	assert.Nil(t, s.Shards)
+
+	// only the size of LFUs without Stats
+	lru := lfu.NewLRU(2)
+	lru.Set("a", 1)
+	lru.Get("a")
+	assert.Equal(t, Sample{Size: 1}, Read(lru))
+}
+
+func TestRead_Sharded(t *testing.T) {
+	s := lfu.NewSharded(0, 3)
+	for _, k := range []string{"a", "b", "c", "d"} {
+		s.Set(k, k)
+		s.Get(k)
+	}
+
+	sample := Read(s)
+	assert.Equal(t, 3, len(sample.Shards))
+	assert.Equal(t, 4, sample.Size)
+	assert.Equal(t, uint64(4), sample.Hits)
+	assert.Equal(t, 1.0, sample.HitRatio)
+
+	size := 0
+	for _, shard := range sample.Shards {
+		size += shard.Size
+	}
+	assert.Equal(t, 4, size)
+}
+
+func TestPublish(t *testing.T) {
+	cache := lfu.New(0)
+	Publish(Cache{Name: "lfu_test_publish", LFU: cache})
+	cache.Set("a", 1)
+	cache.Get("a")
+
+	var s Sample
+	assert.Nil(t, json.Unmarshal([]byte(expvar.Get("lfu_test_publish").String()), &s))
+	assert.Equal(t, Read(cache), s)
+
+	assert.Panics(t, func() {
+		Publish(Cache{Name: "lfu_test_publish", LFU: cache})
+	})
+}
+
+func TestHandler(t *testing.T) {
+	cache := lfu.New(0)
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("b")
+	sharded := lfu.NewConsistentSharded(0, 2)
+
+	rec := httptest.NewRecorder()
+	Handler(Cache{Name: "users", LFU: cache}, Cache{Name: `a"b`, LFU: sharded}).
+		ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# HELP lfu_hits_total Lookups that found their key.",
+		"# TYPE lfu_hits_total counter",
+		`lfu_hits_total{cache="users"} 1`,
+		`lfu_misses_total{cache="users"} 1`,
+		`lfu_hit_ratio{cache="users"} 0.5`,
+		`lfu_size{cache="a\"b"} 0`,
+		`lfu_lock_waits_total{cache="users"} 0`,
+		"# TYPE lfu_shard_size gauge",
+		`lfu_shard_size{cache="a\"b",shard="1"} 0`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+	assert.NotContains(t, body, `lfu_shard_size{cache="users"`)
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, `"plain"`, label("plain"))
+	assert.Equal(t, `"a\\b\"c\nd"`, label("a\\b\"c\nd"))
+}
@@ -0,0 +1,56 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeSink struct {
+	hits, misses, evictions int
+	sizes                   []int
+}
+
+func (f *fakeSink) IncHit()              { f.hits++ }
+func (f *fakeSink) IncMiss()             { f.misses++ }
+func (f *fakeSink) IncEviction()         { f.evictions++ }
+func (f *fakeSink) ObserveSize(size int) { f.sizes = append(f.sizes, size) }
+
+func TestCache_Metrics(t *testing.T) {
+	sink := &fakeSink{}
+	cache := New(2, WithMetrics(sink))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("a", 3)
+	cache.Get("a")
+	cache.Get("c")
+	cache.GetBatch([]string{"a", "b", "d"})
+	cache.Set("c", 3)
+	cache.Evict(1)
+	cache.Remove("a")
+
+	assert.Equal(t, 3, sink.hits)
+	assert.Equal(t, 2, sink.misses)
+	assert.Equal(t, 2, sink.evictions)
+	assert.Equal(t, []int{1, 2, 2, 1, 0}, sink.sizes)
+}
+
+func TestInMemoryMetrics(t *testing.T) {
+	m := &InMemoryMetrics{}
+	cache := New(1, WithMetrics(m))
+
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("b")
+	cache.Set("b", 2)
+
+	assert.Equal(t, uint64(1), m.Hits())
+	assert.Equal(t, uint64(1), m.Misses())
+	assert.Equal(t, uint64(1), m.Evictions())
+	assert.Equal(t, 1, m.Size())
+
+	var sink MetricsSink = NopMetrics{}
+	cache = New(1, WithMetrics(sink))
+	cache.Set("a", 1)
+	cache.Get("a")
+}
@@ -0,0 +1,110 @@
+package lfu
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// sketchWidthPerKey is the number of sketch counters kept per tracked key,
+// which keeps collisions rare enough for the top keys to be meaningful.
+const sketchWidthPerKey = 16
+
+// missTracker estimates which absent keys are requested most, in memory
+// bounded by the number of keys it tracks.
+type missTracker struct {
+	total  uint64
+	sketch *cmSketch
+	size   int
+	top    map[string]uint32
+}
+
+// newMissTracker creates a tracker of the size most missed keys, whose sketch
+// has depth rows of at most maxWidth counters if maxWidth is positive.
+func newMissTracker(size, maxWidth, depth int) *missTracker {
+	width := size * sketchWidthPerKey
+	// the sketch rounds its width up to a power of two, which must not
+	// exceed maxWidth either
+	if maxWidth > 0 {
+		if max := 1 << (bits.Len(uint(maxWidth)) - 1); width > max {
+			width = max
+		}
+	}
+
+	return &missTracker{
+		sketch: newCMSketch(width, depth),
+		size:   size,
+		top:    make(map[string]uint32, size),
+	}
+}
+
+// reset forgets every miss, keeping the bounds of m.
+func (m *missTracker) reset() {
+	m.total = 0
+	m.sketch = newCMSketch(len(m.sketch.rows[0]), len(m.sketch.rows))
+	m.top = make(map[string]uint32, m.size)
+}
+
+// record counts a miss of k, keeping k among the top keys if its estimate
+// beats the least missed one.
+func (m *missTracker) record(k string) {
+	m.total++
+	est := m.sketch.add(k)
+
+	if _, ok := m.top[k]; ok || len(m.top) < m.size {
+		m.top[k] = est
+		return
+	}
+
+	minK, minV := "", ^uint32(0)
+	for tk, tv := range m.top {
+		if tv < minV {
+			minK, minV = tk, tv
+		}
+	}
+	if est > minV {
+		delete(m.top, minK)
+		m.top[k] = est
+	}
+}
+
+// ColdMisses returns the number of lookups of keys that weren't in cache. It
+// is always zero unless Config.TrackMisses is set.
+func (c *Cache) ColdMisses() uint64 {
+	c.lock()
+	defer c.unlock()
+
+	if c.misses == nil {
+		return 0
+	}
+	return c.misses.total
+}
+
+// HottestMissedKeys returns up to n keys that were looked up but not in cache,
+// from the most to the least missed. Counts are estimated by a count-min
+// sketch, and only the Config.TrackMisses most missed keys are remembered, so
+// it returns nil unless TrackMisses is set.
+func (c *Cache) HottestMissedKeys(n int) []string {
+	c.lock()
+	defer c.unlock()
+
+	if c.misses == nil || n <= 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(c.misses.top))
+	for k := range c.misses.top {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := c.misses.top[keys[i]], c.misses.top[keys[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return keys[i] < keys[j]
+	})
+
+	if n < len(keys) {
+		keys = keys[:n]
+	}
+	return keys
+}
@@ -0,0 +1,56 @@
+package lfu
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_HottestMissedKeys(t *testing.T) {
+	cache := New(2)
+	cache.Get("a")
+	assert.Equal(t, uint64(0), cache.ColdMisses())
+	assert.Nil(t, cache.HottestMissedKeys(1))
+
+	cache = NewWithConfig(Config{Capacity: 2, TrackMisses: 3})
+	cache.Set("a", 1)
+
+	for i, k := range []string{"w", "x", "y", "z"} {
+		for j := 0; j <= i*2; j++ {
+			cache.Get(k)
+		}
+	}
+	cache.Get("a")
+	cache.GetBatch([]string{"a", "z"})
+	_, _, _ = cache.GetWithFreshness("z")
+
+	assert.Equal(t, uint64(1+3+5+7+2), cache.ColdMisses())
+	assert.Equal(t, []string{"z", "y", "x"}, cache.HottestMissedKeys(10))
+	assert.Equal(t, []string{"z"}, cache.HottestMissedKeys(1))
+	assert.Nil(t, cache.HottestMissedKeys(0))
+}
+
+func TestMissTracker_Bounded(t *testing.T) {
+	m := newMissTracker(4, 0, 0)
+	width := len(m.sketch.rows[0])
+
+	for i := 0; i < 10000; i++ {
+		m.record(fmt.Sprintf("k%d", i%1000))
+		assert.True(t, len(m.top) <= 4)
+	}
+	assert.Equal(t, uint64(10000), m.total)
+	assert.Equal(t, width, len(m.sketch.rows[0]))
+}
+
+func TestCMSketch(t *testing.T) {
+	s := newCMSketch(100, 0)
+	assert.Equal(t, 128, len(s.rows[0]))
+	assert.Equal(t, uint32(0), s.estimate("a"))
+
+	for i := 0; i < 5; i++ {
+		s.add("a")
+	}
+	assert.Equal(t, uint32(6), s.add("a"))
+	assert.Equal(t, uint32(6), s.estimate("a"))
+	assert.True(t, s.estimate("b") <= 6)
+}
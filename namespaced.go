@@ -0,0 +1,201 @@
+package lfu
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Namespaced hands out namespaces, logical caches that share the capacity of
+// one cache, so a hot namespace can take over the room of a cold one instead
+// of each being bounded on its own. Keys of different namespaces never
+// collide, and eviction is global: storing a new key in a full Namespaced
+// evicts the least frequently used item of any namespace.
+type Namespaced struct {
+	c *Cache
+
+	mu         sync.Mutex
+	namespaces map[string]*namespace
+}
+
+// NewNamespaced creates a Namespaced holding up to totalCap items across all
+// its namespaces. A non-positive totalCap means it won't do any eviction.
+func NewNamespaced(totalCap int) *Namespaced {
+	return &Namespaced{c: New(totalCap), namespaces: make(map[string]*namespace)}
+}
+
+// Namespace returns the namespace of the given name, creating it on first
+// use.
+func (n *Namespaced) Namespace(name string) LFU {
+	return n.namespace(name)
+}
+
+// SetQuota reserves room for quota items of the namespace of the given name,
+// creating it on first use, e.g. so the traffic of one tenant doesn't evict
+// the entries of another. A namespace at its quota evicts its own least
+// frequently used item to store a new key, and the items of a namespace
+// within its quota are only evicted for other namespaces once the items of
+// namespaces without a quota, or over it, are gone, which never happens if
+// the quotas add up to at most the total capacity. Lowering a quota below
+// the size of the namespace evicts the excess. A non-positive quota removes
+// the quota.
+func (n *Namespaced) SetQuota(name string, quota int) {
+	ns := n.namespace(name)
+
+	c := n.c
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	ns.quota = quota
+	if quota > 0 && ns.size > quota {
+		ns.evict(ns.size-quota, ReasonCapacity)
+	}
+}
+
+func (n *Namespaced) namespace(name string) *namespace {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// a length prefix can't be confused with the start of another name
+	prefix := strconv.Itoa(len(name)) + ":" + name
+	ns, ok := n.namespaces[prefix]
+	if !ok {
+		ns = &namespace{parent: n, prefix: prefix}
+		n.namespaces[prefix] = ns
+	}
+	return ns
+}
+
+// reserved reports whether item is in the room its namespace reserved with
+// SetQuota, so it must be evicted last. The caller must hold the lock of the
+// shared cache.
+func (n *Namespaced) reserved(item *kvItem) bool {
+	ns := n.owner(item.k)
+	return ns.quota > 0 && ns.size <= ns.quota
+}
+
+// owner returns the namespace of a key of the shared cache.
+func (n *Namespaced) owner(k string) *namespace {
+	i := strings.IndexByte(k, ':')
+	size, _ := strconv.Atoi(k[:i])
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.namespaces[k[:i+1+size]]
+}
+
+// namespace stores its keys in the shared cache under its prefix. Its size
+// and quota are guarded by the lock of the shared cache.
+type namespace struct {
+	parent *Namespaced
+	prefix string
+	size   int
+	quota  int
+}
+
+var _ LFU = (*namespace)(nil)
+
+// Set stores the given kv pair. If k is new, it evicts the least frequently
+// used item of the namespace if it is at its quota, or else, if the shared
+// capacity is used up, the least frequently used item of any namespace, one
+// outside the quotas first.
+func (ns *namespace) Set(k string, v interface{}) {
+	c := ns.parent.c
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = ns.prefix + k
+	if _, ok := c.kv[k]; !ok {
+		if ns.quota > 0 && ns.size >= ns.quota {
+			ns.evict(1, ReasonCapacity)
+		} else if c.cap > 0 && len(c.kv) >= c.cap {
+			if victim := c.victim(ns.parent.reserved); victim != nil {
+				ns.parent.owner(victim.k).size--
+				c.evictItem(victim, ReasonCapacity)
+			}
+		}
+		ns.size++
+	}
+
+	c.collecting = true
+	c.set(k, v)
+	evicted := c.collected
+	c.collecting, c.collected = false, nil
+	for _, e := range evicted {
+		ns.parent.owner(e.Key).size--
+	}
+}
+
+// Get returns the v related to k in the namespace.
+func (ns *namespace) Get(k string) (v interface{}, ok bool) {
+	return ns.parent.c.Get(ns.prefix + k)
+}
+
+// Evict evicts up to n least frequently used items of the namespace.
+func (ns *namespace) Evict(n int) {
+	c := ns.parent.c
+	c.lock()
+	defer c.unlock()
+
+	ns.evict(n, ReasonEvicted)
+}
+
+// evict evicts up to n least frequently used items of the namespace for the
+// given reason. The caller must hold the lock of the shared cache.
+func (ns *namespace) evict(n int, reason EvictReason) {
+	c := ns.parent.c
+	other := func(item *kvItem) bool {
+		return !strings.HasPrefix(item.k, ns.prefix)
+	}
+	for i := 0; i < n; i++ {
+		victim := c.victim(other)
+		if victim == nil {
+			return
+		}
+		c.evictItem(victim, reason)
+		ns.size--
+	}
+}
+
+// Size returns the number of items in the namespace.
+func (ns *namespace) Size() int {
+	c := ns.parent.c
+	c.lock()
+	defer c.unlock()
+
+	return ns.size
+}
+
+// Remove deletes k from the namespace.
+func (ns *namespace) Remove(k string) bool {
+	c := ns.parent.c
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	item, ok := c.kv[ns.prefix+k]
+	if ok {
+		c.deleteItem(item, ReasonDeleted)
+		ns.size--
+	}
+	return ok
+}
+
+// Purge deletes every item of the namespace, leaving the other namespaces
+// alone. It scans every key of the shared cache.
+func (ns *namespace) Purge() {
+	c := ns.parent.c
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	for k, item := range c.kv {
+		if strings.HasPrefix(k, ns.prefix) {
+			c.deleteItem(item, ReasonDeleted)
+		}
+	}
+	ns.size = 0
+}
@@ -0,0 +1,114 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNamespaced(t *testing.T) {
+	n := NewNamespaced(4)
+	hot, cold := n.Namespace("hot"), n.Namespace("cold")
+	assert.Same(t, hot, n.Namespace("hot"))
+
+	// keys of different namespaces don't collide, even with names alike
+	cold.Set("x", 1)
+	cold.Set("y", 2)
+	hot.Set("x", 10)
+	n.Namespace("hot:x").Set("", 100)
+	v, _ := hot.Get("x")
+	assert.Equal(t, 10, v)
+	assert.Equal(t, 2, cold.Size())
+	n.Namespace("hot:x").Evict(1)
+	_, ok := hot.Get("x")
+	assert.True(t, ok)
+
+	// the hot namespace takes over the room of the cold one
+	hot.Set("p", 11)
+	hot.Get("p")
+	hot.Get("x")
+	hot.Set("q", 12)
+	assert.Equal(t, 3, hot.Size())
+	assert.Equal(t, 1, cold.Size())
+	hot.Get("q")
+	hot.Set("r", 13)
+	assert.Equal(t, 4, hot.Size())
+	assert.Equal(t, 0, cold.Size())
+	assert.Equal(t, 4, n.c.Size())
+
+	// Evict only evicts from its namespace
+	cold.Set("z", 3)
+	assert.Equal(t, 3, hot.Size())
+	cold.Get("z")
+	cold.Get("z")
+	hot.Evict(1)
+	assert.Equal(t, 2, hot.Size())
+	assert.Equal(t, 1, cold.Size())
+	cold.Evict(5)
+	assert.Equal(t, 0, cold.Size())
+	assert.Equal(t, 2, n.c.Size())
+	assert.NoError(t, n.c.Verify())
+}
+
+func TestNamespaced_RemovePurge(t *testing.T) {
+	n := NewNamespaced(0)
+	a, b := n.Namespace("a"), n.Namespace("b")
+	a.Set("x", 1)
+	a.Set("y", 2)
+	b.Set("x", 3)
+
+	assert.True(t, a.Remove("x"))
+	assert.False(t, a.Remove("x"))
+	assert.Equal(t, 1, a.Size())
+	v, ok := b.Get("x")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+
+	a.Set("z", 4)
+	a.Purge()
+	assert.Equal(t, 0, a.Size())
+	assert.Equal(t, 1, b.Size())
+	assert.Equal(t, 1, n.c.Size())
+	assert.NoError(t, n.c.Verify())
+}
+
+func TestNamespaced_SetQuota(t *testing.T) {
+	n := NewNamespaced(4)
+	n.SetQuota("a", 2)
+	n.SetQuota("b", 2)
+	a, b := n.Namespace("a"), n.Namespace("b")
+
+	a.Set("x", 1)
+	a.Get("x")
+	a.Set("y", 2)
+	a.Get("y")
+	b.Set("x", 3)
+
+	// a at its quota evicts its own items, however cold b is
+	a.Set("z", 4)
+	assert.Equal(t, 2, a.Size())
+	assert.Equal(t, 1, b.Size())
+	_, ok := a.Get("z")
+	assert.True(t, ok)
+
+	// b fills its share without evicting a
+	b.Set("y", 5)
+	b.Get("y")
+	assert.Equal(t, 2, a.Size())
+	assert.Equal(t, 2, b.Size())
+
+	// a namespace without a quota is evicted first once the cache is full
+	c := n.Namespace("c")
+	c.Set("x", 6)
+	assert.Equal(t, 1, c.Size())
+	assert.Equal(t, 3, a.Size()+b.Size())
+	c.Set("y", 7)
+	assert.Equal(t, 1, c.Size())
+	assert.Equal(t, 3, a.Size()+b.Size())
+
+	// lowering a quota evicts the excess
+	n.SetQuota("a", 1)
+	assert.True(t, a.Size() <= 1)
+	n.SetQuota("a", 0)
+	assert.NoError(t, n.c.Verify())
+	assert.Equal(t, n.c.Size(), a.Size()+b.Size()+c.Size())
+}
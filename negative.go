@@ -0,0 +1,41 @@
+package lfu
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNegativeCached is the value of a key StoreMiss stored, which the Get
+// methods return like any other value, and the error GetOrLoad and the like
+// return instead of calling their loader.
+var ErrNegativeCached = errors.New("lfu: negative cached")
+
+// StoreMiss stores a negative entry for k, recording that the backing store
+// has no value for it, so lookups of k stop reaching the backing store until
+// ttl has passed. A negative entry counts towards the Capacity like any
+// other, and is evicted like any other. A non-positive ttl means it doesn't
+// expire, like for SetWithTTL, and a later Set of k replaces it.
+func (c *Cache) StoreMiss(k string, ttl time.Duration) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	c.set(k, ErrNegativeCached)
+	c.setTTL(c.kv[k], ttl)
+}
+
+// negative reports whether v is the value of a negative entry.
+func negative(v interface{}) bool {
+	err, ok := v.(error)
+	return ok && err == ErrNegativeCached
+}
+
+// loaded returns the value of a key the Get methods found, or
+// ErrNegativeCached for a negative entry, for the GetOrLoad methods.
+func loaded(v interface{}) (interface{}, error) {
+	if negative(v) {
+		return nil, ErrNegativeCached
+	}
+	return v, nil
+}
@@ -0,0 +1,99 @@
+package lfu
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_StoreMiss(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(2, WithClock(clock), WithSizer(func(v interface{}) int64 {
+		return int64(len(v.(string)))
+	}))
+
+	loads := 0
+	loader := func(k string) (interface{}, error) {
+		loads++
+		return "found", nil
+	}
+
+	cache.StoreMiss("a", time.Second)
+	v, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, ErrNegativeCached, v)
+	_, err := cache.GetOrLoad("a", loader)
+	assert.Equal(t, ErrNegativeCached, err)
+	_, err = cache.GetOrLoadCtx(context.Background(), "a", func(ctx context.Context, k string) (interface{}, error) {
+		return loader(k)
+	})
+	assert.Equal(t, ErrNegativeCached, err)
+	assert.Equal(t, 0, loads)
+	assert.Equal(t, int64(0), cache.Bytes())
+
+	// negative entries take room, and are kept by their hits, like any other
+	cache.Set("b", "bb")
+	cache.Get("b")
+	cache.StoreMiss("c", 0)
+	assert.Equal(t, 2, cache.Size())
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	// once expired, the backing store is asked again
+	cache.StoreMiss("a", time.Second)
+	clock.Advance(time.Second)
+	v, err = cache.GetOrLoad("a", loader)
+	assert.NoError(t, err)
+	assert.Equal(t, "found", v)
+	assert.Equal(t, 1, loads)
+
+	// a Set replaces a negative entry
+	cache.Set("c", "value")
+	v, _ = cache.Get("c")
+	assert.Equal(t, "value", v)
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_StoreMissWriteTo(t *testing.T) {
+	src := New(0, intCodec())
+	src.Set("a", 1)
+	src.StoreMiss("b", time.Minute)
+
+	var buf bytes.Buffer
+	_, err := src.WriteTo(&buf)
+	assert.NoError(t, err)
+	dst := New(0, intCodec())
+	_, err = dst.ReadFrom(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a"}, dst.Keys())
+	assert.Equal(t, 1, dst.Size())
+}
+
+func TestCache_StoreMissSnapshot(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	cache.Set("a", 1)
+	cache.StoreMiss("b", time.Second)
+
+	entries := cache.Snapshot()
+	assert.Equal(t, []Entry{{Key: "a", Value: 1, Freq: 1}}, entries)
+	assert.Equal(t, []string{"a"}, cache.Keys())
+	_, ok := cache.Clone().Get("b")
+	assert.False(t, ok)
+
+	restored := New(0, WithClock(clock))
+	restored.Restore(entries)
+	clock.Advance(time.Hour)
+	_, err := restored.GetOrLoad("b", func(k string) (interface{}, error) { return 2, nil })
+	assert.NoError(t, err)
+
+	// a StoreMiss landing between the miss of GetOrLoad and its load
+	cache.StoreMiss("c", 0)
+	v, err := cache.loadOnce(context.Background(), "c", withoutContext(func(k string) (interface{}, error) {
+		return 3, nil
+	}))
+	assert.Nil(t, v)
+	assert.Equal(t, ErrNegativeCached, err)
+}
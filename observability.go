@@ -0,0 +1,59 @@
+package lfu
+
+import "unsafe"
+
+// ObservabilityConfig bounds the structures cache keeps to observe itself, so
+// monitoring never dominates its footprint, whatever the rest of Config asks
+// for. A non-positive bound means no bound.
+type ObservabilityConfig struct {
+	// MaxTrackedMisses caps Config.TrackMisses, the number of missed keys
+	// remembered for HottestMissedKeys.
+	MaxTrackedMisses int
+	// SketchWidth caps the number of counters in each row of the sketch
+	// estimating how often keys are missed, which otherwise grows with
+	// TrackMisses. A narrower sketch overcounts more keys.
+	SketchWidth int
+	// SketchDepth is the number of rows of that sketch. It defaults to 4.
+	SketchDepth int
+	// MaxAccessBuffer caps Config.AccessBuffer, including its default.
+	MaxAccessBuffer int
+	// MaxHitRatioWindow caps Config.HitRatioWindow, including its default.
+	MaxHitRatioWindow int
+}
+
+// trackedMissOverhead is a rough estimate of the memory a missed key takes
+// in the tracker besides its bytes: its string header, count and map slot.
+const trackedMissOverhead = 48
+
+// ObservabilityBytes returns a rough estimate of the memory the structures
+// cache keeps to observe itself take: the missed keys and their sketch, the
+// buffer of the AccessRecorder, the window of RecentHitRatio and the
+// histogram of evicted frequencies. EstimatedBytes leaves them out.
+func (c *Cache) ObservabilityBytes() int64 {
+	c.lock()
+	defer c.unlock()
+
+	n := int64(unsafe.Sizeof(c.evicted)) + int64(len(c.recent.hits))
+	if m := c.misses; m != nil {
+		n += int64(len(m.sketch.rows)*len(m.sketch.rows[0])) * int64(unsafe.Sizeof(uint32(0)))
+		for k := range m.top {
+			n += int64(len(k)) + trackedMissOverhead
+		}
+	}
+	if c.accesses != nil {
+		n += int64(cap(c.accesses.queue)) * int64(unsafe.Sizeof(AccessEvent{}))
+	}
+	return n
+}
+
+// bounded returns n, or def if n isn't positive, capped at max if max is
+// positive.
+func bounded(n, def, max int) int {
+	if n <= 0 {
+		n = def
+	}
+	if max > 0 && n > max {
+		n = max
+	}
+	return n
+}
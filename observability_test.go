@@ -0,0 +1,60 @@
+package lfu
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_ObservabilityBounds(t *testing.T) {
+	cache := NewWithConfig(Config{
+		Capacity:       2,
+		TrackMisses:    1000,
+		HitRatioWindow: 5000,
+		AccessRecorder: func(e AccessEvent) {},
+		Observability: ObservabilityConfig{
+			MaxTrackedMisses:  8,
+			SketchWidth:       100,
+			SketchDepth:       2,
+			MaxAccessBuffer:   16,
+			MaxHitRatioWindow: 32,
+		},
+	})
+
+	for i := 0; i < 10000; i++ {
+		cache.Get(fmt.Sprintf("k%d", i%1000))
+	}
+
+	cache.lock()
+	assert.Equal(t, 8, len(cache.misses.top))
+	assert.Equal(t, 2, len(cache.misses.sketch.rows))
+	assert.Equal(t, 64, len(cache.misses.sketch.rows[0]))
+	assert.Equal(t, 16, cap(cache.accesses.queue))
+	assert.Equal(t, 32, len(cache.recent.hits))
+	cache.unlock()
+
+	bytes := cache.ObservabilityBytes()
+	assert.True(t, bytes > 2*64*4, bytes)
+	assert.True(t, bytes < 4096, bytes)
+	assert.Equal(t, uint64(10000), cache.ColdMisses())
+	assert.Len(t, cache.HottestMissedKeys(100), 8)
+
+	cache.Reset(2)
+	cache.Get("a")
+	cache.lock()
+	assert.Equal(t, 2, len(cache.misses.sketch.rows))
+	assert.Equal(t, 64, len(cache.misses.sketch.rows[0]))
+	cache.unlock()
+
+	// without bounds, the structures follow the rest of Config
+	cache = NewWithConfig(Config{Capacity: 2, TrackMisses: 10})
+	cache.Get("a")
+	cache.lock()
+	assert.Equal(t, sketchDepth, len(cache.misses.sketch.rows))
+	assert.Equal(t, 256, len(cache.misses.sketch.rows[0]))
+	assert.Equal(t, defaultRecentWindow, len(cache.recent.hits))
+	cache.unlock()
+	assert.True(t, cache.ObservabilityBytes() > int64(sketchDepth*256*4))
+
+	assert.True(t, New(2).ObservabilityBytes() > 0)
+}
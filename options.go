@@ -0,0 +1,369 @@
+package lfu
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Option sets a field of the Config used by New. Options are applied in
+// order, so a later Option overrides an earlier one setting the same field.
+//
+// There is no option for a read-write lock: every Get moves its item to
+// another freq node, and even Peek deletes an expired item, so next to no
+// call could do with a read lock. WithShards and WithReadBuffer are the ways
+// to spread concurrent reads.
+type Option func(cfg *Config)
+
+// WithFreshness sets Config.Freshness.
+func WithFreshness(d time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.Freshness = d
+	}
+}
+
+// WithClock sets Config.Clock.
+func WithClock(clock Clock) Option {
+	return func(cfg *Config) {
+		cfg.Clock = clock
+	}
+}
+
+// WithTrackMisses sets Config.TrackMisses.
+func WithTrackMisses(n int) Option {
+	return func(cfg *Config) {
+		cfg.TrackMisses = n
+	}
+}
+
+// WithEvictionComparator sets Config.EvictionComparator.
+func WithEvictionComparator(less func(a, b Entry) bool) Option {
+	return func(cfg *Config) {
+		cfg.EvictionComparator = less
+	}
+}
+
+// WithMetrics sets Config.Metrics.
+func WithMetrics(sink MetricsSink) Option {
+	return func(cfg *Config) {
+		cfg.Metrics = sink
+	}
+}
+
+// WithCloneFunc sets Config.CloneFunc.
+func WithCloneFunc(clone func(v interface{}) interface{}) Option {
+	return func(cfg *Config) {
+		cfg.CloneFunc = clone
+	}
+}
+
+// WithMaxConcurrentLoads sets Config.MaxConcurrentLoads.
+func WithMaxConcurrentLoads(n int) Option {
+	return func(cfg *Config) {
+		cfg.MaxConcurrentLoads = n
+	}
+}
+
+// WithKeyNormalizer sets Config.KeyNormalizer.
+func WithKeyNormalizer(normalize func(k string) string) Option {
+	return func(cfg *Config) {
+		cfg.KeyNormalizer = normalize
+	}
+}
+
+// WithRecycleBuffers sets Config.RecycleBuffers.
+func WithRecycleBuffers() Option {
+	return func(cfg *Config) {
+		cfg.RecycleBuffers = true
+	}
+}
+
+// WithAccessRecorder sets Config.AccessRecorder and Config.AccessBuffer.
+func WithAccessRecorder(record func(e AccessEvent), buffer int) Option {
+	return func(cfg *Config) {
+		cfg.AccessRecorder = record
+		cfg.AccessBuffer = buffer
+	}
+}
+
+// WithTTLJitter sets Config.TTLJitter.
+func WithTTLJitter(jitter float64) Option {
+	return func(cfg *Config) {
+		cfg.TTLJitter = jitter
+	}
+}
+
+// WithRandSource sets Config.RandSource.
+func WithRandSource(src rand.Source) Option {
+	return func(cfg *Config) {
+		cfg.RandSource = src
+	}
+}
+
+// WithSeed sets Config.RandSource to a source seeded with seed, so the
+// randomized behavior of cache is the same on every run.
+func WithSeed(seed int64) Option {
+	return func(cfg *Config) {
+		cfg.RandSource = rand.NewSource(seed)
+	}
+}
+
+// WithOnFull sets Config.OnFull.
+func WithOnFull(policy FullPolicy) Option {
+	return func(cfg *Config) {
+		cfg.OnFull = policy
+	}
+}
+
+// WithHitRatioWindow sets Config.HitRatioWindow.
+func WithHitRatioWindow(n int) Option {
+	return func(cfg *Config) {
+		cfg.HitRatioWindow = n
+	}
+}
+
+// WithMaxKeyLength sets Config.MaxKeyLength and Config.TruncateLongKeys.
+func WithMaxKeyLength(n int, truncate bool) Option {
+	return func(cfg *Config) {
+		cfg.MaxKeyLength = n
+		cfg.TruncateLongKeys = truncate
+	}
+}
+
+// WithValueCodec sets Config.ValueEncoder and Config.ValueDecoder.
+func WithValueCodec(encode func(v interface{}) ([]byte, error), decode func(data []byte) (interface{}, error)) Option {
+	return func(cfg *Config) {
+		cfg.ValueEncoder = encode
+		cfg.ValueDecoder = decode
+	}
+}
+
+// WithGobCodec sets Config.ValueEncoder and Config.ValueDecoder to encode
+// values with encoding/gob, so WriteTo and ReadFrom handle most values
+// without a codec of their own. Values are encoded as interface values, each
+// with its type, which takes more room than a dedicated codec, and their
+// types, unless basic ones like int or string, must be registered with
+// gob.Register by the writer and the reader alike.
+func WithGobCodec() Option {
+	return WithValueCodec(gobEncode, gobDecode)
+}
+
+// WithCircuitBreaker sets Config.FailureThreshold and Config.CooldownDuration.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.FailureThreshold = threshold
+		cfg.CooldownDuration = cooldown
+	}
+}
+
+// WithValueEqual sets Config.ValueEqual.
+func WithValueEqual(equal func(a, b interface{}) bool) Option {
+	return func(cfg *Config) {
+		cfg.ValueEqual = equal
+	}
+}
+
+// WithMaxTiers sets Config.MaxTiers.
+func WithMaxTiers(n int) Option {
+	return func(cfg *Config) {
+		cfg.MaxTiers = n
+	}
+}
+
+// WithLazyPromotions sets Config.LazyPromotions.
+func WithLazyPromotions(buffer int) Option {
+	return func(cfg *Config) {
+		cfg.LazyPromotions = buffer
+	}
+}
+
+// WithStrict sets Config.Strict.
+func WithStrict() Option {
+	return func(cfg *Config) {
+		cfg.Strict = true
+	}
+}
+
+// WithFrequencyWindow sets Config.FrequencyWindow and Config.FrequencyBuckets.
+func WithFrequencyWindow(width time.Duration, buckets int) Option {
+	return func(cfg *Config) {
+		cfg.FrequencyWindow = width
+		cfg.FrequencyBuckets = buckets
+	}
+}
+
+// WithIncrementWeight sets Config.IncrementWeight.
+func WithIncrementWeight(weight func(v interface{}) int) Option {
+	return func(cfg *Config) {
+		cfg.IncrementWeight = weight
+	}
+}
+
+// WithInitialFrequency sets Config.InitialFrequency.
+func WithInitialFrequency(freq int) Option {
+	return func(cfg *Config) {
+		cfg.InitialFrequency = freq
+	}
+}
+
+// WithLockWaitObserver sets Config.LockWaitObserver.
+func WithLockWaitObserver(observe func(wait time.Duration)) Option {
+	return func(cfg *Config) {
+		cfg.LockWaitObserver = observe
+	}
+}
+
+// WithObservability sets Config.Observability.
+func WithObservability(obs ObservabilityConfig) Option {
+	return func(cfg *Config) {
+		cfg.Observability = obs
+	}
+}
+
+// WithExpirySweep sets Config.ExpirySweepInterval.
+func WithExpirySweep(interval time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.ExpirySweepInterval = interval
+	}
+}
+
+// WithEvictionCallback sets Config.EvictionCallback.
+func WithEvictionCallback(fn func(k string, v interface{}, reason EvictReason)) Option {
+	return func(cfg *Config) {
+		cfg.EvictionCallback = fn
+	}
+}
+
+// WithSizer sets Config.Sizer.
+func WithSizer(sizer func(v interface{}) int64) Option {
+	return func(cfg *Config) {
+		cfg.Sizer = sizer
+	}
+}
+
+// WithMaxBytes sets Config.MaxBytes.
+func WithMaxBytes(maxBytes int64) Option {
+	return func(cfg *Config) {
+		cfg.MaxBytes = maxBytes
+	}
+}
+
+// WithAdmissionWindow sets Config.AdmissionWindow.
+func WithAdmissionWindow(size int) Option {
+	return func(cfg *Config) {
+		cfg.AdmissionWindow = size
+	}
+}
+
+// WithFrequencyDecay sets Config.FrequencyDecayInterval.
+func WithFrequencyDecay(interval time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.FrequencyDecayInterval = interval
+	}
+}
+
+// WithCapacity sets Config.Capacity, overriding the cap given to New.
+func WithCapacity(cap int) Option {
+	return func(cfg *Config) {
+		cfg.Capacity = cap
+	}
+}
+
+// WithDefaultTTL sets Config.DefaultTTL.
+func WithDefaultTTL(ttl time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.DefaultTTL = ttl
+	}
+}
+
+// WithProtectedRatio sets Config.ProtectedRatio.
+func WithProtectedRatio(ratio float64) Option {
+	return func(cfg *Config) {
+		cfg.ProtectedRatio = ratio
+	}
+}
+
+// WithRefreshAfter sets Config.RefreshAfter and Config.RefreshWorkers.
+func WithRefreshAfter(after time.Duration, workers int) Option {
+	return func(cfg *Config) {
+		cfg.RefreshAfter = after
+		cfg.RefreshWorkers = workers
+	}
+}
+
+// WithHeapLimit sets Config.HeapLimit and Config.HeapCheckInterval.
+func WithHeapLimit(limit uint64, interval time.Duration) Option {
+	return func(cfg *Config) {
+		cfg.HeapLimit = limit
+		cfg.HeapCheckInterval = interval
+	}
+}
+
+// WithHeapUsage sets Config.HeapUsage.
+func WithHeapUsage(usage func() uint64) Option {
+	return func(cfg *Config) {
+		cfg.HeapUsage = usage
+	}
+}
+
+// WithTrackAccessTime sets Config.TrackAccessTime.
+func WithTrackAccessTime() Option {
+	return func(cfg *Config) {
+		cfg.TrackAccessTime = true
+	}
+}
+
+// WithTiePolicy sets Config.TiePolicy.
+func WithTiePolicy(p TiePolicy) Option {
+	return func(cfg *Config) {
+		cfg.TiePolicy = p
+	}
+}
+
+// WithReadBuffer sets Config.ReadBuffer.
+func WithReadBuffer(size int) Option {
+	return func(cfg *Config) {
+		cfg.ReadBuffer = size
+	}
+}
+
+// WithWarmup sets Config.Warmup.
+func WithWarmup(warmup func(add func(k string, v interface{}))) Option {
+	return func(cfg *Config) {
+		cfg.Warmup = warmup
+	}
+}
+
+// WithWriteBack sets Config.WriteBack and Config.MaxDirty.
+func WithWriteBack(writeBack func(k string, v interface{}) error, maxDirty int) Option {
+	return func(cfg *Config) {
+		cfg.WriteBack, cfg.MaxDirty = writeBack, maxDirty
+	}
+}
+
+// WithWriteThrough sets Config.WriteThrough.
+func WithWriteThrough() Option {
+	return func(cfg *Config) {
+		cfg.WriteThrough = true
+	}
+}
+
+// WithEvictionBatch sets Config.EvictionBatch.
+func WithEvictionBatch(n int) Option {
+	return func(cfg *Config) {
+		cfg.EvictionBatch = n
+	}
+}
+
+// WithLowWatermark sets Config.LowWatermark.
+func WithLowWatermark(fraction float64) Option {
+	return func(cfg *Config) {
+		cfg.LowWatermark = fraction
+	}
+}
+
+// WithShards sets Config.Shards.
+func WithShards(n int) Option {
+	return func(cfg *Config) {
+		cfg.Shards = n
+	}
+}
@@ -0,0 +1,93 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNew_Options(t *testing.T) {
+	cache := New(3)
+	assert.Equal(t, 3, cache.cap)
+	assert.Equal(t, time.Duration(0), cache.freshness)
+	assert.Nil(t, cache.clock)
+	assert.Nil(t, cache.misses)
+	assert.Nil(t, cache.evictBefore)
+
+	clock := NewManualClock(time.Unix(0, 0))
+	cache = New(3,
+		WithFreshness(time.Second),
+		WithFreshness(time.Minute),
+		WithClock(clock),
+		WithTrackMisses(2),
+		WithEvictionComparator(func(a, b Entry) bool { return a.Key < b.Key }),
+	)
+	assert.Equal(t, 3, cache.cap)
+	assert.Equal(t, time.Minute, cache.freshness)
+	assert.Equal(t, time.Unix(0, 0), cache.now())
+	assert.Equal(t, 2, cache.misses.size)
+	assert.NotNil(t, cache.evictBefore)
+
+	cache.Set("b", 1)
+	cache.Set("a", 2)
+	cache.Evict(1)
+	_, ok := cache.kv["a"]
+	assert.False(t, ok)
+}
+
+func TestNew_WithSeed(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	expiries := func(seed int64) []time.Time {
+		cache := New(0, WithClock(clock), WithTTLJitter(1), WithSeed(seed))
+		var expiries []time.Time
+		for _, k := range []string{"a", "b", "c", "d"} {
+			cache.SetWithTTL(k, k, time.Hour)
+			expiries = append(expiries, cache.kv[k].expireAt)
+		}
+		return expiries
+	}
+
+	assert.Equal(t, expiries(7), expiries(7))
+	assert.NotEqual(t, expiries(7), expiries(8))
+}
+
+func TestNew_WithObservability(t *testing.T) {
+	cache := New(2, WithTrackMisses(100), WithObservability(ObservabilityConfig{MaxTrackedMisses: 3}))
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		cache.Get(k)
+	}
+	assert.Len(t, cache.HottestMissedKeys(10), 3)
+}
+
+func TestNew_WithCapacity(t *testing.T) {
+	assert.Equal(t, 5, New(0, WithCapacity(5)).cap)
+	assert.Equal(t, 0, New(5, WithCapacity(0)).cap)
+}
+
+func TestNew_WithWarmup(t *testing.T) {
+	src := New(0)
+	src.Set("a", 1)
+	src.Set("b", 2)
+	src.Get("b")
+
+	var evicted []string
+	cache := New(2,
+		WithReadBuffer(4),
+		WithEvictionCallback(func(k string, v interface{}, reason EvictReason) {
+			evicted = append(evicted, k)
+		}),
+		WithWarmup(func(add func(k string, v interface{})) {
+			add("c", 3)
+			for _, e := range src.Snapshot() {
+				add(e.Key, e.Value)
+			}
+		}))
+
+	// warm before New returns, within capacity
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, []string{"c"}, evicted)
+	v, ok := cache.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	assert.NoError(t, cache.Verify())
+}
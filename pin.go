@@ -0,0 +1,31 @@
+package lfu
+
+// Pin excludes k from eviction until it is unpinned: neither Evict nor a Set
+// on a full cache will choose it, even if it's the least frequently used item.
+// It reports whether k is in cache.
+//
+// When every item is pinned, Evict evicts nothing and Set stores new keys
+// anyway, so cache may outgrow its capacity until items get unpinned, while
+// SetMultiple drops them. Pinning a key doesn't stop Remove-like calls from
+// deleting it.
+func (c *Cache) Pin(k string) bool {
+	return c.setPinned(k, true)
+}
+
+// Unpin makes a pinned k a candidate for eviction again. It reports whether k
+// is in cache.
+func (c *Cache) Unpin(k string) bool {
+	return c.setPinned(k, false)
+}
+
+func (c *Cache) setPinned(k string, pinned bool) bool {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return false
+	}
+	item.pinned = pinned
+	return true
+}
@@ -0,0 +1,48 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_Pin(t *testing.T) {
+	cache := New(2)
+	assert.False(t, cache.Pin("a"))
+	assert.False(t, cache.Unpin("a"))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	assert.True(t, cache.Pin("a"))
+
+	// "a" is the sole lfu victim, but pinned
+	cache.Set("c", 3)
+	_, ok := cache.kv["a"]
+	assert.True(t, ok)
+	_, ok = cache.kv["b"]
+	assert.False(t, ok)
+
+	cache.Evict(1)
+	_, ok = cache.kv["a"]
+	assert.True(t, ok)
+	assert.Equal(t, 1, cache.Size())
+
+	// every item pinned: nothing to evict, so cache grows
+	cache.Evict(1)
+	assert.Equal(t, 1, cache.Size())
+	cache.Set("d", 4)
+	cache.Pin("d")
+	cache.Set("e", 5)
+	assert.Equal(t, 3, cache.Size())
+
+	cache.SetMultiple(map[string]interface{}{"f": 6})
+	_, ok = cache.kv["e"]
+	assert.False(t, ok)
+	assert.Equal(t, 3, cache.Size())
+
+	assert.True(t, cache.Unpin("a"))
+	cache.Evict(3)
+	assert.Equal(t, 1, cache.Size())
+	_, ok = cache.kv["d"]
+	assert.True(t, ok)
+}
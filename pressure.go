@@ -0,0 +1,90 @@
+package lfu
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// defaultHeapCheckInterval is how often the heap usage is checked when no
+// HeapCheckInterval is configured.
+const defaultHeapCheckInterval = time.Second
+
+// heapMonitor tells whether the heap is above the HeapLimit of a cache. It is
+// only used by the goroutine of startHeapWatch.
+type heapMonitor struct {
+	limit uint64
+	usage func() uint64
+	// gcs is the number of GC cycles as of the last eviction.
+	gcs     uint64
+	samples []metrics.Sample
+}
+
+func startHeapWatch(c *Cache, clock Clock, limit uint64, interval time.Duration, usage func() uint64) *janitor {
+	if interval <= 0 {
+		interval = defaultHeapCheckInterval
+	}
+	m := &heapMonitor{limit: limit, usage: usage}
+	return startJanitor(clock, interval, func() {
+		if m.over() {
+			c.EvictLeastFrequent()
+		}
+	})
+}
+
+// over reports whether the heap usage is above the limit, and, for the usage
+// read by runtime/metrics, a GC cycle ran since the last time it was.
+func (m *heapMonitor) over() bool {
+	if m.usage != nil {
+		return m.usage() > m.limit
+	}
+
+	if m.samples == nil {
+		m.samples = []metrics.Sample{
+			{Name: "/memory/classes/heap/objects:bytes"},
+			{Name: "/gc/cycles/total:gc-cycles"},
+		}
+	}
+	metrics.Read(m.samples)
+	for _, s := range m.samples {
+		if s.Value.Kind() != metrics.KindUint64 {
+			return false
+		}
+	}
+
+	heap, gcs := m.samples[0].Value.Uint64(), m.samples[1].Value.Uint64()
+	if heap <= m.limit || gcs == m.gcs {
+		return false
+	}
+	m.gcs = gcs
+	return true
+}
+
+// EvictLeastFrequent evicts every item sharing the lowest frequency count,
+// but pinned ones, and returns how many it evicted, e.g. to shed a whole
+// layer of cold items at once under memory pressure, as Config.HeapLimit
+// does. If every item of the lowest count is pinned, it evicts those of the
+// next one.
+func (c *Cache) EvictLeastFrequent() int {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	c.decayIfDue()
+	if c.window != nil {
+		c.age(c.epoch())
+	}
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		n := 0
+		for item, next := e.Value.(*freqNode).head, (*kvItem)(nil); item != nil; item = next {
+			next = item.next
+			if !item.pinned {
+				c.evictItem(item, ReasonEvicted)
+				n++
+			}
+		}
+		if n > 0 {
+			return n
+		}
+	}
+	return 0
+}
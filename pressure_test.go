@@ -0,0 +1,68 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_EvictLeastFrequent(t *testing.T) {
+	cache := New(0)
+	assert.Equal(t, 0, cache.EvictLeastFrequent())
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		cache.Set(k, k)
+	}
+	cache.Get("c")
+	cache.Get("d")
+	cache.Get("d")
+	cache.Pin("a")
+
+	assert.Equal(t, 1, cache.EvictLeastFrequent())
+	assert.ElementsMatch(t, []string{"a", "c", "d"}, cache.Keys())
+
+	// only pinned items are left at the lowest count
+	assert.Equal(t, 1, cache.EvictLeastFrequent())
+	assert.ElementsMatch(t, []string{"a", "d"}, cache.Keys())
+	assert.Equal(t, uint64(2), cache.Stats().Evictions)
+	assert.Nil(t, cache.Verify())
+}
+
+func TestCache_HeapLimit(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	var usage uint64 = 10
+	cache := New(0,
+		WithClock(clock),
+		WithHeapLimit(10, time.Second),
+		WithHeapUsage(func() uint64 { return atomic.LoadUint64(&usage) }),
+	)
+	defer cache.Close()
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+
+	// at the limit is not above it
+	clock.Advance(time.Second)
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, 2, cache.Size())
+
+	atomic.StoreUint64(&usage, 11)
+	clock.Advance(time.Second)
+	assert.Eventually(t, func() bool { return cache.Size() == 1 }, time.Second, time.Millisecond)
+	_, ok := cache.Peek("b")
+	assert.True(t, ok)
+}
+
+func TestHeapMonitor(t *testing.T) {
+	m := &heapMonitor{limit: 1}
+	// the heap of a running test is above a byte, but the first check
+	// still needs a GC cycle to compare with
+	m.gcs = ^uint64(0)
+	assert.True(t, m.over())
+	assert.False(t, m.over())
+
+	m = &heapMonitor{limit: ^uint64(0)}
+	assert.False(t, m.over())
+}
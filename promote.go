@@ -0,0 +1,74 @@
+package lfu
+
+type promoteHook struct {
+	threshold int
+	fn        func(k string, v interface{})
+}
+
+type reachTrigger struct {
+	freq int
+	fn   func()
+}
+
+// OnPromote registers fn to be called when an item's frequency count rises
+// from below threshold to threshold or above, e.g. to copy hot items to a
+// faster tier. fn is called once per crossing, not on every access above
+// threshold, and runs after the lock is released, so it may use cache.
+func (c *Cache) OnPromote(threshold int, fn func(k string, v interface{})) {
+	c.lock()
+	defer c.unlock()
+
+	c.promoteHooks = append(c.promoteHooks, promoteHook{threshold: threshold, fn: fn})
+}
+
+// OnReach registers fn to be called once, the first time the frequency count
+// of k reaches freq, e.g. to enable something for keys proven popular. Like
+// OnPromote, it fires on accesses moving k up rather than on k being stored
+// at freq, and fn runs after the lock is released. If k is already at freq or
+// above, fn is called right away. The trigger is dropped once fired, or when
+// k leaves the cache.
+func (c *Cache) OnReach(k string, freq int, fn func()) {
+	c.lock()
+	defer c.unlock()
+
+	k = c.key(k)
+	if item, ok := c.lookup(k); ok && item.parent.Value.(*freqNode).freq >= freq {
+		c.after = append(c.after, fn)
+		return
+	}
+	if c.reach == nil {
+		c.reach = make(map[string][]reachTrigger)
+	}
+	c.reach[k] = append(c.reach[k], reachTrigger{freq: freq, fn: fn})
+}
+
+// promoted queues the hooks whose threshold item crossed by moving up from
+// freq from, and the triggers of item it reached. The caller must hold the
+// lock.
+func (c *Cache) promoted(item *kvItem, from int) {
+	to := item.parent.Value.(*freqNode).freq
+	for _, h := range c.promoteHooks {
+		if from < h.threshold && to >= h.threshold {
+			fn, k, v := h.fn, item.k, item.v
+			c.after = append(c.after, func() { fn(k, v) })
+		}
+	}
+
+	triggers, ok := c.reach[item.k]
+	if !ok {
+		return
+	}
+	pending := triggers[:0]
+	for _, tr := range triggers {
+		if to >= tr.freq {
+			c.after = append(c.after, tr.fn)
+		} else {
+			pending = append(pending, tr)
+		}
+	}
+	if len(pending) == 0 {
+		delete(c.reach, item.k)
+	} else {
+		c.reach[item.k] = pending
+	}
+}
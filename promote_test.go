@@ -0,0 +1,82 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_OnPromote(t *testing.T) {
+	cache := New(0)
+
+	var promoted []string
+	cache.OnPromote(3, func(k string, v interface{}) {
+		promoted = append(promoted, k)
+		// callbacks run outside the lock
+		cache.Set(k+"-hot", v)
+	})
+
+	cache.Set("a", 1)
+	cache.Get("a")
+	assert.Nil(t, promoted)
+
+	cache.Get("a")
+	assert.Equal(t, []string{"a"}, promoted)
+
+	for i := 0; i < 5; i++ {
+		cache.Get("a")
+	}
+	assert.Equal(t, []string{"a"}, promoted)
+	_, ok := cache.kv["a-hot"]
+	assert.True(t, ok)
+
+	// jumping past the threshold is a crossing too, moving down or being
+	// inserted above it is not
+	cache.Set("b", 2)
+	cache.SetWithFrequency("b", 2, 10)
+	cache.SetWithFrequency("b", 2, 1)
+	cache.SetWithFrequency("c", 3, 10)
+	assert.Equal(t, []string{"a", "b"}, promoted)
+
+	cache.ResetFrequencies()
+	cache.Get("c")
+	cache.Get("c")
+	assert.Equal(t, []string{"a", "b", "c"}, promoted)
+}
+
+func TestCache_OnReach(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+
+	fired := 0
+	cache.OnReach("a", 3, func() { fired++ })
+	cache.Get("b")
+	cache.Get("b")
+	assert.Equal(t, 0, fired)
+
+	cache.Get("a")
+	assert.Equal(t, 0, fired)
+	cache.Get("a")
+	assert.Equal(t, 1, fired)
+	cache.Get("a")
+	cache.TouchBy("a", 5)
+	assert.Equal(t, 1, fired)
+	assert.Empty(t, cache.reach)
+
+	// already reached
+	cache.OnReach("a", 2, func() { fired++ })
+	assert.Equal(t, 2, fired)
+
+	// dropped along with its key, even if the key comes back
+	cache.OnReach("b", 5, func() { fired++ })
+	cache.Remove("b")
+	cache.Set("b", 2)
+	cache.TouchBy("b", 10)
+	assert.Equal(t, 2, fired)
+
+	// fn may use cache
+	cache.Set("c", 3)
+	cache.OnReach("c", 2, func() { cache.Remove("c") })
+	cache.Get("c")
+	assert.Equal(t, 2, cache.Size())
+}
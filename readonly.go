@@ -0,0 +1,36 @@
+package lfu
+
+// ReadOnlyLFU is the part of a cache that reads it, for code that must not
+// store or remove anything.
+type ReadOnlyLFU interface {
+	// Get returns the v related to k, counting it as an access.
+	Get(k string) (v interface{}, ok bool)
+	// Peek returns the v related to k, without counting it as an access.
+	Peek(k string) (v interface{}, ok bool)
+	// Contains reports whether k is in cache.
+	Contains(k string) bool
+	// Size returns the number of items.
+	Size() int
+	// Keys returns the keys in cache.
+	Keys() []string
+}
+
+// ReadOnly returns a view of cache limited to ReadOnlyLFU, which sees every
+// later change of cache. Read-only means the view can't store, remove or
+// evict anything: its Get still counts as an access, raising the frequency
+// of k like Get of cache does, and lookups still drop expired entries.
+func (c *Cache) ReadOnly() ReadOnlyLFU {
+	return readOnly{c: c}
+}
+
+// readOnly hides the methods of Cache beyond ReadOnlyLFU, so the view can't
+// be asserted back to a *Cache.
+type readOnly struct {
+	c *Cache
+}
+
+func (r readOnly) Get(k string) (interface{}, bool)  { return r.c.Get(k) }
+func (r readOnly) Peek(k string) (interface{}, bool) { return r.c.Peek(k) }
+func (r readOnly) Contains(k string) bool            { return r.c.Contains(k) }
+func (r readOnly) Size() int                         { return r.c.Size() }
+func (r readOnly) Keys() []string                    { return r.c.Keys() }
@@ -0,0 +1,50 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_PeekContains(t *testing.T) {
+	cache := New(0)
+	_, ok := cache.Peek("a")
+	assert.False(t, ok)
+	assert.False(t, cache.Contains("a"))
+
+	cache.Set("a", 1)
+	v, ok := cache.Peek("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.True(t, cache.Contains("a"))
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, uint64(0), cache.lookups())
+}
+
+func TestCache_ReadOnly(t *testing.T) {
+	cache := New(0)
+	view := cache.ReadOnly()
+	assert.Equal(t, 0, view.Size())
+
+	// live updates
+	cache.Set("a", 1)
+	assert.Equal(t, 1, view.Size())
+	assert.True(t, view.Contains("a"))
+	assert.Equal(t, []string{"a"}, view.Keys())
+	v, ok := view.Peek("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// Get still counts as an access
+	v, ok = view.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	// no way to mutate through the view
+	_, ok = view.(LFU)
+	assert.False(t, ok)
+	_, ok = view.(*Cache)
+	assert.False(t, ok)
+	_, ok = view.(interface{ Remove(k string) bool })
+	assert.False(t, ok)
+}
@@ -0,0 +1,155 @@
+package lfu
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// readBuffer lets Get find values without the lock of cache, see
+// Config.ReadBuffer. index maps the normalized keys in cache to a copy of
+// their value, written under the lock whenever an item is stored or removed.
+// The items hit there are appended to one of the stripes, each with its own
+// lock, and their hits applied under the lock of cache once a stripe is
+// full.
+type readBuffer struct {
+	index   sync.Map
+	stripes []readStripe
+	size    int
+	next    uint32
+}
+
+// readEntry is what index holds for a key. It is never modified, but
+// replaced as a whole. item is only used under the lock of cache.
+type readEntry struct {
+	v        interface{}
+	expireAt time.Time
+	item     *kvItem
+}
+
+type readStripe struct {
+	sync.Mutex
+	items []*kvItem
+	// spare is a drained batch of items, handed back for reuse.
+	spare []*kvItem
+}
+
+func newReadBuffer(size int) *readBuffer {
+	b := &readBuffer{
+		stripes: make([]readStripe, 4*runtime.GOMAXPROCS(0)),
+		size:    size,
+	}
+	for i := range b.stripes {
+		b.stripes[i].items = make([]*kvItem, 0, size)
+	}
+	return b
+}
+
+// record buffers a hit of item, and returns the stripe it went to with its
+// items, if that made it full, for the caller to apply and hand back.
+func (b *readBuffer) record(item *kvItem) (*readStripe, []*kvItem) {
+	s := &b.stripes[atomic.AddUint32(&b.next, 1)%uint32(len(b.stripes))]
+	s.Lock()
+	defer s.Unlock()
+
+	s.items = append(s.items, item)
+	if len(s.items) < b.size {
+		return nil, nil
+	}
+	full := s.items
+	s.items, s.spare = s.spare, nil
+	if s.items == nil {
+		s.items = make([]*kvItem, 0, b.size)
+	}
+	return s, full
+}
+
+// handBack returns the items drained from s for reuse.
+func (s *readStripe) handBack(items []*kvItem) {
+	for i := range items {
+		items[i] = nil
+	}
+	s.Lock()
+	s.spare = items[:0]
+	s.Unlock()
+}
+
+// getBuffered looks k up in the ReadBuffer index, without the lock, which
+// CloneFunc runs without too. ok is false if k isn't there or expired, for
+// Get to look it up under the lock.
+func (c *Cache) getBuffered(k string) (v interface{}, ok bool) {
+	b := c.reads
+	k = c.key(k)
+	e, ok := b.index.Load(k)
+	if !ok {
+		return nil, false
+	}
+	entry := e.(*readEntry)
+	if !entry.expireAt.IsZero() && !c.now().Before(entry.expireAt) {
+		return nil, false
+	}
+
+	if s, items := b.record(entry.item); s != nil {
+		c.lock()
+		c.applyReads(items)
+		c.unlock()
+		s.handBack(items)
+	}
+	return c.clone(entry.v), true
+}
+
+// applyReads counts the buffered hits of items as the hits of Get, dropping
+// those of items gone since. The caller must hold the lock.
+func (c *Cache) applyReads(items []*kvItem) {
+	for _, item := range items {
+		if c.kv[item.k] == item {
+			c.hit(item)
+		}
+	}
+}
+
+// drainReads applies the hits buffered by every stripe. The caller must hold
+// the lock.
+func (c *Cache) drainReads() {
+	b := c.reads
+	if b == nil {
+		return
+	}
+	for i := range b.stripes {
+		s := &b.stripes[i]
+		s.Lock()
+		c.applyReads(s.items)
+		for j := range s.items {
+			s.items[j] = nil
+		}
+		s.items = s.items[:0]
+		s.Unlock()
+	}
+}
+
+// publish stores the value and expiry of item in the ReadBuffer index. The
+// caller must hold the lock.
+func (c *Cache) publish(item *kvItem) {
+	if c.reads != nil {
+		c.reads.index.Store(item.k, &readEntry{v: item.v, expireAt: item.expireAt, item: item})
+	}
+}
+
+// unpublishAll empties the ReadBuffer index. The caller must hold the lock.
+func (c *Cache) unpublishAll() {
+	if c.reads != nil {
+		c.reads.index.Range(func(k, _ interface{}) bool {
+			c.reads.index.Delete(k)
+			return true
+		})
+	}
+}
+
+// unpublish deletes k from the ReadBuffer index. The caller must hold the
+// lock.
+func (c *Cache) unpublish(k string) {
+	if c.reads != nil {
+		c.reads.index.Delete(k)
+	}
+}
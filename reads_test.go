@@ -0,0 +1,99 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCache_ReadBuffer(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithReadBuffer(4), WithClock(clock))
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, time.Second)
+
+	// hits are found without the lock, and counted once flushed
+	for i := 0; i < 3; i++ {
+		v, ok := cache.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+	}
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 1, freq)
+	assert.Equal(t, uint64(0), cache.Stats().Hits)
+	cache.Flush()
+	freq, _ = cache.GetFrequency("a")
+	assert.Equal(t, 4, freq)
+	assert.Equal(t, uint64(3), cache.Stats().Hits)
+
+	// stores and removals reach the index
+	cache.Set("a", 10)
+	v, _ := cache.Get("a")
+	assert.Equal(t, 10, v)
+	cache.Remove("a")
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), cache.Stats().Misses)
+
+	// an expired key takes the lock to be removed
+	v, ok = cache.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	clock.Advance(time.Second)
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.Size())
+	assert.NoError(t, cache.Verify())
+
+	cache.Set("c", 3)
+	cache.Reset(0)
+	_, ok = cache.Get("c")
+	assert.False(t, ok)
+	cache.Flush()
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_ReadBufferFull(t *testing.T) {
+	cache := New(2, WithReadBuffer(1))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+
+	// a buffer of one applies every hit right away
+	cache.Get("a")
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 2, freq)
+	cache.Set("c", 3)
+	_, ok := cache.Get("b")
+	assert.False(t, ok)
+}
+
+func TestCache_ReadBufferConcurrent(t *testing.T) {
+	cache := New(0, WithReadBuffer(8))
+	for i := 0; i < 10; i++ {
+		cache.Set(strconv.Itoa(i), i)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				k := strconv.Itoa(i % 10)
+				if v, ok := cache.Get(k); ok {
+					assert.Equal(t, i%10, v)
+				}
+				if i%100 == g {
+					cache.Set(k, i%10)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	cache.Flush()
+	assert.Equal(t, uint64(8000), cache.Stats().Hits)
+	assert.NoError(t, cache.Verify())
+}
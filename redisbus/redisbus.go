@@ -0,0 +1,387 @@
+// Package redisbus is an lfu.Invalidator over the pub/sub of a Redis server,
+// so the replicas of a cache spread over several processes invalidate each
+// other's copies.
+//
+// It doesn't depend on a Redis client: the few commands it sends are written
+// in the RESP protocol over a plain connection.
+package redisbus
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ZhengHe-MD/lfu"
+)
+
+// Bus publishes Invalidations to a Redis channel, as JSON, and subscribes to
+// it. Publish sends PUBLISH over a connection it doesn't share with a
+// concurrent Publish, taken from a few idle ones kept around or dialed on
+// demand, and closed after an error, and every Subscribe opens another one
+// for SUBSCRIBE, redialed after it broke. Invalidations published while a
+// subscription is down are lost for it, as they are with Redis pub/sub.
+type Bus struct {
+	channel  string
+	dial     func() (net.Conn, error)
+	password string
+	retry    time.Duration
+	timeout  time.Duration
+
+	mu   sync.Mutex
+	idle []*conn
+}
+
+// maxIdle is how many idle connections a Bus keeps for Publish.
+const maxIdle = 4
+
+var _ lfu.Invalidator = (*Bus)(nil)
+
+// Option configures a Bus.
+type Option func(b *Bus)
+
+// WithPassword makes Bus authenticate every connection with AUTH password.
+func WithPassword(password string) Option {
+	return func(b *Bus) {
+		b.password = password
+	}
+}
+
+// WithDialer makes Bus open its connections with dial instead of dialing
+// TCP, e.g. to use TLS.
+func WithDialer(dial func() (net.Conn, error)) Option {
+	return func(b *Bus) {
+		b.dial = dial
+	}
+}
+
+// WithRetryInterval sets how long a broken subscription waits before
+// redialing, one second by default.
+func WithRetryInterval(d time.Duration) Option {
+	return func(b *Bus) {
+		b.retry = d
+	}
+}
+
+// WithTimeout sets how long Bus waits for the server to answer a command,
+// and to accept a TCP connection, five seconds by default. A non-positive d
+// waits forever. It doesn't bound how long a subscription waits for
+// messages.
+func WithTimeout(d time.Duration) Option {
+	return func(b *Bus) {
+		b.timeout = d
+	}
+}
+
+// New creates a Bus over the channel of the Redis server at addr.
+func New(addr, channel string, opts ...Option) *Bus {
+	b := &Bus{
+		channel: channel,
+		retry:   time.Second,
+		timeout: 5 * time.Second,
+	}
+	b.dial = func() (net.Conn, error) {
+		if b.timeout <= 0 {
+			return net.Dial("tcp", addr)
+		}
+		return net.DialTimeout("tcp", addr, b.timeout)
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// Publish sends inv to every subscriber of the channel. It fails once the
+// server hasn't answered within the timeout of WithTimeout, without holding
+// up the other callers of Publish meanwhile.
+func (b *Bus) Publish(inv lfu.Invalidation) error {
+	payload, err := json.Marshal(inv)
+	if err != nil {
+		return err
+	}
+
+	c := b.take()
+	if c == nil {
+		if c, err = b.connect(); err != nil {
+			return err
+		}
+	}
+	if _, err = c.do("PUBLISH", b.channel, string(payload)); err != nil {
+		c.Close()
+		return err
+	}
+	b.put(c)
+	return nil
+}
+
+// Close closes the idle connections of Publish. Subscriptions are closed
+// by their stop, and a later Publish dials again.
+func (b *Bus) Close() error {
+	b.mu.Lock()
+	idle := b.idle
+	b.idle = nil
+	b.mu.Unlock()
+
+	for _, c := range idle {
+		c.Close()
+	}
+	return nil
+}
+
+// take returns an idle connection, or nil if there is none.
+func (b *Bus) take() *conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.idle)
+	if n == 0 {
+		return nil
+	}
+	c := b.idle[n-1]
+	b.idle = b.idle[:n-1]
+	return c
+}
+
+// put keeps c for a later Publish, or closes it if enough are idle.
+func (b *Bus) put(c *conn) {
+	b.mu.Lock()
+	if len(b.idle) < maxIdle {
+		b.idle = append(b.idle, c)
+		c = nil
+	}
+	b.mu.Unlock()
+
+	if c != nil {
+		c.Close()
+	}
+}
+
+// Subscribe subscribes to the channel, then calls fn from a goroutine with
+// every Invalidation received, until stop is called.
+func (b *Bus) Subscribe(fn func(inv lfu.Invalidation)) (stop func() error, err error) {
+	c, err := b.subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &subscription{conn: c, done: make(chan struct{}), stopped: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		for {
+			s.receive(fn)
+			select {
+			case <-s.stopped:
+				return
+			case <-time.After(b.retry):
+			}
+			if c, err := b.subscribe(); err == nil && !s.swap(c) {
+				return
+			}
+		}
+	}()
+	return s.stop, nil
+}
+
+// subscribe opens a connection subscribed to the channel.
+func (b *Bus) subscribe() (*conn, error) {
+	c, err := b.connect()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.do("SUBSCRIBE", b.channel); err != nil {
+		c.Close()
+		return nil, err
+	}
+	// messages may be a long time coming
+	c.timeout = 0
+	if err := c.SetDeadline(time.Time{}); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// connect dials a connection, authenticated if need be.
+func (b *Bus) connect() (*conn, error) {
+	nc, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{Conn: nc, r: bufio.NewReader(nc), timeout: b.timeout}
+	if b.password != "" {
+		if _, err := c.do("AUTH", b.password); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+type subscription struct {
+	mu      sync.Mutex
+	conn    *conn
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// receive calls fn with the messages of the connection until it breaks or
+// gets closed by stop.
+func (s *subscription) receive(fn func(inv lfu.Invalidation)) {
+	s.mu.Lock()
+	c := s.conn
+	s.mu.Unlock()
+	if c == nil {
+		return
+	}
+
+	for {
+		v, err := c.read()
+		if err != nil {
+			c.Close()
+			return
+		}
+		// a message is ["message", channel, payload]
+		msg, ok := v.([]interface{})
+		if !ok || len(msg) != 3 || string(bulk(msg[0])) != "message" {
+			continue
+		}
+		var inv lfu.Invalidation
+		if json.Unmarshal(bulk(msg[2]), &inv) == nil {
+			fn(inv)
+		}
+	}
+}
+
+// swap replaces the broken connection with c, or closes c and returns false
+// if the subscription was stopped meanwhile.
+func (s *subscription) swap(c *conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.stopped:
+		c.Close()
+		return false
+	default:
+		s.conn = c
+		return true
+	}
+}
+
+func (s *subscription) stop() error {
+	s.once.Do(func() {
+		s.mu.Lock()
+		close(s.stopped)
+		s.conn.Close()
+		s.mu.Unlock()
+	})
+	<-s.done
+	return nil
+}
+
+// conn is a connection to Redis speaking RESP.
+type conn struct {
+	net.Conn
+	r       *bufio.Reader
+	timeout time.Duration
+}
+
+// do sends the command args and returns its reply, or the error Redis
+// replied with, failing if that takes longer than the timeout of c.
+func (c *conn) do(args ...string) (interface{}, error) {
+	if c.timeout > 0 {
+		if err := c.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+			return nil, err
+		}
+	}
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(arg)), 10)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+
+	v, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if rerr, ok := v.(redisError); ok {
+		return nil, rerr
+	}
+	return v, nil
+}
+
+// redisError is an error replied by Redis.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redisbus: " + string(e)
+}
+
+// read reads a RESP value: a string, a redisError, an int64, a []byte or nil
+// for a bulk string, or a []interface{} for an array.
+func (c *conn) read() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redisbus: malformed reply")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return redisError(line), nil
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("redisbus: unknown reply type %q", kind)
+}
+
+// bulk returns v as bytes, for the bulk strings of a message.
+func bulk(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package redisbus
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ZhengHe-MD/lfu"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRedis serves the pub/sub commands of Redis, for one channel.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu   sync.Mutex
+	subs map[net.Conn]struct{}
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no loopback:", err)
+	}
+	s := &fakeRedis{ln: ln, password: password, subs: make(map[net.Conn]struct{})}
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(nc)
+		}
+	}()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// kick closes the connections of the subscribers.
+func (s *fakeRedis) kick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for nc := range s.subs {
+		nc.Close()
+		delete(s.subs, nc)
+	}
+}
+
+func (s *fakeRedis) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs)
+}
+
+func (s *fakeRedis) serve(nc net.Conn) {
+	defer nc.Close()
+	c := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	authed := s.password == ""
+	for {
+		v, err := c.read()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range v.([]interface{}) {
+			args = append(args, string(bulk(arg)))
+		}
+
+		switch {
+		case args[0] == "AUTH":
+			authed = args[1] == s.password
+			if !authed {
+				nc.Write([]byte("-WRONGPASS invalid password\r\n"))
+				continue
+			}
+			nc.Write([]byte("+OK\r\n"))
+		case !authed:
+			nc.Write([]byte("-NOAUTH Authentication required.\r\n"))
+		case args[0] == "SUBSCRIBE":
+			s.mu.Lock()
+			s.subs[nc] = struct{}{}
+			s.mu.Unlock()
+			nc.Write(array("subscribe", args[1], ":1"))
+		case args[0] == "PUBLISH":
+			s.mu.Lock()
+			for sub := range s.subs {
+				sub.Write(array("message", args[1], args[2]))
+			}
+			n := len(s.subs)
+			s.mu.Unlock()
+			nc.Write([]byte(":" + strconv.Itoa(n) + "\r\n"))
+		}
+	}
+}
+
+// array encodes a RESP array of bulk strings, or integers for the ones
+// starting with ':'.
+func array(items ...string) []byte {
+	buf := []byte("*" + strconv.Itoa(len(items)) + "\r\n")
+	for _, item := range items {
+		if item[0] == ':' {
+			buf = append(buf, item+"\r\n"...)
+			continue
+		}
+		buf = append(buf, "$"+strconv.Itoa(len(item))+"\r\n"+item+"\r\n"...)
+	}
+	return buf
+}
+
+func TestBus(t *testing.T) {
+	s := newFakeRedis(t, "secret")
+	addr := s.ln.Addr().String()
+
+	_, err := New(addr, "inv").Subscribe(func(inv lfu.Invalidation) {})
+	assert.EqualError(t, err, "redisbus: NOAUTH Authentication required.")
+
+	bus := New(addr, "inv", WithPassword("secret"), WithRetryInterval(time.Millisecond))
+	received := make(chan lfu.Invalidation, 10)
+	stop, err := bus.Subscribe(func(inv lfu.Invalidation) { received <- inv })
+	assert.NoError(t, err)
+
+	assert.NoError(t, bus.Publish(lfu.Invalidation{Key: "k", Origin: "a"}))
+	assert.Equal(t, lfu.Invalidation{Key: "k", Origin: "a"}, <-received)
+
+	// a broken subscription resubscribes
+	s.kick()
+	assert.Eventually(t, func() bool { return s.subscribers() == 1 }, time.Second, time.Millisecond)
+	assert.NoError(t, bus.Publish(lfu.Invalidation{Tag: "t", Origin: "a"}))
+	assert.Equal(t, lfu.Invalidation{Tag: "t", Origin: "a"}, <-received)
+
+	assert.NoError(t, stop())
+	assert.NoError(t, stop())
+}
+
+func TestBus_Replicated(t *testing.T) {
+	s := newFakeRedis(t, "")
+	bus := New(s.ln.Addr().String(), "inv")
+
+	a, err := lfu.NewReplicated(lfu.New(0), bus)
+	assert.NoError(t, err)
+	defer a.Close()
+	b, err := lfu.NewReplicated(lfu.New(0), New(s.ln.Addr().String(), "inv"))
+	assert.NoError(t, err)
+	defer b.Close()
+
+	b.Local().Set("k", 1)
+	assert.NoError(t, a.Set("k", 2))
+	assert.Eventually(t, func() bool {
+		_, ok := b.Get("k")
+		return !ok
+	}, time.Second, time.Millisecond)
+}
+
+func TestBus_Timeout(t *testing.T) {
+	// a server that accepts connections but never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no loopback:", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer nc.Close()
+		}
+	}()
+
+	bus := New(ln.Addr().String(), "inv", WithTimeout(100*time.Millisecond))
+	start := time.Now()
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() { errs <- bus.Publish(lfu.Invalidation{Key: "k"}) }()
+	}
+	for i := 0; i < 3; i++ {
+		err := <-errs
+		var nerr net.Error
+		assert.True(t, errors.As(err, &nerr) && nerr.Timeout(), err)
+	}
+	// the publishers waited side by side, not one after the other
+	assert.True(t, time.Since(start) < 250*time.Millisecond)
+	assert.NoError(t, bus.Close())
+}
+
+func TestBus_ReusesConnections(t *testing.T) {
+	s := newFakeRedis(t, "")
+	var dials int
+	bus := New(s.ln.Addr().String(), "inv", WithDialer(func() (net.Conn, error) {
+		dials++
+		return net.Dial("tcp", s.ln.Addr().String())
+	}))
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, bus.Publish(lfu.Invalidation{Key: "k"}))
+	}
+	assert.Equal(t, 1, dials)
+
+	assert.NoError(t, bus.Close())
+	assert.NoError(t, bus.Publish(lfu.Invalidation{Key: "k"}))
+	assert.Equal(t, 2, dials)
+}
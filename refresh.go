@@ -0,0 +1,138 @@
+package lfu
+
+import (
+	"context"
+	"time"
+)
+
+// GetWithRefresh works like GetOrLoad, and also reloads a value older than
+// its RefreshAfter in the background, returning the cached value meanwhile,
+// so callers of a slow backend never wait for it once k is cached. A
+// refresh stores the new value without counting it as an access, honors the
+// circuit breaker and MaxConcurrentLoads like GetOrLoad, and leaves the old
+// value in place if loader fails or panics, or drops the new one if k is no
+// longer in cache by then, or was written meanwhile, as the load may predate
+// that write. The new value keeps the metadata, tags and expiry of the old
+// one, as it stands for the same record. A given k is refreshed by one call
+// at a time. A dirty entry of SetDirty isn't refreshed, so the store
+// doesn't overwrite what it has yet to be written, nor is one that got dirty
+// while its refresh was running.
+func (c *Cache) GetWithRefresh(k string, loader func(k string) (interface{}, error)) (interface{}, error) {
+	c.lock()
+	key := c.key(k)
+	item, ok := c.lookup(key)
+	if !ok {
+		c.miss(key)
+		c.unlock()
+		return c.loadOnce(context.Background(), k, withoutContext(loader))
+	}
+
+	v := c.clone(item.v)
+	c.hit(item)
+	refresh := c.startRefresh(item)
+	c.unlock()
+
+	if refresh {
+		go c.refresh(k, key, loader)
+	}
+	return loaded(v)
+}
+
+// SetRefreshAfter sets the age after which GetWithRefresh refreshes k,
+// overriding Config.RefreshAfter for as long as k stays in cache. A
+// non-positive d falls back on Config.RefreshAfter. It reports whether k
+// is in cache.
+func (c *Cache) SetRefreshAfter(k string, d time.Duration) bool {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if ok {
+		item.refresh = d
+	}
+	return ok
+}
+
+// startRefresh reports whether item is due for a refresh that nothing is
+// running yet and a worker is free for, and if so, takes the worker. The
+// caller must hold the lock.
+func (c *Cache) startRefresh(item *kvItem) bool {
+	after := item.refresh
+	if after <= 0 {
+		after = c.refreshAfter
+	}
+	if after <= 0 || item.dirty != 0 || c.now().Sub(item.updatedAt) < after {
+		return false
+	}
+	if _, ok := c.refreshes[item.k]; ok {
+		return false
+	}
+
+	if c.refreshSlots == nil {
+		workers := c.refreshWorkers
+		if workers <= 0 {
+			workers = 1
+		}
+		c.refreshSlots = make(chan struct{}, workers)
+	}
+	select {
+	case c.refreshSlots <- placeholder:
+	default:
+		return false
+	}
+	if c.refreshes == nil {
+		c.refreshes = make(map[string]*kvItem)
+	}
+	c.refreshes[item.k] = item
+	return true
+}
+
+// refresh loads k, whose normalized key is key, and stores its new value if
+// k still holds the value it had when the refresh started, then frees the
+// worker taken by startRefresh.
+func (c *Cache) refresh(k, key string, loader func(k string) (interface{}, error)) {
+	defer func() {
+		c.lock()
+		delete(c.refreshes, key)
+		<-c.refreshSlots
+		c.unlock()
+	}()
+
+	if err := c.admitLoad(k); err != nil {
+		return
+	}
+	if c.loadSlots != nil {
+		c.loadSlots <- placeholder
+		defer func() { <-c.loadSlots }()
+	}
+	v, err := c.refreshLoad(k, loader)
+	if err != nil {
+		return
+	}
+
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	if item, ok := c.lookup(key); ok && item == c.refreshes[key] && item.dirty == 0 {
+		meta, tags, expireAt := item.meta, item.tags, item.expireAt
+		c.update(item, v, measured)
+		item.meta = meta
+		c.tag(item, tags)
+		item.expireAt = expireAt
+		c.expireAfter(item)
+		c.publish(item)
+		c.fitBytes(item)
+	}
+}
+
+// refreshLoad calls loader for k, recovering from a panic of loader, which
+// unlike one in GetOrLoad has no caller to go to, as ErrLoaderPanicked.
+func (c *Cache) refreshLoad(k string, loader func(k string) (interface{}, error)) (v interface{}, err error) {
+	defer func() {
+		if recover() != nil {
+			v, err = nil, ErrLoaderPanicked
+		}
+	}()
+	return c.callLoader(k, func() (interface{}, error) { return loader(k) })
+}
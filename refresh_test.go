@@ -0,0 +1,181 @@
+package lfu
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_GetWithRefresh(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithRefreshAfter(time.Minute, 1))
+
+	var loads int32
+	release := make(chan struct{})
+	loader := func(k string) (interface{}, error) {
+		n := atomic.AddInt32(&loads, 1)
+		if n > 1 {
+			<-release
+		}
+		return int(n), nil
+	}
+
+	// a miss loads in the foreground
+	v, err := cache.GetWithRefresh("a", loader)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+
+	// a fresh value isn't refreshed
+	clock.Advance(time.Minute - time.Nanosecond)
+	v, _ = cache.GetWithRefresh("a", loader)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// a stale one is returned right away, and refreshed once
+	clock.Advance(time.Nanosecond)
+	for i := 0; i < 3; i++ {
+		v, _ = cache.GetWithRefresh("a", loader)
+		assert.Equal(t, 1, v)
+	}
+	close(release)
+	assert.Eventually(t, func() bool {
+		v, _ := cache.Peek("a")
+		return v == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&loads))
+
+	// storing the refreshed value isn't an access
+	freq, _ := cache.GetFrequency("a")
+	assert.Equal(t, 5, freq)
+	assert.Nil(t, cache.Verify())
+}
+
+func TestCache_GetWithRefreshFailure(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	cache.Set("a", 1)
+	clock.Advance(time.Hour)
+
+	// without a RefreshAfter, nothing is refreshed
+	failed := make(chan struct{}, 1)
+	loader := func(k string) (interface{}, error) {
+		failed <- placeholder
+		return nil, errors.New("failed")
+	}
+	v, err := cache.GetWithRefresh("a", loader)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, v)
+	assert.Empty(t, failed)
+
+	// a failed refresh keeps the old value
+	assert.True(t, cache.SetRefreshAfter("a", time.Second))
+	assert.False(t, cache.SetRefreshAfter("b", time.Second))
+	cache.GetWithRefresh("a", loader)
+	<-failed
+	assert.Eventually(t, func() bool {
+		cache.lock()
+		defer cache.unlock()
+		return len(cache.refreshes) == 0
+	}, time.Second, time.Millisecond)
+	v, _ = cache.Peek("a")
+	assert.Equal(t, 1, v)
+
+	// a miss returns the error of the loader
+	_, err = cache.GetWithRefresh("b", loader)
+	assert.EqualError(t, err, "failed")
+}
+
+func TestCache_GetWithRefreshPanic(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithRefreshAfter(time.Minute, 1), WithCircuitBreaker(1, time.Hour))
+	cache.Set("a", 1)
+	clock.Advance(time.Minute)
+
+	panicked := make(chan struct{})
+	v, err := cache.GetWithRefresh("a", func(k string) (interface{}, error) {
+		close(panicked)
+		panic("boom")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, v)
+	<-panicked
+	assert.Eventually(t, func() bool {
+		cache.lock()
+		defer cache.unlock()
+		return len(cache.refreshes) == 0
+	}, time.Second, time.Millisecond)
+
+	// the old value stays, and the panic counts as a failure
+	v, _ = cache.Peek("a")
+	assert.Equal(t, 1, v)
+	cache.Remove("a")
+	_, err = cache.GetOrLoad("a", func(k string) (interface{}, error) { return 2, nil })
+	assert.Equal(t, ErrCircuitOpen, err)
+}
+
+func TestCache_GetWithRefreshKeepsTags(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithRefreshAfter(time.Minute, 1))
+	cache.SetWithTags("a", 1, "t")
+	cache.lock()
+	cache.kv["a"].meta = map[string]string{"etag": "x"}
+	cache.unlock()
+	clock.Advance(time.Minute)
+
+	cache.GetWithRefresh("a", func(k string) (interface{}, error) { return 2, nil })
+	assert.Eventually(t, func() bool {
+		v, _ := cache.Peek("a")
+		return v == 2
+	}, time.Second, time.Millisecond)
+	meta, _ := cache.GetMeta("a")
+	assert.Equal(t, map[string]string{"etag": "x"}, meta)
+	tags, _ := cache.GetTags("a")
+	assert.Equal(t, []string{"t"}, tags)
+	assert.Equal(t, 1, cache.InvalidateTag("t"))
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_GetWithRefreshKeepsTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithDefaultTTL(time.Hour), WithRefreshAfter(time.Minute, 1))
+	cache.SetWithTTL("a", 1, 2*time.Minute)
+	clock.Advance(time.Minute)
+
+	cache.GetWithRefresh("a", func(k string) (interface{}, error) { return 2, nil })
+	assert.Eventually(t, func() bool {
+		v, _ := cache.Peek("a")
+		return v == 2
+	}, time.Second, time.Millisecond)
+	e, _ := cache.GetEntry("a")
+	assert.Equal(t, time.Unix(120, 0), e.ExpiresAt)
+
+	clock.Advance(time.Minute)
+	_, ok := cache.Peek("a")
+	assert.False(t, ok)
+}
+
+func TestCache_GetWithRefreshLosesToSet(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithRefreshAfter(time.Minute, 1))
+	cache.Set("a", 1)
+	clock.Advance(time.Minute)
+
+	loading, set := make(chan struct{}), make(chan struct{})
+	cache.GetWithRefresh("a", func(k string) (interface{}, error) {
+		close(loading)
+		<-set
+		return 2, nil
+	})
+	<-loading
+	cache.Set("a", 3)
+	close(set)
+	assert.Eventually(t, func() bool {
+		cache.lock()
+		defer cache.unlock()
+		return len(cache.refreshes) == 0
+	}, time.Second, time.Millisecond)
+	v, _ := cache.Peek("a")
+	assert.Equal(t, 3, v)
+}
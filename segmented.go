@@ -0,0 +1,56 @@
+package lfu
+
+// segments splits cache into a probation and a protected segment, like SLRU
+// but ordered by frequency within each: new keys enter probation, and move
+// to protected on their first hit, so keys seen once, like those of a scan,
+// are evicted before any key seen twice.
+type segments struct {
+	ratio     float64
+	protected int
+}
+
+// protectedCap returns the number of items the protected segment holds at
+// most, or 0 for no limit. The caller must hold the lock.
+func (c *Cache) protectedCap() int {
+	if c.cap <= 0 {
+		return 0
+	}
+	n := int(c.segments.ratio * float64(c.cap))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// protect moves item from probation to protected, demoting the least
+// frequently used protected items back to probation if protected outgrows
+// its share. The caller must hold the lock.
+func (c *Cache) protect(item *kvItem) {
+	s := c.segments
+	item.protected = true
+	s.protected++
+
+	for max := c.protectedCap(); max > 0 && s.protected > max; {
+		demoted := c.victimOf(func(other *kvItem) bool {
+			return !other.protected || other == item
+		})
+		if demoted == nil {
+			return
+		}
+		demoted.protected = false
+		s.protected--
+	}
+}
+
+// SegmentSizes returns the number of items in the probation and protected
+// segments of Config.ProtectedRatio. Without segments, every item is on
+// probation.
+func (c *Cache) SegmentSizes() (probation, protected int) {
+	c.lock()
+	defer c.unlock()
+
+	if c.segments != nil {
+		protected = c.segments.protected
+	}
+	return len(c.kv) - protected, protected
+}
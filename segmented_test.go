@@ -0,0 +1,76 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestCache_ProtectedRatio(t *testing.T) {
+	cache := New(4, WithProtectedRatio(0.5))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("a")
+	cache.Get("b")
+	probation, protected := cache.SegmentSizes()
+	assert.Equal(t, 0, probation)
+	assert.Equal(t, 2, protected)
+
+	// a scan only evicts its own keys, however often they are set
+	for i := 0; i < 10; i++ {
+		k := "scan" + strconv.Itoa(i)
+		cache.Set(k, i)
+		cache.Set(k, i)
+		cache.Set(k, i)
+	}
+	_, ok := cache.Peek("a")
+	assert.True(t, ok)
+	_, ok = cache.Peek("b")
+	assert.True(t, ok)
+	assert.Equal(t, 4, cache.Size())
+	assert.Nil(t, cache.Verify())
+
+	// protected is full, so promoting c demotes the coldest of a and b
+	cache.Get("a")
+	cache.Set("c", 3)
+	cache.Get("c")
+	probation, protected = cache.SegmentSizes()
+	assert.Equal(t, 2, probation)
+	assert.Equal(t, 2, protected)
+	assert.False(t, cache.kv["b"].protected)
+	assert.True(t, cache.kv["a"].protected)
+	assert.Nil(t, cache.Verify())
+
+	// b is back on probation, so it goes before protected keys
+	cache.Evict(2)
+	_, ok = cache.Peek("b")
+	assert.False(t, ok)
+	assert.ElementsMatch(t, []string{"a", "c"}, cache.Keys())
+
+	// with probation empty, eviction takes protected keys
+	cache.Evict(1)
+	assert.Equal(t, 1, cache.Size())
+	assert.True(t, cache.Remove(cache.Keys()[0]))
+	_, protected = cache.SegmentSizes()
+	assert.Equal(t, 0, protected)
+	assert.Nil(t, cache.Verify())
+}
+
+func TestCache_ProtectedRatioReset(t *testing.T) {
+	cache := New(0, WithProtectedRatio(0.8))
+	for i := 0; i < 10; i++ {
+		cache.Set(strconv.Itoa(i), i)
+		cache.Get(strconv.Itoa(i))
+	}
+	// without a capacity, protected isn't bounded
+	_, protected := cache.SegmentSizes()
+	assert.Equal(t, 10, protected)
+
+	cache.Reset(2)
+	_, protected = cache.SegmentSizes()
+	assert.Equal(t, 0, protected)
+	assert.Nil(t, cache.Verify())
+
+	probation, protected := New(2).SegmentSizes()
+	assert.Equal(t, 0, probation+protected)
+}
@@ -0,0 +1,156 @@
+package lfu
+
+import "hash/fnv"
+
+// Sharded is an LFU split into a fixed number of shards, each a *Cache of its
+// own with an equal part of the capacity, so callers of different shards
+// don't contend for the same lock. Keys are routed to shards by their hash
+// modulo the number of shards, which is cheaper than the ring lookup of
+// ConsistentSharded and spreads keys more evenly, but the number of shards
+// can't change.
+//
+// Frequencies are per shard, so Evict, which evicts the least frequently
+// used item of the shards, is only as exact as their frequencies compare.
+type Sharded struct {
+	shards []*Cache
+}
+
+var _ LFU = (*Sharded)(nil)
+
+// NewSharded creates an LFU of the given total capacity split into the given
+// number of shards, at least one. A non-positive cap means the shards won't
+// do any eviction.
+func NewSharded(cap int, shards int) *Sharded {
+	if shards < 1 {
+		shards = 1
+	}
+
+	return newSharded(Config{Capacity: cap, Shards: shards})
+}
+
+// newSharded creates a Sharded of cfg.Shards shards, at least one, each
+// configured like cfg with an equal part of its bounds.
+func newSharded(cfg Config) *Sharded {
+	n := cfg.Shards
+	if n < 1 {
+		n = 1
+	}
+
+	shard := cfg
+	shard.Capacity = shardCap(cfg.Capacity, n)
+	if cfg.MaxBytes > 0 {
+		shard.MaxBytes = (cfg.MaxBytes + int64(n) - 1) / int64(n)
+	}
+	shard.MaxDirty = shardCap(cfg.MaxDirty, n)
+	shard.AdmissionWindow = shardCap(cfg.AdmissionWindow, n)
+	shard.Warmup = nil
+	s := &Sharded{shards: make([]*Cache, n)}
+	for i := range s.shards {
+		s.shards[i] = NewWithConfig(shard)
+	}
+	if cfg.Warmup != nil {
+		cfg.Warmup(s.Set)
+	}
+	return s
+}
+
+// Set stores the given kv pair in the shard of k.
+func (s *Sharded) Set(k string, v interface{}) {
+	s.shardOf(k).Set(k, v)
+}
+
+// Get returns the v related to k from the shard of k.
+func (s *Sharded) Get(k string) (v interface{}, ok bool) {
+	return s.shardOf(k).Get(k)
+}
+
+// Evict evicts up to n items, each time from the shard whose least frequently
+// used item has the lowest frequency.
+func (s *Sharded) Evict(n int) {
+	evictColdest(s.shards, n)
+}
+
+// Size returns the number of items in all shards.
+func (s *Sharded) Size() int {
+	size := 0
+	for _, shard := range s.shards {
+		size += shard.Size()
+	}
+	return size
+}
+
+// Remove deletes k from its shard.
+func (s *Sharded) Remove(k string) bool {
+	return s.shardOf(k).Remove(k)
+}
+
+// Purge deletes every item of every shard.
+func (s *Sharded) Purge() {
+	for _, shard := range s.shards {
+		shard.Purge()
+	}
+}
+
+// Resize changes the total capacity to cap, shared out equally again, each
+// shard evicting its least frequently used items if it holds more than its
+// part. A non-positive cap means the shards won't do any eviction.
+func (s *Sharded) Resize(cap int) {
+	for _, shard := range s.shards {
+		shard.Resize(shardCap(cap, len(s.shards)))
+	}
+}
+
+// ShardStats returns the Stats of every shard, e.g. to spot a shard taking
+// more than its share of the keys or the lookups.
+func (s *Sharded) ShardStats() []CacheStats {
+	stats := make([]CacheStats, len(s.shards))
+	for i, shard := range s.shards {
+		stats[i] = shard.Stats()
+	}
+	return stats
+}
+
+// ShardSizes returns the number of items in every shard.
+func (s *Sharded) ShardSizes() []int {
+	sizes := make([]int, len(s.shards))
+	for i, shard := range s.shards {
+		sizes[i] = shard.Size()
+	}
+	return sizes
+}
+
+func (s *Sharded) shardOf(k string) *Cache {
+	h := fnv.New32a()
+	h.Write([]byte(k))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// evictColdest evicts up to n items, each time from the shard whose least
+// frequently used item has the lowest frequency.
+func evictColdest(shards []*Cache, n int) {
+	for i := 0; i < n; i++ {
+		var coldest *Cache
+		freq := 0
+		for _, shard := range shards {
+			if e := shard.ColdestN(1); len(e) > 0 && (coldest == nil || e[0].Freq < freq) {
+				coldest, freq = shard, e[0].Freq
+			}
+		}
+		if coldest == nil {
+			return
+		}
+		coldest.Evict(1)
+	}
+}
+
+// shardCap returns the capacity of each of n shards sharing cap, rounded up so
+// they hold at least cap. It doesn't overflow for a cap near math.MaxInt.
+func shardCap(cap, n int) int {
+	if cap <= 0 {
+		return 0
+	}
+	if cap%n != 0 {
+		return cap/n + 1
+	}
+	return cap / n
+}
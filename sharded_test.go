@@ -0,0 +1,79 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestSharded(t *testing.T) {
+	s := NewSharded(0, 4)
+	for i := 0; i < 8; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	for i := 0; i < 8; i++ {
+		v, ok := s.Get(strconv.Itoa(i))
+		assert.True(t, ok)
+		assert.Equal(t, i, v)
+	}
+	assert.Equal(t, 4, len(s.ShardSizes()))
+	hits := uint64(0)
+	for _, stats := range s.ShardStats() {
+		hits += stats.Hits
+	}
+	assert.Equal(t, uint64(8), hits)
+
+	// every key but 0 is read once more
+	for i := 1; i < 8; i++ {
+		s.Get(strconv.Itoa(i))
+	}
+	s.Evict(1)
+	_, ok := s.Get("0")
+	assert.False(t, ok)
+	assert.Equal(t, 7, s.Size())
+
+	assert.True(t, s.Remove("1"))
+	assert.False(t, s.Remove("1"))
+	s.Purge()
+	assert.Equal(t, 0, s.Size())
+	s.Evict(1)
+}
+
+func TestSharded_Capacity(t *testing.T) {
+	s := NewSharded(10, 3)
+	for _, shard := range s.shards {
+		assert.Equal(t, 4, shard.cap)
+	}
+	for i := 0; i < 100; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	assert.True(t, s.Size() <= 12)
+
+	s = NewSharded(math.MaxInt, 3)
+	assert.Equal(t, math.MaxInt/3+1, s.shards[0].cap)
+
+	s = NewSharded(0, 0)
+	assert.Equal(t, 1, len(s.shards))
+	for i := 0; i < 100; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 100, s.Size())
+}
+
+func TestSharded_Resize(t *testing.T) {
+	s := NewSharded(0, 2)
+	for i := 0; i < 20; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+
+	s.Resize(4)
+	for _, size := range s.ShardSizes() {
+		assert.True(t, size <= 2)
+	}
+	s.Resize(0)
+	for i := 0; i < 20; i++ {
+		s.Set(strconv.Itoa(i), i)
+	}
+	assert.Equal(t, 20, s.Size())
+}
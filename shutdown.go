@@ -0,0 +1,17 @@
+package lfu
+
+import "context"
+
+// NewWithShutdown works like New, and hands a final Snapshot of cache to
+// onShutdown once ctx is done, e.g. to persist cache when the service gets
+// a termination signal, with ctx from signal.NotifyContext. onShutdown runs
+// once, on a goroutine of its own. Cache stays usable afterwards, but what
+// is stored then isn't handed over.
+func NewWithShutdown(ctx context.Context, cap int, onShutdown func(snapshot []Entry), opts ...Option) *Cache {
+	c := New(cap, opts...)
+	go func() {
+		<-ctx.Done()
+		onShutdown(c.Snapshot())
+	}()
+	return c
+}
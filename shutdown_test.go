@@ -0,0 +1,42 @@
+package lfu
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewWithShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	snapshots := make(chan []Entry, 2)
+	cache := NewWithShutdown(ctx, 2, func(snapshot []Entry) {
+		snapshots <- snapshot
+	})
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	select {
+	case <-snapshots:
+		t.Fatal("onShutdown called before ctx was done")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case snapshot := <-snapshots:
+		assert.Equal(t, []Entry{{Key: "a", Value: 1, Freq: 1}, {Key: "b", Value: 2, Freq: 2}}, snapshot)
+	case <-time.After(time.Second):
+		t.Fatal("onShutdown not called after ctx was done")
+	}
+
+	// once
+	cancel()
+	cache.Set("c", 3)
+	select {
+	case <-snapshots:
+		t.Fatal("onShutdown called twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
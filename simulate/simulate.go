@@ -0,0 +1,171 @@
+// Package simulate replays access traces against lfu caches and reports the
+// hit ratio each policy reaches at each capacity, e.g. to size a cache from
+// production traffic, or to pick between its eviction modes.
+package simulate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ZhengHe-MD/lfu"
+)
+
+// Format is the format of an access trace.
+type Format int
+
+const (
+	// Lines has one key per line. Blank lines are skipped.
+	Lines Format = iota
+	// ARC is the format of the traces of the ARC paper: every line reads
+	// "start count ignored request", an access of the count blocks from
+	// start on.
+	ARC
+	// LIRS is the format of the traces of the LIRS paper: one block number
+	// per line. Lines that aren't a number, like the "*" some traces end
+	// with, are skipped.
+	LIRS
+)
+
+// ReadTrace returns the keys accessed by the trace read from r, in order.
+func ReadTrace(r io.Reader, format Format) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		switch format {
+		case Lines:
+			keys = append(keys, line)
+		case ARC:
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("simulate: line %d: want start and count, got %q", n, line)
+			}
+			start, err := strconv.ParseUint(fields[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("simulate: line %d: %w", n, err)
+			}
+			count, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("simulate: line %d: %w", n, err)
+			}
+			for b := start; b < start+count; b++ {
+				keys = append(keys, strconv.FormatUint(b, 10))
+			}
+		case LIRS:
+			if _, err := strconv.ParseUint(line, 10, 64); err == nil {
+				keys = append(keys, line)
+			}
+		default:
+			return nil, fmt.Errorf("simulate: unknown format %d", format)
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// Policy names a way to build a cache of a given capacity.
+type Policy struct {
+	Name string
+	New  func(cap int) lfu.LFU
+}
+
+// Policies returns the eviction modes of lfu worth comparing: plain LFU, LFU
+// with a W-TinyLFU admission window of 1% of the capacity, segmented LFU
+// protecting 80% of it, and LRU as a baseline.
+func Policies() []Policy {
+	return []Policy{
+		{"lfu", func(cap int) lfu.LFU { return lfu.New(cap) }},
+		{"tinylfu", func(cap int) lfu.LFU {
+			return lfu.New(cap, lfu.WithAdmissionWindow(cap/100+1))
+		}},
+		{"segmented", func(cap int) lfu.LFU { return lfu.New(cap, lfu.WithProtectedRatio(0.8)) }},
+		{"lru", lfu.NewLRU},
+	}
+}
+
+// Result is the outcome of replaying a trace against a cache.
+type Result struct {
+	Policy       string
+	Capacity     int
+	Hits, Misses int
+}
+
+// HitRatio returns the fraction of the accesses that hit, or 0 for an empty
+// trace.
+func (r Result) HitRatio() float64 {
+	if r.Hits+r.Misses == 0 {
+		return 0
+	}
+	return float64(r.Hits) / float64(r.Hits+r.Misses)
+}
+
+// Replay accesses keys in order against a cache of every policy and capacity,
+// the way a read-through cache would: a miss stores the key. It returns the
+// results by policy, then by capacity.
+func Replay(keys []string, policies []Policy, capacities []int) []Result {
+	results := make([]Result, 0, len(policies)*len(capacities))
+	for _, p := range policies {
+		for _, cap := range capacities {
+			c := p.New(cap)
+			r := Result{Policy: p.Name, Capacity: cap}
+			for _, k := range keys {
+				if _, ok := c.Get(k); ok {
+					r.Hits++
+				} else {
+					r.Misses++
+					c.Set(k, nil)
+				}
+			}
+			results = append(results, r)
+		}
+	}
+	return results
+}
+
+// WriteTable writes results to w as a table of hit ratios, a row per
+// capacity and a column per policy, in the order they appear in results.
+func WriteTable(w io.Writer, results []Result) error {
+	var policies []string
+	var capacities []int
+	ratios := make(map[string]map[int]float64)
+	for _, r := range results {
+		if _, ok := ratios[r.Policy]; !ok {
+			policies = append(policies, r.Policy)
+			ratios[r.Policy] = make(map[int]float64)
+		}
+		if !containsInt(capacities, r.Capacity) {
+			capacities = append(capacities, r.Capacity)
+		}
+		ratios[r.Policy][r.Capacity] = r.HitRatio()
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%10s", "capacity")
+	for _, p := range policies {
+		fmt.Fprintf(bw, " %10s", p)
+	}
+	fmt.Fprintln(bw)
+	for _, cap := range capacities {
+		fmt.Fprintf(bw, "%10d", cap)
+		for _, p := range policies {
+			fmt.Fprintf(bw, " %9.2f%%", 100*ratios[p][cap])
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+func containsInt(s []int, x int) bool {
+	for _, y := range s {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
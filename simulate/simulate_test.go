@@ -0,0 +1,97 @@
+package simulate
+
+import (
+	"bytes"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadTrace(t *testing.T) {
+	keys, err := ReadTrace(strings.NewReader("a\n\n b \na\n"), Lines)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b", "a"}, keys)
+
+	keys, err = ReadTrace(strings.NewReader("10 3 0 0\n7 1 0 1\n"), ARC)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10", "11", "12", "7"}, keys)
+
+	_, err = ReadTrace(strings.NewReader("10\n"), ARC)
+	assert.EqualError(t, err, `simulate: line 1: want start and count, got "10"`)
+	_, err = ReadTrace(strings.NewReader("x 1\n"), ARC)
+	assert.Error(t, err)
+
+	keys, err = ReadTrace(strings.NewReader("5\n6\n5\n*\n"), LIRS)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"5", "6", "5"}, keys)
+
+	_, err = ReadTrace(strings.NewReader("a\n"), Format(9))
+	assert.Error(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	keys := []string{"a", "b", "a", "c", "a", "b"}
+	results := Replay(keys, Policies()[:1], []int{1, 2, 0})
+	assert.Equal(t, []Result{
+		{Policy: "lfu", Capacity: 1, Hits: 0, Misses: 6},
+		{Policy: "lfu", Capacity: 2, Hits: 2, Misses: 4},
+		{Policy: "lfu", Capacity: 0, Hits: 3, Misses: 3},
+	}, results)
+	assert.Equal(t, 0.5, results[2].HitRatio())
+	assert.Equal(t, 0.0, Result{}.HitRatio())
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteTable(&buf, results))
+	assert.Equal(t, ""+
+		"  capacity        lfu\n"+
+		"         1      0.00%\n"+
+		"         2     33.33%\n"+
+		"         0     50.00%\n", buf.String())
+}
+
+// scanTrace is a Zipf workload, interrupted by scans of keys read once.
+func scanTrace(n int) []string {
+	r := rand.New(rand.NewSource(1))
+	z := rand.NewZipf(r, 1.1, 1, 1<<16)
+	keys := make([]string, 0, n)
+	for len(keys) < n {
+		if len(keys)%10000 == 0 {
+			for i := 0; i < 2000; i++ {
+				keys = append(keys, "scan"+strconv.Itoa(len(keys)))
+			}
+		}
+		keys = append(keys, strconv.FormatUint(z.Uint64(), 10))
+	}
+	return keys
+}
+
+func TestReplay_Policies(t *testing.T) {
+	results := Replay(scanTrace(50000), Policies(), []int{500})
+	assert.Equal(t, 4, len(results))
+	for _, r := range results {
+		assert.Equal(t, 500, r.Capacity)
+		assert.True(t, r.HitRatio() > 0, r.Policy)
+	}
+}
+
+// BenchmarkPolicies reports the hit ratio of every policy on a Zipf workload
+// with scans, alongside the time to replay it.
+func BenchmarkPolicies(b *testing.B) {
+	keys := scanTrace(1 << 16)
+	for _, p := range Policies() {
+		for _, cap := range []int{100, 1000, 10000} {
+			p, cap := p, cap
+			b.Run(p.Name+"/"+strconv.Itoa(cap), func(b *testing.B) {
+				var r Result
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					r = Replay(keys, []Policy{p}, []int{cap})[0]
+				}
+				b.ReportMetric(100*r.HitRatio(), "hit%")
+			})
+		}
+	}
+}
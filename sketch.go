@@ -0,0 +1,79 @@
+package lfu
+
+import "hash/fnv"
+
+// sketchDepth is the number of rows of a sketch when none is configured.
+const sketchDepth = 4
+
+// cmSketch is a count-min sketch estimating how many times a key was added
+// with a fixed amount of memory. Estimates never undercount, but may
+// overcount keys colliding with more frequent ones.
+type cmSketch struct {
+	mask uint64
+	rows [][]uint32
+}
+
+// newCMSketch creates a sketch of depth rows, or sketchDepth if depth isn't
+// positive, each of width counters rounded up to a power of two.
+func newCMSketch(width, depth int) *cmSketch {
+	w := 1
+	for w < width {
+		w <<= 1
+	}
+	if depth <= 0 {
+		depth = sketchDepth
+	}
+
+	s := &cmSketch{mask: uint64(w - 1), rows: make([][]uint32, depth)}
+	for i := range s.rows {
+		s.rows[i] = make([]uint32, w)
+	}
+	return s
+}
+
+// add counts one more occurrence of k and returns its new estimate.
+func (s *cmSketch) add(k string) uint32 {
+	h1, h2 := sketchHash(k)
+
+	est := ^uint32(0)
+	for i := range s.rows {
+		idx := (h1 + uint64(i)*h2) & s.mask
+		if s.rows[i][idx] < ^uint32(0) {
+			s.rows[i][idx]++
+		}
+		if s.rows[i][idx] < est {
+			est = s.rows[i][idx]
+		}
+	}
+	return est
+}
+
+// estimate returns how many times k was added, possibly overcounted.
+func (s *cmSketch) estimate(k string) uint32 {
+	h1, h2 := sketchHash(k)
+
+	est := ^uint32(0)
+	for i := range s.rows {
+		if v := s.rows[i][(h1+uint64(i)*h2)&s.mask]; v < est {
+			est = v
+		}
+	}
+	return est
+}
+
+// halve divides every count by two, so older occurrences weigh less than
+// recent ones.
+func (s *cmSketch) halve() {
+	for _, row := range s.rows {
+		for i := range row {
+			row[i] >>= 1
+		}
+	}
+}
+
+func sketchHash(k string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(k))
+	sum := h.Sum64()
+	return sum, sum>>32 | 1
+}
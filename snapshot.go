@@ -0,0 +1,396 @@
+package lfu
+
+import (
+	"sort"
+	"time"
+)
+
+// Entry is a point-in-time copy of a cached kv pair and its frequency count.
+type Entry struct {
+	Key   string
+	Value interface{}
+	Freq  int
+	// ExpiresAt is when k expires, or zero if it doesn't.
+	ExpiresAt time.Time
+}
+
+// EntryInfo is an Entry along with the times telling why it is, or isn't,
+// retained.
+type EntryInfo struct {
+	Entry
+	// CreatedAt is when k was stored while not in cache, and UpdatedAt when
+	// its value was last stored.
+	CreatedAt, UpdatedAt time.Time
+	// LastAccessedAt is the time of the latest hit of k, or zero if it had
+	// none or Config.TrackAccessTime isn't set.
+	LastAccessedAt time.Time
+}
+
+// GetEntry returns the EntryInfo of k, e.g. for an admin dashboard, without
+// counting it as an access. Freq accounts for the hits queued by
+// LazyPromotions.
+func (c *Cache) GetEntry(k string) (info EntryInfo, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return EntryInfo{}, false
+	}
+	info = item.info()
+	info.Value = c.clone(item.v)
+	return info, true
+}
+
+// info returns a copy of item as an EntryInfo.
+func (item *kvItem) info() EntryInfo {
+	e := item.entry()
+	e.Freq += item.queuedHits
+	return EntryInfo{
+		Entry:          e,
+		CreatedAt:      item.createdAt,
+		UpdatedAt:      item.updatedAt,
+		LastAccessedAt: item.accessedAt,
+	}
+}
+
+// listed reports whether item shows in the copies and iterations of cache,
+// which leave expired items out, as if they were gone already, and the
+// negative entries of StoreMiss, which hold no value. The caller must hold
+// the lock.
+func (c *Cache) listed(item *kvItem) bool {
+	return !c.expired(item) && !negative(item.v)
+}
+
+// Snapshot returns a copy of every entry in cache, ordered from the least to
+// the most frequently used. Entries sharing a frequency come in the order
+// they reached it, the one there the longest first. Expired and negative
+// entries are left out, like in the other copies and iterations of cache.
+func (c *Cache) Snapshot() []Entry {
+	c.lock()
+	defer c.unlock()
+
+	entries := make([]Entry, 0, len(c.kv))
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		for item := e.Value.(*freqNode).head; item != nil; item = item.next {
+			if c.listed(item) {
+				entries = append(entries, item.entry())
+			}
+		}
+	}
+	return entries
+}
+
+// Keys returns the keys in cache, ordered from the least to the most
+// frequently used. Keys sharing a frequency come in the order of Snapshot.
+func (c *Cache) Keys() []string {
+	c.lock()
+	defer c.unlock()
+
+	keys := make([]string, 0, len(c.kv))
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		for item := e.Value.(*freqNode).head; item != nil; item = item.next {
+			if c.listed(item) {
+				keys = append(keys, item.k)
+			}
+		}
+	}
+	return keys
+}
+
+// SnapshotTopN returns a copy of the n most frequently used entries, from the
+// most to the least frequently used, e.g. to persist only the working set and
+// Restore it later. It returns every entry if n exceeds the size of cache.
+func (c *Cache) SnapshotTopN(n int) []Entry {
+	c.lock()
+	defer c.unlock()
+
+	if n > len(c.kv) {
+		n = len(c.kv)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	entries := make([]Entry, 0, n)
+	for e := c.freqList.Back(); e != nil && len(entries) < n; e = e.Prev() {
+		for item := e.Value.(*freqNode).head; item != nil && len(entries) < n; item = item.next {
+			if c.listed(item) {
+				entries = append(entries, item.entry())
+			}
+		}
+	}
+	return entries
+}
+
+// MostFrequent returns the most frequently used entry, without allocating
+// like SnapshotTopN(1) does. Of the entries sharing the highest frequency,
+// it returns the one there the longest. It doesn't count as an access. ok is
+// false if cache is empty.
+func (c *Cache) MostFrequent() (k string, v interface{}, freq int, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	for e := c.freqList.Back(); e != nil; e = e.Prev() {
+		node := e.Value.(*freqNode)
+		for item := node.head; item != nil; item = item.next {
+			if c.listed(item) {
+				return item.k, item.v, node.freq, true
+			}
+		}
+	}
+	return "", nil, 0, false
+}
+
+// ColdestN returns a copy of the n least frequently used entries, from the
+// least to the most frequently used, e.g. to persist them before Evict drops
+// them. It doesn't count as an access. It returns every entry if n exceeds
+// the size of cache.
+func (c *Cache) ColdestN(n int) []Entry {
+	c.lock()
+	defer c.unlock()
+
+	if n > len(c.kv) {
+		n = len(c.kv)
+	}
+	if n <= 0 {
+		return nil
+	}
+
+	entries := make([]Entry, 0, n)
+	for e := c.freqList.Front(); e != nil && len(entries) < n; e = e.Next() {
+		for item := e.Value.(*freqNode).head; item != nil && len(entries) < n; item = item.next {
+			if c.listed(item) {
+				entries = append(entries, item.entry())
+			}
+		}
+	}
+	return entries
+}
+
+// Partition splits a copy of every entry in cache into hot, the entries used
+// at least threshold times, and cold, the others, e.g. to decide what to move
+// between tiers. Both are ordered from the least to the most frequently used.
+// It doesn't count as an access.
+func (c *Cache) Partition(threshold int) (hot, cold []Entry) {
+	c.lock()
+	defer c.unlock()
+
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*freqNode)
+		for item := node.head; item != nil; item = item.next {
+			switch {
+			case !c.listed(item):
+			case node.freq >= threshold:
+				hot = append(hot, item.entry())
+			default:
+				cold = append(cold, item.entry())
+			}
+		}
+	}
+	return
+}
+
+// FindByValue returns a copy of the entries whose value pred returns true
+// for, from the least to the most frequently used, e.g. to inspect what an
+// invalidation would hit before removing anything. It doesn't count as an
+// access. pred runs under the lock on every value, so it must be quick and
+// must not use cache.
+func (c *Cache) FindByValue(pred func(v interface{}) bool) []Entry {
+	c.lock()
+	defer c.unlock()
+
+	var found []Entry
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		for item := e.Value.(*freqNode).head; item != nil; item = item.next {
+			if c.listed(item) && pred(item.v) {
+				found = append(found, item.entry())
+			}
+		}
+	}
+	return found
+}
+
+// Tier is the entries of cache sharing a frequency count.
+type Tier struct {
+	Freq    int
+	Entries []Entry
+}
+
+// EntriesByTier returns a copy of every entry in cache grouped by frequency,
+// from the least to the most frequently used tier, e.g. for an inspector
+// showing how the items spread over frequencies. The entries of a tier come
+// in the order of Snapshot. It doesn't count as an access. Like Snapshot, it
+// copies every entry under the lock, so it costs as much for large caches.
+func (c *Cache) EntriesByTier() []Tier {
+	c.lock()
+	defer c.unlock()
+
+	tiers := make([]Tier, 0, c.freqList.Len())
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*freqNode)
+		tier := Tier{Freq: node.freq, Entries: make([]Entry, 0, node.n)}
+		for item := node.head; item != nil; item = item.next {
+			if c.listed(item) {
+				tier.Entries = append(tier.Entries, item.entry())
+			}
+		}
+		if len(tier.Entries) > 0 {
+			tiers = append(tiers, tier)
+		}
+	}
+	return tiers
+}
+
+// Diff compares prev, a Snapshot taken earlier, to the current state of
+// cache, e.g. to monitor churn between intervals. added holds the keys in
+// cache but not in prev, removed the keys in prev but no longer in cache, and
+// frequencyChanged the keys in both whose frequency went up or down. Each is
+// sorted. Like Snapshot, it leaves out expired and negative entries, so a
+// key that expired since prev is removed. It doesn't count as an access.
+func (c *Cache) Diff(prev []Entry) (added, removed, frequencyChanged []string) {
+	c.lock()
+	defer c.unlock()
+
+	seen := make(map[string]struct{}, len(prev))
+	for _, e := range prev {
+		seen[e.Key] = placeholder
+		item, ok := c.kv[e.Key]
+		switch {
+		case !ok || !c.listed(item):
+			removed = append(removed, e.Key)
+		case item.parent.Value.(*freqNode).freq != e.Freq:
+			frequencyChanged = append(frequencyChanged, e.Key)
+		}
+	}
+	for k, item := range c.kv {
+		if _, ok := seen[k]; !ok && c.listed(item) {
+			added = append(added, k)
+		}
+	}
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Strings(frequencyChanged)
+	return
+}
+
+// ForEach calls fn for every kv pair in cache, from the least to the most
+// frequently used, while holding the lock. fn must not call methods of cache.
+func (c *Cache) ForEach(fn func(k string, v interface{})) {
+	c.lock()
+	defer c.unlock()
+
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		for item := e.Value.(*freqNode).head; item != nil; item = item.next {
+			if c.listed(item) {
+				fn(item.k, item.v)
+			}
+		}
+	}
+}
+
+// Range calls fn for every kv pair in cache, from the least to the most
+// frequently used, until fn returns false. Unlike ForEach, the pairs are
+// copied under the lock and fn is called after releasing it, so fn may take
+// its time or use cache. The pairs are those in cache when Range started and
+// don't reflect later mutations.
+func (c *Cache) Range(fn func(k string, v interface{}) bool) {
+	c.RangeOrdered(ColdestFirst, fn)
+}
+
+// Order is the frequency order RangeOrdered calls its function in.
+type Order int
+
+const (
+	// ColdestFirst goes from the least to the most frequently used item.
+	ColdestFirst Order = iota
+	// HottestFirst goes from the most to the least frequently used item.
+	HottestFirst
+)
+
+// RangeOrdered works like Range, in the given order, e.g. HottestFirst to
+// hand the working set over to another process first. Items sharing a
+// frequency come in the order of Snapshot with ColdestFirst, and in reverse
+// with HottestFirst.
+func (c *Cache) RangeOrdered(order Order, fn func(k string, v interface{}) bool) {
+	entries := c.Snapshot()
+	for i := range entries {
+		e := entries[i]
+		if order == HottestFirst {
+			e = entries[len(entries)-1-i]
+		}
+		if !fn(e.Key, e.Value) {
+			return
+		}
+	}
+}
+
+// Restore stores the given entries with their frequencies, e.g. from the
+// Snapshot of another cache. Entries are inserted from the least to the most
+// frequently used, so if they don't all fit, the least frequently used ones
+// are the ones evicted and the relative eviction order is preserved. An entry
+// with an ExpiresAt keeps expiring then, and isn't stored if that passed
+// already; the others get the DefaultTTL.
+func (c *Cache) Restore(entries []Entry) {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Freq < sorted[j].Freq
+	})
+
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	now := c.now()
+	for _, e := range sorted {
+		if !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt) {
+			continue
+		}
+		c.restoreExpiry(c.setWithFrequency(c.key(e.Key), e.Value, e.Freq), e.ExpiresAt)
+	}
+}
+
+// DrainTo empties cache into ch, e.g. to hand its contents over to a
+// replacement instance. Entries are removed one at a time, from the least to
+// the most frequently used, and sent on ch after releasing the lock, so a
+// slow receiver holds DrainTo back without blocking other callers. Items
+// stored while DrainTo runs are drained as well. Expired and negative entries
+// are removed without being sent. DrainTo returns once cache is empty, and
+// doesn't close ch.
+func (c *Cache) DrainTo(ch chan<- Entry) {
+	for {
+		e, ok := c.popColdest()
+		if !ok {
+			return
+		}
+		ch <- e
+	}
+}
+
+// popColdest removes and returns the least frequently used entry, removing
+// the expired and negative ones it comes across first without returning
+// them.
+func (c *Cache) popColdest() (e Entry, ok bool) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	for front := c.freqList.Front(); front != nil; front = c.freqList.Front() {
+		head := front.Value.(*freqNode).head
+		switch {
+		case head == nil:
+			return
+		case c.expired(head):
+			c.deleteItem(head, ReasonExpired)
+		case negative(head.v):
+			c.removeItem(head)
+		default:
+			e = head.entry()
+			c.removeItem(head)
+			return e, true
+		}
+	}
+	return
+}
@@ -0,0 +1,456 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_Snapshot(t *testing.T) {
+	cache := New(0)
+	assert.Equal(t, 0, len(cache.Snapshot()))
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	cache.Get("b")
+
+	assert.Equal(t, []Entry{
+		{Key: "a", Value: 1, Freq: 1},
+		{Key: "b", Value: 2, Freq: 3},
+	}, cache.Snapshot())
+}
+
+func TestCache_SetWithFrequency(t *testing.T) {
+	cache := New(2)
+
+	cache.SetWithFrequency("a", 1, 5)
+	cache.SetWithFrequency("b", 2, 0)
+	assert.Equal(t, 5, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 1, cache.kv["b"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 2, cache.freqList.Len())
+
+	cache.SetWithFrequency("b", 3, 3)
+	assert.Equal(t, 3, cache.kv["b"].v)
+	assert.Equal(t, 3, cache.kv["b"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 2, cache.freqList.Len())
+	assert.Equal(t, 3, cache.freqList.Front().Value.(*freqNode).freq)
+
+	cache.SetWithFrequency("c", 4, 4)
+	_, ok := cache.kv["b"]
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Size())
+	assert.Equal(t, 4, cache.freqList.Front().Value.(*freqNode).freq)
+	assert.Equal(t, 5, cache.freqList.Back().Value.(*freqNode).freq)
+}
+
+func TestCache_Restore(t *testing.T) {
+	src := New(0)
+	for i, k := range []string{"a", "b", "c", "d", "e"} {
+		src.SetWithFrequency(k, i, i+1)
+	}
+
+	dst := New(3)
+	dst.Restore(src.Snapshot())
+	assert.Equal(t, 3, dst.Size())
+	for _, k := range []string{"a", "b"} {
+		_, ok := dst.kv[k]
+		assert.False(t, ok)
+	}
+	for i, k := range []string{"c", "d", "e"} {
+		item, ok := dst.kv[k]
+		assert.True(t, ok)
+		assert.Equal(t, i+2, item.v)
+		assert.Equal(t, i+3, item.parent.Value.(*freqNode).freq)
+	}
+}
+
+func TestCache_ForEach(t *testing.T) {
+	cache := New(0)
+	cache.ForEach(func(k string, v interface{}) {
+		t.Fatal("ForEach called fn on an empty cache")
+	})
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+
+	var keys []string
+	var values []interface{}
+	cache.ForEach(func(k string, v interface{}) {
+		keys = append(keys, k)
+		values = append(values, v)
+	})
+	assert.Equal(t, []string{"a", "b"}, keys)
+	assert.Equal(t, []interface{}{1, 2}, values)
+}
+
+func TestCache_Range(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	cache.Set("c", 3)
+	cache.Get("c")
+	cache.Get("c")
+
+	var keys []string
+	cache.Range(func(k string, v interface{}) bool {
+		keys = append(keys, k)
+		cache.Set(k+k, v)
+		return true
+	})
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, 6, cache.Size())
+	_, ok := cache.kv["aa"]
+	assert.True(t, ok)
+
+	keys = nil
+	cache.Range(func(k string, v interface{}) bool {
+		keys = append(keys, k)
+		return len(keys) < 2
+	})
+	assert.Equal(t, 2, len(keys))
+}
+
+func TestCache_RangeOrdered(t *testing.T) {
+	cache := New(0)
+	for i, k := range []string{"a", "b", "c"} {
+		cache.SetWithFrequency(k, i, i+1)
+	}
+
+	collect := func(order Order, n int) []string {
+		var keys []string
+		cache.RangeOrdered(order, func(k string, v interface{}) bool {
+			keys = append(keys, k)
+			return len(keys) < n
+		})
+		return keys
+	}
+	assert.Equal(t, []string{"a", "b", "c"}, collect(ColdestFirst, 5))
+	assert.Equal(t, []string{"c", "b", "a"}, collect(HottestFirst, 5))
+	assert.Equal(t, []string{"c"}, collect(HottestFirst, 1))
+}
+
+func TestCache_SnapshotTopN(t *testing.T) {
+	cache := New(0)
+	assert.Nil(t, cache.SnapshotTopN(3))
+
+	for i, k := range []string{"a", "b", "c", "d"} {
+		cache.SetWithFrequency(k, i, i+1)
+	}
+
+	assert.Equal(t, []Entry{
+		{Key: "d", Value: 3, Freq: 4},
+		{Key: "c", Value: 2, Freq: 3},
+	}, cache.SnapshotTopN(2))
+	assert.Equal(t, 4, len(cache.SnapshotTopN(10)))
+	assert.Nil(t, cache.SnapshotTopN(0))
+	assert.Nil(t, cache.SnapshotTopN(-1))
+
+	dst := New(0)
+	dst.Restore(cache.SnapshotTopN(2))
+	assert.Equal(t, 2, dst.Size())
+	assert.Equal(t, 4, dst.kv["d"].parent.Value.(*freqNode).freq)
+}
+
+func TestCache_ColdestN(t *testing.T) {
+	cache := New(0)
+	assert.Nil(t, cache.ColdestN(3))
+
+	for i, k := range []string{"d", "c", "b", "a"} {
+		cache.SetWithFrequency(k, i, 4-i)
+	}
+
+	assert.Equal(t, []Entry{
+		{Key: "a", Value: 3, Freq: 1},
+		{Key: "b", Value: 2, Freq: 2},
+	}, cache.ColdestN(2))
+	assert.Equal(t, 4, len(cache.ColdestN(10)))
+	assert.Nil(t, cache.ColdestN(0))
+
+	// reading them is not an access
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+	cache.Evict(2)
+	_, ok := cache.kv["b"]
+	assert.False(t, ok)
+}
+
+func TestCache_DrainTo(t *testing.T) {
+	cache := New(0)
+	for i, k := range []string{"a", "b", "c"} {
+		cache.SetWithFrequency(k, i, i+1)
+	}
+
+	ch := make(chan Entry, 3)
+	cache.DrainTo(ch)
+	close(ch)
+
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 0, cache.freqList.Len())
+	var got []Entry
+	for e := range ch {
+		got = append(got, e)
+	}
+	assert.Equal(t, []Entry{
+		{Key: "a", Value: 0, Freq: 1},
+		{Key: "b", Value: 1, Freq: 2},
+		{Key: "c", Value: 2, Freq: 3},
+	}, got)
+
+	// an empty cache sends nothing
+	cache.DrainTo(nil)
+}
+
+func TestCache_DrainToUnlisted(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	cache.StoreMiss("n", 0)
+	cache.SetWithTTL("x", 1, time.Second)
+	cache.SetWithFrequency("a", 2, 2)
+	clock.Advance(time.Second)
+
+	ch := make(chan Entry, 3)
+	cache.DrainTo(ch)
+	close(ch)
+	assert.Equal(t, 0, cache.Size())
+	var got []Entry
+	for e := range ch {
+		got = append(got, e)
+	}
+	assert.Equal(t, []Entry{{Key: "a", Value: 2, Freq: 2}}, got)
+}
+
+func TestCache_Partition(t *testing.T) {
+	cache := New(0)
+	for i, k := range []string{"a", "b", "c", "d"} {
+		cache.SetWithFrequency(k, i, i+1)
+	}
+	before := cache.Snapshot()
+
+	hot, cold := cache.Partition(3)
+	assert.Equal(t, []Entry{{Key: "c", Value: 2, Freq: 3}, {Key: "d", Value: 3, Freq: 4}}, hot)
+	assert.Equal(t, []Entry{{Key: "a", Value: 0, Freq: 1}, {Key: "b", Value: 1, Freq: 2}}, cold)
+	assert.Equal(t, before, cache.Snapshot())
+
+	hot, cold = cache.Partition(1)
+	assert.Equal(t, 4, len(hot))
+	assert.Empty(t, cold)
+	hot, cold = cache.Partition(5)
+	assert.Empty(t, hot)
+	assert.Equal(t, 4, len(cold))
+}
+
+func TestCache_Diff(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetWithFrequency("c", 3, 5)
+	cache.Set("d", 4)
+	prev := cache.Snapshot()
+
+	added, removed, changed := cache.Diff(prev)
+	assert.Nil(t, added)
+	assert.Nil(t, removed)
+	assert.Nil(t, changed)
+
+	cache.Get("a")             // hotter
+	cache.SetFrequency("c", 2) // colder
+	cache.Remove("b")          // left
+	cache.Set("e", 5)          // entered
+	cache.Remove("d")          // left and entered again at the same frequency
+	cache.Set("d", 40)
+	added, removed, changed = cache.Diff(prev)
+	assert.Equal(t, []string{"e"}, added)
+	assert.Equal(t, []string{"b"}, removed)
+	assert.Equal(t, []string{"a", "c"}, changed)
+
+	// everything is added compared to nothing
+	added, removed, changed = cache.Diff(nil)
+	assert.Equal(t, []string{"a", "c", "d", "e"}, added)
+	assert.Nil(t, removed)
+	assert.Nil(t, changed)
+}
+
+func TestCache_DiffUnlisted(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, time.Second)
+	prev := cache.Snapshot()
+
+	// neither negative entries nor expired ones show up
+	cache.StoreMiss("n", 0)
+	cache.SetWithTTL("x", 3, time.Second)
+	clock.Advance(time.Second)
+	added, removed, changed := cache.Diff(prev)
+	assert.Nil(t, added)
+	assert.Equal(t, []string{"b"}, removed)
+	assert.Nil(t, changed)
+}
+
+func TestCache_EntriesByTier(t *testing.T) {
+	assert.Empty(t, New(0).EntriesByTier())
+
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.SetWithFrequency("b", 2, 3)
+	cache.SetWithFrequency("c", 3, 7)
+	cache.SetWithFrequency("d", 4, 3)
+
+	tiers := cache.EntriesByTier()
+	assert.Equal(t, 3, len(tiers))
+	assert.Equal(t, Tier{Freq: 1, Entries: []Entry{{Key: "a", Value: 1, Freq: 1}}}, tiers[0])
+	assert.Equal(t, 3, tiers[1].Freq)
+	assert.ElementsMatch(t, []Entry{{Key: "b", Value: 2, Freq: 3}, {Key: "d", Value: 4, Freq: 3}}, tiers[1].Entries)
+	assert.Equal(t, Tier{Freq: 7, Entries: []Entry{{Key: "c", Value: 3, Freq: 7}}}, tiers[2])
+
+	// copies, not views
+	tiers[0].Entries[0].Value = 10
+	v, _ := cache.Peek("a")
+	assert.Equal(t, 1, v)
+}
+
+func TestCache_MostFrequent(t *testing.T) {
+	cache := New(0)
+	_, _, _, ok := cache.MostFrequent()
+	assert.False(t, ok)
+
+	cache.Set("a", 1)
+	cache.SetWithFrequency("b", 2, 4)
+	cache.SetWithFrequency("c", 3, 4)
+	cache.SetWithFrequency("d", 4, 2)
+	k, v, freq, ok := cache.MostFrequent()
+	assert.True(t, ok)
+	assert.Contains(t, []string{"b", "c"}, k)
+	assert.Equal(t, map[string]int{"b": 2, "c": 3}[k], v)
+	assert.Equal(t, 4, freq)
+
+	// not an access
+	cache.MostFrequent()
+	assert.Equal(t, 4, cache.kv[k].parent.Value.(*freqNode).freq)
+
+	cache.Get("a")
+	cache.TouchBy("a", 5)
+	k, v, freq, ok = cache.MostFrequent()
+	assert.Equal(t, "a", k)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 7, freq)
+	assert.True(t, ok)
+}
+
+func TestCache_FindByValue(t *testing.T) {
+	type response struct{ etag string }
+	cache := New(0)
+	cache.Set("a", response{etag: "stale"})
+	cache.SetWithFrequency("b", response{etag: "fresh"}, 2)
+	cache.SetWithFrequency("c", response{etag: "stale"}, 3)
+	cache.Set("d", 4)
+	before := cache.Snapshot()
+
+	stale := cache.FindByValue(func(v interface{}) bool {
+		r, ok := v.(response)
+		return ok && r.etag == "stale"
+	})
+	assert.Equal(t, []Entry{
+		{Key: "a", Value: response{etag: "stale"}, Freq: 1},
+		{Key: "c", Value: response{etag: "stale"}, Freq: 3},
+	}, stale)
+	assert.Nil(t, cache.FindByValue(func(v interface{}) bool { return false }))
+
+	_, _, changed := cache.Diff(before)
+	assert.Nil(t, changed)
+	assert.Equal(t, 4, cache.Size())
+}
+
+func TestCache_GetEntry(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithTrackAccessTime())
+	_, ok := cache.GetEntry("a")
+	assert.False(t, ok)
+
+	cache.Set("a", 1)
+	clock.Advance(time.Second)
+	cache.SetWithTTL("a", 2, time.Minute)
+	clock.Advance(time.Second)
+	cache.Get("a")
+	clock.Advance(time.Second)
+
+	info, ok := cache.GetEntry("a")
+	assert.True(t, ok)
+	assert.Equal(t, EntryInfo{
+		Entry:          Entry{Key: "a", Value: 2, Freq: 3, ExpiresAt: time.Unix(61, 0)},
+		CreatedAt:      time.Unix(0, 0),
+		UpdatedAt:      time.Unix(1, 0),
+		LastAccessedAt: time.Unix(2, 0),
+	}, info)
+
+	// looking at an entry isn't an access
+	info, _ = cache.GetEntry("a")
+	assert.Equal(t, 3, info.Freq)
+	assert.Equal(t, time.Unix(2, 0), info.LastAccessedAt)
+
+	// hits aren't timed without TrackAccessTime
+	cache = New(0)
+	cache.Set("a", 1)
+	cache.Get("a")
+	info, _ = cache.GetEntry("a")
+	assert.True(t, info.LastAccessedAt.IsZero())
+	assert.True(t, info.ExpiresAt.IsZero())
+}
+
+func TestCache_SnapshotExpired(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	cache.SetWithTTL("a", 1, time.Second)
+	cache.SetWithTTL("b", 2, time.Hour)
+	cache.Set("c", 3)
+	clock.Advance(time.Second)
+
+	entries := cache.Snapshot()
+	assert.Equal(t, []Entry{
+		{Key: "b", Value: 2, Freq: 1, ExpiresAt: time.Unix(3600, 0)},
+		{Key: "c", Value: 3, Freq: 1},
+	}, entries)
+	assert.Equal(t, []string{"b", "c"}, cache.Keys())
+	assert.Len(t, cache.ColdestN(3), 2)
+	assert.Len(t, cache.SnapshotTopN(3), 2)
+	var seen []string
+	cache.ForEach(func(k string, v interface{}) { seen = append(seen, k) })
+	assert.Equal(t, []string{"b", "c"}, seen)
+
+	// the expired entries stay gone, the others keep expiring
+	restored := New(0, WithClock(clock))
+	restored.Restore(append(entries, Entry{Key: "a", Value: 1, Freq: 1, ExpiresAt: time.Unix(1, 0)}))
+	_, ok := restored.Get("a")
+	assert.False(t, ok)
+	clock.Advance(time.Hour)
+	assert.Equal(t, []string{"c"}, restored.Keys())
+
+	merged := New(0, WithClock(clock))
+	cache.SetWithTTL("d", 4, time.Minute)
+	merged.Merge(cache, nil, SumFrequencies)
+	assert.Equal(t, []string{"c", "d"}, merged.Keys())
+	clock.Advance(time.Minute)
+	assert.Equal(t, []string{"c"}, merged.Keys())
+	assert.NoError(t, merged.Verify())
+}
+
+func TestCache_SnapshotTieOrder(t *testing.T) {
+	cache := New(0)
+	for _, k := range []string{"c", "a", "d", "b"} {
+		cache.Set(k, k)
+	}
+	cache.Get("d")
+	cache.Get("a")
+	assert.Equal(t, []string{"c", "b", "d", "a"}, cache.Keys())
+	k, _, _, _ := cache.MostFrequent()
+	assert.Equal(t, "d", k)
+
+	var keys []string
+	cache.RangeOrdered(HottestFirst, func(k string, v interface{}) bool {
+		keys = append(keys, k)
+		return true
+	})
+	assert.Equal(t, []string{"a", "d", "b", "c"}, keys)
+}
@@ -0,0 +1,81 @@
+package lfu
+
+import "context"
+
+// WaitForSpace blocks until cache holds fewer items than its capacity, e.g.
+// because another goroutine removed or evicted some, or until ctx is done, in
+// which case it returns ctx.Err(). A cache without capacity always has space.
+// It lets producers that would rather wait than evict use cache as a bounded
+// buffer, throttled by its consumers.
+//
+// Space can be taken again by the time WaitForSpace returns, so producers
+// racing for it should use TrySet rather than Set.
+func (c *Cache) WaitForSpace(ctx context.Context) error {
+	c.lock()
+	for !c.hasSpace() {
+		if c.spaceFreed == nil {
+			c.spaceFreed = make(chan struct{})
+		}
+		freed := c.spaceFreed
+		c.unlock()
+
+		select {
+		case <-freed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c.lock()
+	}
+	c.unlock()
+	return nil
+}
+
+// WaitForKeys blocks until every one of keys is in cache, e.g. for a
+// readiness check waiting for a warmup storing them in the background, or
+// until ctx is done, in which case it returns ctx.Err(). Keys evicted before
+// the last one lands are waited for again.
+func (c *Cache) WaitForKeys(ctx context.Context, keys []string) error {
+	c.lock()
+	for !c.containsAll(keys) {
+		if c.keysStored == nil {
+			c.keysStored = make(chan struct{})
+		}
+		stored := c.keysStored
+		c.unlock()
+
+		select {
+		case <-stored:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		c.lock()
+	}
+	c.unlock()
+	return nil
+}
+
+// containsAll reports whether every one of keys is in cache. The caller must
+// hold the lock.
+func (c *Cache) containsAll(keys []string) bool {
+	for _, k := range keys {
+		if _, ok := c.lookup(c.key(k)); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasSpace reports whether cache holds fewer items than its capacity. The
+// caller must hold the lock.
+func (c *Cache) hasSpace() bool {
+	return c.cap <= 0 || len(c.kv) < c.cap
+}
+
+// signalSpace wakes up the callers of WaitForSpace if there is space. The
+// caller must hold the lock.
+func (c *Cache) signalSpace() {
+	if c.spaceFreed != nil && c.hasSpace() {
+		close(c.spaceFreed)
+		c.spaceFreed = nil
+	}
+}
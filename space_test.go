@@ -0,0 +1,107 @@
+package lfu
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_WaitForSpace(t *testing.T) {
+	assert.NoError(t, New(0).WaitForSpace(context.Background()))
+
+	cache := New(2)
+	assert.NoError(t, cache.WaitForSpace(context.Background()))
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+
+	// a producer blocked on a full cache
+	done := make(chan error)
+	go func() {
+		err := cache.WaitForSpace(context.Background())
+		if err == nil {
+			cache.TrySet("c", 3)
+		}
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("WaitForSpace returned with a full cache")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// updates and reads don't free anything
+	cache.Set("a", 10)
+	cache.Get("b")
+	select {
+	case <-done:
+		t.Fatal("WaitForSpace returned with a full cache")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// a consumer freeing a slot
+	cache.Remove("a")
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WaitForSpace didn't return after Remove")
+	}
+	v, ok := cache.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+
+	// cancelled while full
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cache.WaitForSpace(ctx))
+
+	// growing the capacity makes space too
+	go func() { done <- cache.WaitForSpace(context.Background()) }()
+	time.Sleep(10 * time.Millisecond)
+	cache.Resize(3)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WaitForSpace didn't return after Resize")
+	}
+}
+
+func TestCache_WaitForKeys(t *testing.T) {
+	cache := New(0)
+	assert.NoError(t, cache.WaitForKeys(context.Background(), nil))
+
+	keys := []string{"a", "b", "c"}
+	done := make(chan error)
+	go func() { done <- cache.WaitForKeys(context.Background(), keys) }()
+
+	// every key but the last
+	stored := make(chan struct{})
+	go func() {
+		cache.Set("b", 2)
+		cache.SetWithFrequency("a", 1, 3)
+		cache.Set("x", 0)
+		close(stored)
+	}()
+	<-stored
+	select {
+	case <-done:
+		t.Fatal("WaitForKeys returned before c was stored")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cache.Set("c", 3)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WaitForKeys didn't return after the last key was stored")
+	}
+
+	// cancelled while a key is missing
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, cache.WaitForKeys(ctx, []string{"a", "d"}))
+}
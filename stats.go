@@ -0,0 +1,181 @@
+package lfu
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// hit records a lookup that found item, counting it as an access. The caller
+// must hold the lock.
+func (c *Cache) hit(item *kvItem) {
+	c.decayIfDue()
+	atomic.AddUint64(&c.hits, 1)
+	c.pending.hits++
+	c.recent.record(true, c.hitWindow)
+	if a := c.admission; a != nil {
+		a.record(item.k)
+		if item.windowed != nil {
+			a.window.MoveToFront(item.windowed)
+		}
+	}
+	if c.segments != nil && !item.protected {
+		c.protect(item)
+	}
+	if c.stampHits {
+		item.accessedAt = c.now()
+	}
+	c.recordAccess(item.k, true)
+	c.watched(item.k, item.v, true)
+	c.promote(item)
+	c.adapt()
+}
+
+// miss records a lookup of k that wasn't in cache. The caller must hold the
+// lock.
+func (c *Cache) miss(k string) {
+	atomic.AddUint64(&c.missed, 1)
+	c.pending.misses++
+	c.recent.record(false, c.hitWindow)
+	if c.admission != nil {
+		c.admission.record(k)
+	}
+	c.recordAccess(k, false)
+	c.watched(k, nil, false)
+	if c.misses != nil {
+		c.misses.record(k)
+	}
+	c.adapt()
+}
+
+// CurrentHitRatio returns the fraction of lookups so far that found their key
+// in cache, or 0 before the first lookup.
+func (c *Cache) CurrentHitRatio() float64 {
+	c.lock()
+	defer c.unlock()
+
+	if c.lookups() == 0 {
+		return 0
+	}
+	return float64(c.hits) / float64(c.lookups())
+}
+
+// lookups returns the number of lookups so far. The caller must hold the
+// lock.
+func (c *Cache) lookups() uint64 {
+	return c.hits + c.missed
+}
+
+// defaultRecentWindow is the number of lookups RecentHitRatio covers when no
+// HitRatioWindow is configured.
+const defaultRecentWindow = 1000
+
+// recentLookups is a ring of the outcomes of the latest lookups.
+type recentLookups struct {
+	hits    []bool
+	next    int
+	n, nhit int
+}
+
+// record adds the outcome of a lookup, forgetting the oldest one once size
+// lookups are recorded.
+func (r *recentLookups) record(hit bool, size int) {
+	if r.hits == nil {
+		if size <= 0 {
+			size = defaultRecentWindow
+		}
+		r.hits = make([]bool, size)
+	}
+
+	if r.n == len(r.hits) {
+		if r.hits[r.next] {
+			r.nhit--
+		}
+	} else {
+		r.n++
+	}
+	r.hits[r.next] = hit
+	if hit {
+		r.nhit++
+	}
+	r.next = (r.next + 1) % len(r.hits)
+}
+
+// RecentHitRatio returns the fraction of the latest lookups that found their
+// key in cache, over a window of Config.HitRatioWindow lookups, or 0 before
+// the first lookup. Unlike CurrentHitRatio, it follows changes of the
+// workload rather than averaging them with everything seen so far.
+func (c *Cache) RecentHitRatio() float64 {
+	c.lock()
+	defer c.unlock()
+
+	if c.recent.n == 0 {
+		return 0
+	}
+	return float64(c.recent.nhit) / float64(c.recent.n)
+}
+
+// CacheStats is a point-in-time copy of the counters of a cache.
+type CacheStats struct {
+	Hits, Misses uint64
+	Evictions    uint64
+	Size         int
+
+	// LockWaits is the number of calls that found the lock taken, and
+	// LockWaited the total time they waited for it.
+	LockWaits  uint64
+	LockWaited time.Duration
+
+	// EvictionRuns is the number of times a full cache evicted to make room
+	// for a new key, each evicting up to the configured EvictionBatch, or
+	// down to the LowWatermark, so Evictions / EvictionRuns tells how well
+	// eviction amortizes.
+	EvictionRuns  uint64
+	EvictionBatch int
+	LowWatermark  float64
+}
+
+// Stats returns the hits and misses of the lookups so far, the number of
+// evictions, the number of items in cache, and the contention for the lock.
+// It reads counters maintained atomically rather than taking the lock, so it
+// is cheap enough to poll, but the counters may be a call apart from each
+// other, and Size doesn't reflect a call still holding the lock.
+//
+// Evictions counts the items the cache chose to drop, to stay within its
+// bounds or on Evict. A Remove, or an expiry, lowers Size without counting
+// as one.
+func (c *Cache) Stats() CacheStats {
+	return CacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.missed),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Size:      int(atomic.LoadInt64(&c.size)),
+
+		LockWaits:  atomic.LoadUint64(&c.lockWaits),
+		LockWaited: time.Duration(atomic.LoadInt64(&c.lockWaited)),
+
+		EvictionRuns:  atomic.LoadUint64(&c.evictionRuns),
+		EvictionBatch: c.evictionBatch,
+		LowWatermark:  c.lowWatermark,
+	}
+}
+
+// FrequencyHistogram returns how many items in cache have each range of
+// frequency counts, in the buckets of EvictedFrequencyHistogram: the i-th
+// bucket counts the items with a frequency in [2^i, 2^(i+1)). Trailing
+// empty buckets are left out. It takes the lock, and costs O(number of
+// distinct frequency counts).
+func (c *Cache) FrequencyHistogram() []uint64 {
+	c.lock()
+	defer c.unlock()
+
+	var histogram []uint64
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*freqNode)
+		b := freqBucket(node.freq)
+		for len(histogram) <= b {
+			histogram = append(histogram, 0)
+		}
+		histogram[b] += uint64(node.n)
+	}
+	return histogram
+}
@@ -0,0 +1,86 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_CurrentHitRatio(t *testing.T) {
+	cache := New(0)
+	assert.Equal(t, 0.0, cache.CurrentHitRatio())
+
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("b")
+	cache.GetBatch([]string{"a", "a", "c"})
+	assert.Equal(t, 0.6, cache.CurrentHitRatio())
+}
+
+func TestCache_RecentHitRatio(t *testing.T) {
+	cache := New(0, WithHitRatioWindow(10))
+	assert.Equal(t, 0.0, cache.RecentHitRatio())
+
+	cache.Set("a", 1)
+	for i := 0; i < 90; i++ {
+		cache.Get("b")
+	}
+	assert.Equal(t, 0.0, cache.RecentHitRatio())
+
+	// the window only holds the latest 10 lookups
+	for i := 0; i < 10; i++ {
+		cache.Get("a")
+	}
+	assert.Equal(t, 1.0, cache.RecentHitRatio())
+	assert.Equal(t, 0.1, cache.CurrentHitRatio())
+
+	for i := 0; i < 3; i++ {
+		cache.Get("b")
+	}
+	assert.InDelta(t, 0.7, cache.RecentHitRatio(), 1e-9)
+}
+
+func TestCache_Stats(t *testing.T) {
+	cache := New(0)
+	assert.Equal(t, CacheStats{}, cache.Stats())
+
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.Get("a")
+	cache.Get("b")
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Size: 1}, cache.Stats())
+
+	cache.Resize(1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	cache.Remove("c")
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Evictions: 2, EvictionRuns: 2, Size: 0}, cache.Stats())
+
+	cache.Reset(0)
+	assert.Equal(t, CacheStats{}, cache.Stats())
+}
+
+func TestCache_StatsWithoutLock(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 1)
+	cache.Get("a")
+
+	cache.lock()
+	defer cache.unlock()
+	assert.Equal(t, CacheStats{Hits: 1, Size: 1}, cache.Stats())
+}
+
+func TestCache_FrequencyHistogram(t *testing.T) {
+	cache := New(0)
+	assert.Empty(t, cache.FrequencyHistogram())
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.SetWithFrequency("c", 3, 2)
+	cache.SetWithFrequency("d", 4, 3)
+	cache.SetWithFrequency("e", 5, 9)
+	assert.Equal(t, []uint64{2, 2, 0, 1}, cache.FrequencyHistogram())
+
+	cache.Evict(2)
+	assert.Equal(t, []uint64{2}, cache.EvictedFrequencyHistogram())
+	assert.Equal(t, []uint64{0, 2, 0, 1}, cache.FrequencyHistogram())
+}
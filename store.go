@@ -0,0 +1,84 @@
+package lfu
+
+// SecondLevel is a backing store a TieredStore falls back on, e.g. Redis or
+// a disk, usually larger and slower than memory, and possibly failing.
+type SecondLevel interface {
+	// Get returns the v stored for k, with ok false if there is none.
+	Get(k string) (v interface{}, ok bool, err error)
+	// Set stores the kv pair.
+	Set(k string, v interface{}) error
+	// Delete deletes k, which may not be there.
+	Delete(k string) error
+}
+
+// TieredStore is a *Cache in front of a SecondLevel, making the cache the L1
+// of a caching hierarchy. Misses fall through to the SecondLevel, and what
+// the cache evicts to make room is demoted into it rather than lost, if it
+// was looked up often enough.
+//
+// A key is in one tier, or in both with the same value: Set deletes k from
+// the SecondLevel, whose copy would be stale, and a SecondLevel hit is
+// copied into the cache. Calls of the SecondLevel are made outside the lock
+// of the cache, so an entry being demoted is briefly in neither tier.
+type TieredStore struct {
+	l1      *Cache
+	l2      SecondLevel
+	minFreq int
+}
+
+// NewTieredStore composes l1 and l2 into a TieredStore demoting the entries
+// l1 evicts with a frequency count of at least minFreq, or all of them for a
+// minFreq up to 1.
+func NewTieredStore(l1 *Cache, l2 SecondLevel, minFreq int) *TieredStore {
+	return &TieredStore{l1: l1, l2: l2, minFreq: minFreq}
+}
+
+// Get returns the v related to k from the cache, or from the SecondLevel,
+// copying it into the cache. An error of the SecondLevel is returned as is.
+func (t *TieredStore) Get(k string) (v interface{}, ok bool, err error) {
+	if v, ok = t.l1.Get(k); ok {
+		return
+	}
+	if v, ok, err = t.l2.Get(k); err != nil || !ok {
+		return
+	}
+	// unless a Set stored a newer value meanwhile
+	return v, true, t.demote(t.l1.setCollecting(k, v, true))
+}
+
+// Set stores the kv pair in the cache, deleting k from the SecondLevel and
+// demoting what the cache evicts into it. It returns the first error of the
+// SecondLevel, the kv pair being stored regardless.
+func (t *TieredStore) Set(k string, v interface{}) error {
+	err := t.demote(t.l1.setCollecting(k, v, false))
+	if derr := t.l2.Delete(k); err == nil {
+		err = derr
+	}
+	return err
+}
+
+// Remove deletes k from both tiers, reporting whether it was in the cache.
+func (t *TieredStore) Remove(k string) (bool, error) {
+	removed := t.l1.Remove(k)
+	return removed, t.l2.Delete(k)
+}
+
+// L1 returns the cache in front of the SecondLevel.
+func (t *TieredStore) L1() *Cache {
+	return t.l1
+}
+
+// demote stores the evicted entries hot enough in the SecondLevel, returning
+// the first error, if any.
+func (t *TieredStore) demote(evicted []Entry) error {
+	var err error
+	for _, e := range evicted {
+		if e.Freq < t.minFreq {
+			continue
+		}
+		if serr := t.l2.Set(e.Key, e.Value); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
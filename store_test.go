@@ -0,0 +1,96 @@
+package lfu
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// mapStore is a SecondLevel in a map, failing every call while err is set.
+type mapStore struct {
+	kv  map[string]interface{}
+	err error
+}
+
+func (m *mapStore) Get(k string) (interface{}, bool, error) {
+	if m.err != nil {
+		return nil, false, m.err
+	}
+	v, ok := m.kv[k]
+	return v, ok, nil
+}
+
+func (m *mapStore) Set(k string, v interface{}) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.kv[k] = v
+	return nil
+}
+
+func (m *mapStore) Delete(k string) error {
+	if m.err != nil {
+		return m.err
+	}
+	delete(m.kv, k)
+	return nil
+}
+
+func TestTieredStore(t *testing.T) {
+	l2 := &mapStore{kv: map[string]interface{}{"z": 26}}
+	store := NewTieredStore(New(2), l2, 2)
+	assert.NotNil(t, store.L1())
+
+	assert.Nil(t, store.Set("a", 1))
+	assert.Nil(t, store.Set("b", 2))
+	store.Get("b")
+
+	// a is too cold to be demoted, b isn't
+	assert.Nil(t, store.Set("c", 3))
+	store.Get("c")
+	store.Get("c")
+	assert.Nil(t, store.Set("d", 4))
+	assert.Equal(t, map[string]interface{}{"z": 26, "b": 2}, l2.kv)
+
+	// misses fall through, and L2 hits are copied into L1
+	v, ok, err := store.Get("b")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+	_, ok = store.L1().Peek("b")
+	assert.True(t, ok)
+	_, ok, err = store.Get("x")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// Set drops the stale copy of L2
+	assert.Nil(t, store.Set("b", 22))
+	_, ok = l2.kv["b"]
+	assert.False(t, ok)
+
+	removed, err := store.Remove("z")
+	assert.False(t, removed)
+	assert.Nil(t, err)
+	assert.Empty(t, l2.kv)
+}
+
+func TestTieredStore_Errors(t *testing.T) {
+	l2 := &mapStore{kv: map[string]interface{}{}}
+	store := NewTieredStore(New(1), l2, 0)
+	store.Set("a", 1)
+
+	l2.err = errors.New("down")
+	_, _, err := store.Get("x")
+	assert.EqualError(t, err, "down")
+
+	// the kv pair is stored in L1 anyway
+	assert.EqualError(t, store.Set("b", 2), "down")
+	v, ok, err := store.Get("b")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+
+	removed, err := store.Remove("b")
+	assert.True(t, removed)
+	assert.EqualError(t, err, "down")
+}
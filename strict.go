@@ -0,0 +1,20 @@
+package lfu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidOperation is wrapped by the errors cache panics with in Strict
+// mode, on a call that can only be a bug of the caller.
+var ErrInvalidOperation = errors.New("lfu: invalid operation")
+
+// invalid reports a call that can only be a bug of the caller, described by
+// format and args. In Strict mode it panics with an error wrapping
+// ErrInvalidOperation, otherwise it returns, and the caller goes on with its
+// lenient handling of the call, e.g. treating a negative count as zero.
+func (c *Cache) invalid(format string, args ...interface{}) {
+	if c.strict {
+		panic(fmt.Errorf("%w: %s", ErrInvalidOperation, fmt.Sprintf(format, args...)))
+	}
+}
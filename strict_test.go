@@ -0,0 +1,57 @@
+package lfu
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_Strict(t *testing.T) {
+	// lenient by default
+	cache := New(0, WithMaxKeyLength(2, false))
+	cache.Set("a", 1)
+	assert.NotPanics(t, func() { cache.Evict(-1) })
+	assert.True(t, cache.SetFrequency("a", 0))
+	assert.True(t, cache.TouchBy("a", -1))
+	assert.False(t, cache.TrySet("abc", 2))
+	assert.Equal(t, 1, cache.Size())
+	assert.NoError(t, cache.Verify())
+
+	cache = New(0, WithMaxKeyLength(2, false), WithStrict())
+	cache.Set("a", 1)
+	for _, tc := range []struct {
+		name string
+		fn   func()
+		msg  string
+	}{
+		{"Evict", func() { cache.Evict(-1) }, "Evict of -1 items"},
+		{"SetFrequency", func() { cache.SetFrequency("a", 0) }, `SetFrequency of "a" to frequency 0`},
+		{"SetWithFrequency", func() { cache.SetWithFrequency("b", 2, -3) }, `SetWithFrequency of "b" to frequency -3`},
+		{"TouchBy", func() { cache.TouchBy("a", -1) }, `TouchBy of "a" by -1`},
+		{"TrySet", func() { cache.TrySet("abc", 2) }, "key of 3 bytes exceeds MaxKeyLength 2"},
+		{"SetMultiple", func() { cache.SetMultiple(map[string]interface{}{"a": 3, "abc": 2}) }, "key of 3 bytes exceeds MaxKeyLength 2"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			defer func() {
+				err, ok := recover().(error)
+				assert.True(t, ok)
+				assert.True(t, errors.Is(err, ErrInvalidOperation))
+				assert.EqualError(t, err, "lfu: invalid operation: "+tc.msg)
+			}()
+			tc.fn()
+		})
+	}
+
+	// the lock is released and nothing changed
+	assert.Equal(t, []string{"a"}, cache.Keys())
+	v, _ := cache.Peek("a")
+	assert.Equal(t, 1, v)
+	assert.NoError(t, cache.Verify())
+
+	// valid calls are left alone
+	assert.NotPanics(t, func() {
+		cache.Evict(0)
+		cache.TouchBy("a", 0)
+		cache.SetFrequency("a", 1)
+	})
+}
@@ -0,0 +1,42 @@
+package lfu
+
+// Map is a facade of Typed with the method set of sync.Map, to swap a
+// sync.Map growing without bounds for a cache of bounded capacity with few
+// code changes. Unlike a sync.Map, it forgets the least frequently used keys
+// once full, so a Load may miss a key that was stored.
+type Map[K comparable, V any] struct {
+	c *Typed[K, V]
+}
+
+// NewMap creates a Map backed by a Typed cache of the given capacity.
+func NewMap[K comparable, V any](cap int) *Map[K, V] {
+	return &Map[K, V]{c: NewTyped[K, V](cap)}
+}
+
+// Load returns the value stored for k, counting it as an access like Get.
+func (m *Map[K, V]) Load(k K) (v V, ok bool) {
+	return m.c.Get(k)
+}
+
+// Store sets the value for k, like Set.
+func (m *Map[K, V]) Store(k K, v V) {
+	m.c.Set(k, v)
+}
+
+// Delete deletes the value for k.
+func (m *Map[K, V]) Delete(k K) {
+	m.c.Remove(k)
+}
+
+// LoadOrStore returns the value stored for k, with loaded true, if there is
+// one. Otherwise it stores v and returns it, with loaded false.
+func (m *Map[K, V]) LoadOrStore(k K, v V) (actual V, loaded bool) {
+	return m.c.GetOrSet(k, v)
+}
+
+// Range calls fn for every key and value in the map, from the least to the
+// most frequently used, until fn returns false. Like Typed.Range, it ranges
+// over a copy taken when it starts, so fn may use the map.
+func (m *Map[K, V]) Range(fn func(k K, v V) bool) {
+	m.c.Range(fn)
+}
@@ -0,0 +1,67 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMap(t *testing.T) {
+	type userID string
+	m := NewMap[userID, int](2)
+
+	_, ok := m.Load("a")
+	assert.False(t, ok)
+
+	m.Store("a", 1)
+	v, ok := m.Load("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	actual, loaded := m.LoadOrStore("a", 2)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, actual)
+	actual, loaded = m.LoadOrStore("b", 3)
+	assert.False(t, loaded)
+	assert.Equal(t, 3, actual)
+
+	m.Delete("b")
+	m.Delete("missing")
+	_, ok = m.Load("b")
+	assert.False(t, ok)
+
+	// bounded, unlike sync.Map: the least frequently used key goes
+	m.Store("c", 4)
+	m.Store("d", 5)
+	seen := map[userID]int{}
+	m.Range(func(k userID, v int) bool {
+		seen[k] = v
+		return true
+	})
+	assert.Equal(t, map[userID]int{"a": 1, "d": 5}, seen)
+
+	n := 0
+	m.Range(func(k userID, v int) bool {
+		n++
+		return false
+	})
+	assert.Equal(t, 1, n)
+}
+
+func TestMap_NilInterface(t *testing.T) {
+	m := NewMap[string, error](0)
+	m.Store("a", nil)
+	v, ok := m.Load("a")
+	assert.True(t, ok)
+	assert.Nil(t, v)
+}
+
+func TestMap_ComparableKey(t *testing.T) {
+	type point struct{ x, y int }
+	m := NewMap[point, string](0)
+	m.Store(point{1, 2}, "p")
+	v, ok := m.Load(point{1, 2})
+	assert.True(t, ok)
+	assert.Equal(t, "p", v)
+	_, ok = m.Load(point{2, 1})
+	assert.False(t, ok)
+}
@@ -0,0 +1,81 @@
+package lfu
+
+// SetWithTags works like Set, and tags the kv pair, so InvalidateTag of any
+// of tags deletes it, e.g. to drop every cached variant of a record when the
+// record changes. Like the metadata of SetWithMeta, the tags belong to the
+// value: a later Set of k, of any kind, clears them, and SetWithTags
+// replaces them.
+func (c *Cache) SetWithTags(k string, v interface{}, tags ...string) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	c.set(k, v)
+	if item, ok := c.kv[k]; ok {
+		c.tag(item, tags)
+	}
+}
+
+// InvalidateTag deletes every key tagged with tag by SetWithTags, and returns
+// how many it deleted. Unlike RemoveByPrefix, it costs O(number of keys
+// tagged), however large cache is.
+func (c *Cache) InvalidateTag(tag string) int {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	items := c.tags[tag]
+	n := len(items)
+	for item := range items {
+		c.deleteItem(item, ReasonDeleted)
+	}
+	return n
+}
+
+// GetTags returns the tags of k set by SetWithTags. ok reports whether k is
+// in cache. Like Peek, it doesn't count as an access.
+func (c *Cache) GetTags(k string) (tags []string, ok bool) {
+	c.lock()
+	defer c.unlock()
+
+	item, ok := c.lookup(c.key(k))
+	if !ok {
+		return nil, false
+	}
+	return append([]string(nil), item.tags...), true
+}
+
+// tag replaces the tags of item. The caller must hold the lock.
+func (c *Cache) tag(item *kvItem, tags []string) {
+	c.untag(item)
+	if len(tags) == 0 {
+		return
+	}
+	if c.tags == nil {
+		c.tags = make(map[string]map[*kvItem]struct{})
+	}
+	for _, tag := range tags {
+		items, ok := c.tags[tag]
+		if !ok {
+			items = make(map[*kvItem]struct{})
+			c.tags[tag] = items
+		}
+		if _, dup := items[item]; !dup {
+			items[item] = placeholder
+			item.tags = append(item.tags, tag)
+		}
+	}
+}
+
+// untag drops the tags of item. The caller must hold the lock.
+func (c *Cache) untag(item *kvItem) {
+	for _, tag := range item.tags {
+		items := c.tags[tag]
+		delete(items, item)
+		if len(items) == 0 {
+			delete(c.tags, tag)
+		}
+	}
+	item.tags = nil
+}
@@ -0,0 +1,62 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_SetWithTags(t *testing.T) {
+	cache := New(0)
+	cache.SetWithTags("user:1:profile", 1, "user:1")
+	cache.SetWithTags("user:1:avatar", 2, "user:1", "avatars")
+	cache.SetWithTags("user:2:avatar", 3, "user:2", "avatars", "avatars")
+	cache.Set("other", 4)
+
+	tags, ok := cache.GetTags("user:2:avatar")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"user:2", "avatars"}, tags)
+	tags, ok = cache.GetTags("other")
+	assert.True(t, ok)
+	assert.Empty(t, tags)
+	_, ok = cache.GetTags("missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, 2, cache.InvalidateTag("user:1"))
+	assert.Equal(t, 0, cache.InvalidateTag("user:1"))
+	_, ok = cache.Get("user:1:avatar")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Size())
+	assert.NoError(t, cache.Verify())
+
+	// a plain Set clears the tags, SetWithTags replaces them
+	cache.SetWithTags("other", 5, "avatars")
+	cache.SetWithTags("user:2:avatar", 6, "user:2")
+	assert.Equal(t, 1, cache.InvalidateTag("avatars"))
+	cache.Set("user:2:avatar", 7)
+	assert.Equal(t, 0, cache.InvalidateTag("user:2"))
+	assert.Equal(t, 1, cache.Size())
+	assert.Empty(t, cache.tags)
+	assert.NoError(t, cache.Verify())
+}
+
+func TestCache_TagsEvicted(t *testing.T) {
+	var deleted []string
+	cache := New(2, WithEvictionCallback(func(k string, v interface{}, reason EvictReason) {
+		if reason == ReasonDeleted {
+			deleted = append(deleted, k)
+		}
+	}))
+	cache.SetWithTags("a", 1, "t")
+	cache.SetWithTags("b", 2, "t")
+	cache.Get("b")
+	cache.Set("c", 3)
+
+	// the evicted key left the tag
+	assert.Equal(t, 1, cache.InvalidateTag("t"))
+	assert.Equal(t, []string{"b"}, deleted)
+	assert.NoError(t, cache.Verify())
+
+	cache.SetWithTags("d", 4, "t")
+	cache.Reset(2)
+	assert.Equal(t, 0, cache.InvalidateTag("t"))
+}
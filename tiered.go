@@ -0,0 +1,94 @@
+package lfu
+
+// NewTiered composes two caches into a two-tier LFU: a small, fast l1 in
+// front of a larger l2.
+//
+// Set writes to l1. What l1 evicts to make room is demoted into l2 rather than
+// lost, provided l1 is a *Cache: other LFUs can't tell what they evict. Get
+// looks in l1, then in l2, promoting an l2 hit back into l1, so a key lives
+// in one tier at a time. Size is the sum of both tiers, and Evict evicts from
+// l2 first, then from l1, without demotion.
+func NewTiered(l1, l2 LFU) LFU {
+	return &tiered{l1: l1, l2: l2}
+}
+
+type tiered struct {
+	l1, l2 LFU
+}
+
+// Set stores the kv pair in l1, demoting what it evicts into l2.
+func (t *tiered) Set(k string, v interface{}) {
+	t.l2.Remove(k)
+	for _, e := range t.setL1(k, v) {
+		t.l2.Set(e.Key, e.Value)
+	}
+}
+
+// Get returns the v related to k from l1, or from l2, promoting it into l1.
+func (t *tiered) Get(k string) (v interface{}, ok bool) {
+	if v, ok = t.l1.Get(k); ok {
+		return
+	}
+	if v, ok = t.l2.Get(k); ok {
+		t.Set(k, v)
+	}
+	return
+}
+
+// Evict evicts n items, from l2 first.
+func (t *tiered) Evict(n int) {
+	if n <= 0 {
+		return
+	}
+
+	before := t.l2.Size()
+	t.l2.Evict(n)
+	if n -= before - t.l2.Size(); n > 0 {
+		t.l1.Evict(n)
+	}
+}
+
+// Size returns the number of items in both tiers.
+func (t *tiered) Size() int {
+	return t.l1.Size() + t.l2.Size()
+}
+
+// Remove deletes k from both tiers.
+func (t *tiered) Remove(k string) bool {
+	removed := t.l1.Remove(k)
+	return t.l2.Remove(k) || removed
+}
+
+// Purge deletes every item of both tiers.
+func (t *tiered) Purge() {
+	t.l1.Purge()
+	t.l2.Purge()
+}
+
+// setL1 stores the kv pair in l1 and returns the entries it evicted.
+func (t *tiered) setL1(k string, v interface{}) []Entry {
+	c, ok := t.l1.(*Cache)
+	if !ok {
+		t.l1.Set(k, v)
+		return nil
+	}
+	return c.setCollecting(k, v, false)
+}
+
+// setCollecting works like Set, or like SetIfAbsent if ifAbsent, and returns
+// the entries it evicted.
+func (c *Cache) setCollecting(k string, v interface{}, ifAbsent bool) []Entry {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	if _, ok := c.lookup(k); ok && ifAbsent {
+		return nil
+	}
+	c.collecting = true
+	c.set(k, v)
+	evicted := c.collected
+	c.collecting, c.collected = false, nil
+	return evicted
+}
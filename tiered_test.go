@@ -0,0 +1,81 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTiered(t *testing.T) {
+	l1, l2 := New(2), New(4)
+	cache := NewTiered(l1, l2)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Get("b")
+	assert.Equal(t, 2, l1.Size())
+	assert.Equal(t, 0, l2.Size())
+
+	// "a" is evicted from l1 and lands in l2
+	cache.Set("c", 3)
+	_, ok := l1.kv["a"]
+	assert.False(t, ok)
+	v, ok := l2.kv["a"]
+	assert.True(t, ok)
+	assert.Equal(t, 1, v.v)
+	assert.Equal(t, 3, cache.Size())
+
+	// getting "a" promotes it back, demoting "c"
+	v2, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v2)
+	_, ok = l1.kv["a"]
+	assert.True(t, ok)
+	_, ok = l2.kv["a"]
+	assert.False(t, ok)
+	_, ok = l2.kv["c"]
+	assert.True(t, ok)
+	assert.Equal(t, 3, cache.Size())
+
+	_, ok = cache.Get("x")
+	assert.False(t, ok)
+
+	cache.Evict(2)
+	assert.Equal(t, 0, l2.Size())
+	assert.Equal(t, 1, l1.Size())
+	cache.Evict(0)
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestTiered_OpaqueL1(t *testing.T) {
+	l2 := New(0)
+	cache := NewTiered(NewTiered(New(1), New(0)), l2)
+
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	assert.Equal(t, 0, l2.Size())
+	assert.Equal(t, 2, cache.Size())
+
+	v, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+}
+
+func TestTiered_RemovePurge(t *testing.T) {
+	l1, l2 := New(1), New(0)
+	cache := NewTiered(l1, l2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Set("c", 3)
+	assert.Equal(t, 2, l2.Size())
+
+	assert.True(t, cache.Remove("a"))
+	assert.True(t, cache.Remove("c"))
+	assert.False(t, cache.Remove("c"))
+	assert.Equal(t, 1, cache.Size())
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+
+	cache.Purge()
+	assert.Equal(t, 0, l1.Size())
+	assert.Equal(t, 0, l2.Size())
+}
@@ -0,0 +1,30 @@
+package lfu
+
+import "container/list"
+
+// coalesce merges adjacent freq nodes until there are at most MaxTiers, each
+// time the pair holding the fewest items, so the merge moves as few items as
+// possible. The items of the higher node join the lower one, losing the
+// accesses that set them apart: their frequency is understated, never
+// overstated, and within a merged node they are as likely to be evicted as
+// the items they joined. The caller must hold the lock.
+func (c *Cache) coalesce() {
+	if c.maxTiers <= 0 {
+		return
+	}
+
+	for c.freqList.Len() > c.maxTiers {
+		var lo *list.Element
+		fewest := 0
+		for e := c.freqList.Front(); e.Next() != nil; e = e.Next() {
+			n := e.Value.(*freqNode).n + e.Next().Value.(*freqNode).n
+			if lo == nil || n < fewest {
+				lo, fewest = e, n
+			}
+		}
+
+		hi := lo.Next()
+		mergeNodes(lo, hi)
+		c.freqList.Remove(hi)
+	}
+}
@@ -0,0 +1,41 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestCache_MaxTiers(t *testing.T) {
+	cache := New(0, WithMaxTiers(8))
+	for i := 0; i < 100; i++ {
+		cache.SetWithFrequency(strconv.Itoa(i), i, i+1)
+		assert.True(t, cache.FreqListLength() <= 8)
+	}
+	for i := 0; i < 100; i++ {
+		cache.Get(strconv.Itoa(i % 10))
+		assert.True(t, cache.FreqListLength() <= 8)
+	}
+	assert.NoError(t, cache.Verify())
+	assert.Equal(t, 100, cache.Size())
+
+	// frequencies are understated, but the coldest keys still go first and
+	// the hottest stay
+	cache.Evict(50)
+	for i := 0; i < 10; i++ {
+		_, ok := cache.kv[strconv.Itoa(i)]
+		assert.False(t, ok, i)
+	}
+	for i := 90; i < 100; i++ {
+		item, ok := cache.kv[strconv.Itoa(i)]
+		assert.True(t, ok, i)
+		assert.True(t, item.parent.Value.(*freqNode).freq <= i+1)
+	}
+
+	// no limit by default
+	cache = New(0)
+	for i := 0; i < 100; i++ {
+		cache.SetWithFrequency(strconv.Itoa(i), i, i+1)
+	}
+	assert.Equal(t, 100, cache.FreqListLength())
+}
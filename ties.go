@@ -0,0 +1,91 @@
+package lfu
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// TiePolicy picks which of the items sharing the lowest frequency count is
+// evicted first, which matters most at small capacities, where such ties are
+// the rule. An EvictionComparator takes precedence over it.
+type TiePolicy interface {
+	// Victim returns the index in candidates of the one to evict. The
+	// candidates are the items of the lowest frequency count that may be
+	// evicted, in the order they reached it, so the first is the least
+	// recently used. Victim is called under the lock of cache, and must
+	// neither call cache nor retain candidates, whose values it must not
+	// modify either.
+	Victim(candidates []EntryInfo) int
+}
+
+var (
+	// LRUTies evicts the least recently used of the candidates, which costs
+	// O(1). It is the default.
+	LRUTies TiePolicy = lruTies{}
+	// FIFOTies evicts the candidate stored first, however recently used.
+	FIFOTies TiePolicy = fifoTies{}
+)
+
+type lruTies struct{}
+
+func (lruTies) Victim(candidates []EntryInfo) int {
+	return 0
+}
+
+type fifoTies struct{}
+
+func (fifoTies) Victim(candidates []EntryInfo) int {
+	victim := 0
+	for i, e := range candidates {
+		if e.CreatedAt.Before(candidates[victim].CreatedAt) {
+			victim = i
+		}
+	}
+	return victim
+}
+
+// RandomTies returns a TiePolicy evicting a random candidate drawn from src,
+// which it may share between caches, e.g. the shards of a Sharded.
+func RandomTies(src rand.Source) TiePolicy {
+	return &randomTies{r: rand.New(src)}
+}
+
+type randomTies struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func (t *randomTies) Victim(candidates []EntryInfo) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.r.Intn(len(candidates))
+}
+
+// breaksTies reports whether the configured TiePolicy needs the candidates
+// of victimOf, rather than the first of them LRUTies picks.
+func (c *Cache) breaksTies() bool {
+	return c.ties != nil && c.ties != LRUTies
+}
+
+// tieBreak returns the victim the configured TiePolicy picks among the
+// candidates c.tied victimOf gathered. The caller must hold the lock.
+func (c *Cache) tieBreak() *kvItem {
+	c.tiedInfo = c.tiedInfo[:0]
+	for _, item := range c.tied {
+		c.tiedInfo = append(c.tiedInfo, item.info())
+	}
+	i := c.ties.Victim(c.tiedInfo)
+	if i < 0 || i >= len(c.tied) {
+		c.invalid("TiePolicy victim %d of %d candidates", i, len(c.tied))
+		i = 0
+	}
+	victim := c.tied[i]
+
+	// let go of the items and values until the next eviction
+	for i := range c.tied {
+		c.tied[i] = nil
+		c.tiedInfo[i] = EntryInfo{}
+	}
+	c.tied = c.tied[:0]
+	return victim
+}
@@ -0,0 +1,77 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// mruTies evicts the most recently used candidate.
+type mruTies struct{}
+
+func (mruTies) Victim(candidates []EntryInfo) int {
+	return len(candidates) - 1
+}
+
+// badTies returns an index out of range.
+type badTies struct{}
+
+func (badTies) Victim(candidates []EntryInfo) int {
+	return len(candidates)
+}
+
+func TestCache_TiePolicy(t *testing.T) {
+	// b and a tie at frequency 2, a stored first but b used first
+	evicted := func(p TiePolicy) string {
+		clock := NewManualClock(time.Unix(0, 0))
+		cache := New(2, WithClock(clock), WithTiePolicy(p))
+		cache.Set("a", 1)
+		clock.Advance(time.Second)
+		cache.Set("b", 2)
+		cache.Get("b")
+		cache.Get("a")
+		cache.Set("c", 3)
+		assert.NoError(t, cache.Verify())
+		for _, k := range []string{"a", "b"} {
+			if _, ok := cache.kv[k]; !ok {
+				return k
+			}
+		}
+		return ""
+	}
+
+	assert.Equal(t, "b", evicted(nil))
+	assert.Equal(t, "b", evicted(LRUTies))
+	assert.Equal(t, "a", evicted(FIFOTies))
+	assert.Equal(t, "a", evicted(mruTies{}))
+	assert.Equal(t, "b", evicted(badTies{}))
+
+	// the comparator takes precedence
+	cache := New(2, WithTiePolicy(mruTies{}), WithEvictionComparator(func(a, b Entry) bool {
+		return a.Key < b.Key
+	}))
+	cache.Set("b", 1)
+	cache.Set("a", 2)
+	cache.Set("c", 3)
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+}
+
+func TestCache_RandomTies(t *testing.T) {
+	ties := RandomTies(rand.NewSource(1))
+	seen := map[string]int{}
+	for i := 0; i < 100; i++ {
+		cache := New(3, WithTiePolicy(ties))
+		cache.Set("a", 1)
+		cache.Set("b", 2)
+		cache.Set("c", 3)
+		cache.Pin("c")
+		for _, k := range cache.EvictWithResult(1) {
+			seen[k.Key]++
+		}
+		assert.NoError(t, cache.Verify())
+	}
+	assert.Len(t, seen, 2)
+	assert.Equal(t, 100, seen["a"]+seen["b"])
+}
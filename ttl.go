@@ -0,0 +1,154 @@
+package lfu
+
+import "time"
+
+// EntryWithTTL is a kv pair to be stored by SetManyWithTTL, and how long it
+// lives.
+type EntryWithTTL struct {
+	Key   string
+	Value interface{}
+	TTL   time.Duration
+}
+
+// SetWithTTL works like Set, and makes the kv pair expire once ttl has
+// passed. An expired entry is never returned, and is removed on the next
+// lookup of k. A non-positive ttl means the entry doesn't expire. A later Set
+// of k without a TTL replaces it with the DefaultTTL, if any, or clears it.
+func (c *Cache) SetWithTTL(k string, v interface{}, ttl time.Duration) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	k = c.key(k)
+	c.set(k, v)
+	c.setTTL(c.kv[k], ttl)
+}
+
+// SetWithTTLFunc works like SetWithTTL, with the TTL that ttlFn returns for
+// v, e.g. for a token expiring at a time it holds itself. ttlFn is called
+// once, before taking the lock. A nil ttlFn, or a TTL that isn't positive,
+// means v doesn't expire, like for SetWithTTL, so callers should leave out
+// values expired already rather than store them.
+func (c *Cache) SetWithTTLFunc(k string, v interface{}, ttlFn func(v interface{}) time.Duration) {
+	var ttl time.Duration
+	if ttlFn != nil {
+		ttl = ttlFn(v)
+	}
+	c.SetWithTTL(k, v, ttl)
+}
+
+// GetOrSetWithTTL works like GetOrSet, and makes v expire once ttl has passed
+// if it gets stored. An expired entry counts as absent, and is replaced.
+func (c *Cache) GetOrSetWithTTL(k string, v interface{}, ttl time.Duration) (actual interface{}, loaded bool) {
+	c.lock()
+	defer c.unlock()
+
+	k = c.key(k)
+	actual, loaded, _ = c.getOrSet(k, v)
+	if !loaded {
+		c.setTTL(c.kv[k], ttl)
+	}
+	return
+}
+
+// SetManyWithTTL stores all the given kv pairs under a single lock, each one
+// expiring after its own TTL. Capacity is handled like SetMultiple does, by
+// frequency rather than expiry. When a key appears more than once, the last
+// one wins.
+func (c *Cache) SetManyWithTTL(items []EntryWithTTL) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	values := make(map[string]interface{}, len(items))
+	ttls := make(map[string]time.Duration, len(items))
+	for _, e := range items {
+		k := c.key(e.Key)
+		values[k] = e.Value
+		ttls[k] = e.TTL
+	}
+
+	c.setMultiple(values)
+	for k, ttl := range ttls {
+		c.setTTL(c.kv[k], ttl)
+	}
+}
+
+// setTTL makes item expire once ttl, less the configured TTLJitter, has
+// passed, or never for a non-positive ttl. item may be nil, if it didn't
+// make it into cache. The caller must hold the lock.
+func (c *Cache) setTTL(item *kvItem, ttl time.Duration) {
+	if item == nil {
+		return
+	}
+	defer c.expireAfter(item)
+	// every store of item sets its TTL, so this is where the ReadBuffer
+	// index learns of new values too
+	defer c.publish(item)
+	if ttl <= 0 {
+		item.expireAt = time.Time{}
+		return
+	}
+	if jitter := c.ttlJitter; jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		ttl -= time.Duration(c.random().Float64() * jitter * float64(ttl))
+	}
+	item.expireAt = c.now().Add(ttl)
+}
+
+// restoreExpiry makes item expire at expiresAt, the ExpiresAt of the Entry
+// it was restored from, unless that is zero, leaving the DefaultTTL it got
+// then. item may be nil. The caller must hold the lock.
+func (c *Cache) restoreExpiry(item *kvItem, expiresAt time.Time) {
+	if item == nil || expiresAt.IsZero() {
+		return
+	}
+	item.expireAt = expiresAt
+	c.expireAfter(item)
+	c.publish(item)
+}
+
+// GetStale works like Get, and also returns the value of k if it expired,
+// with expired set, e.g. to fall back on it when refreshing it fails. Unlike
+// Get, it keeps an expired entry in cache. Returning an expired value counts
+// as a miss, and not as an access of k. ok is false only if k isn't in cache.
+func (c *Cache) GetStale(k string) (v interface{}, expired bool, ok bool) {
+	c.lock()
+	defer c.unlock()
+	k = c.key(k)
+
+	item, ok := c.kv[k]
+	if !ok {
+		c.miss(k)
+		return nil, false, false
+	}
+	v = c.clone(item.v)
+	if c.expired(item) {
+		c.miss(k)
+		return v, true, true
+	}
+
+	c.hit(item)
+	return v, false, true
+}
+
+// lookup returns the item of k, removing it instead if it expired. The caller
+// must hold the lock.
+func (c *Cache) lookup(k string) (*kvItem, bool) {
+	item, ok := c.kv[k]
+	if !ok {
+		return nil, false
+	}
+	if c.expired(item) {
+		c.deleteItem(item, ReasonExpired)
+		return nil, false
+	}
+	return item, true
+}
+
+// expired reports whether item outlived its TTL.
+func (c *Cache) expired(item *kvItem) bool {
+	return !item.expireAt.IsZero() && !c.now().Before(item.expireAt)
+}
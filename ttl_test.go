@@ -0,0 +1,242 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCache_SetWithTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+
+	cache.SetWithTTL("a", 1, time.Minute)
+	cache.SetWithTTL("b", 2, 0)
+	cache.SetWithTTL("c", 3, time.Minute)
+
+	clock.Advance(time.Minute - time.Nanosecond)
+	v, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// a plain Set clears the TTL
+	cache.Set("c", 4)
+
+	clock.Advance(time.Nanosecond)
+	v, ok = cache.Get("a")
+	assert.False(t, ok)
+	assert.Nil(t, v)
+	_, ok = cache.kv["a"]
+	assert.False(t, ok)
+
+	_, ok = cache.Get("b")
+	assert.True(t, ok)
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+
+	// an expired key is stored anew
+	cache.SetWithTTL("d", 5, time.Second)
+	cache.Get("d")
+	clock.Advance(time.Second)
+	cache.Set("d", 6)
+	assert.Equal(t, 1, cache.kv["d"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, 3, cache.Size())
+}
+
+func TestCache_SetManyWithTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(3, WithClock(clock))
+	cache.SetWithFrequency("z", 0, 5)
+
+	cache.SetManyWithTTL([]EntryWithTTL{
+		{Key: "a", Value: 1, TTL: time.Second},
+		{Key: "b", Value: 2, TTL: 3 * time.Second},
+		{Key: "c", Value: 3, TTL: 0},
+		{Key: "b", Value: 4, TTL: 2 * time.Second},
+		{Key: "d", Value: 5, TTL: time.Second},
+	})
+	assert.Equal(t, 3, cache.Size())
+	for _, k := range []string{"z", "d"} {
+		_, ok := cache.kv[k]
+		assert.False(t, ok)
+	}
+
+	clock.Advance(time.Second)
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	v, ok := cache.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 4, v)
+
+	clock.Advance(time.Second)
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	clock.Advance(time.Hour)
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+}
+
+func TestCache_TTLJitter(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	newCache := func() *Cache {
+		return New(0, WithClock(clock), WithTTLJitter(0.5), WithRandSource(rand.NewSource(1)))
+	}
+
+	cache := newCache()
+	for i := 0; i < 100; i++ {
+		cache.SetWithTTL(strconv.Itoa(i), i, 10*time.Second)
+	}
+
+	var earliest, latest time.Time
+	for _, item := range cache.kv {
+		if earliest.IsZero() || item.expireAt.Before(earliest) {
+			earliest = item.expireAt
+		}
+		if item.expireAt.After(latest) {
+			latest = item.expireAt
+		}
+	}
+	assert.False(t, earliest.Before(time.Unix(5, 0)), earliest)
+	assert.False(t, latest.After(time.Unix(10, 0)), latest)
+	// spread over most of the window
+	assert.True(t, latest.Sub(earliest) > 4*time.Second)
+
+	// the same seed gives the same expiries
+	again := newCache()
+	for i := 0; i < 100; i++ {
+		again.SetWithTTL(strconv.Itoa(i), i, 10*time.Second)
+	}
+	for k, item := range cache.kv {
+		assert.Equal(t, item.expireAt, again.kv[k].expireAt)
+	}
+
+	// no jitter by default
+	cache = New(0, WithClock(clock))
+	cache.SetWithTTL("a", 1, 10*time.Second)
+	assert.Equal(t, time.Unix(10, 0), cache.kv["a"].expireAt)
+}
+
+func TestCache_GetOrSetWithTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+
+	// absent
+	actual, loaded := cache.GetOrSetWithTTL("a", 1, time.Second)
+	assert.False(t, loaded)
+	assert.Equal(t, 1, actual)
+	assert.Equal(t, time.Unix(1, 0), cache.kv["a"].expireAt)
+
+	// present and fresh: the TTL is left alone
+	actual, loaded = cache.GetOrSetWithTTL("a", 2, time.Minute)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, actual)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, time.Unix(1, 0), cache.kv["a"].expireAt)
+
+	// present but expired
+	clock.Advance(time.Second)
+	actual, loaded = cache.GetOrSetWithTTL("a", 3, time.Minute)
+	assert.False(t, loaded)
+	assert.Equal(t, 3, actual)
+	assert.Equal(t, 1, cache.kv["a"].parent.Value.(*freqNode).freq)
+	assert.Equal(t, time.Unix(61, 0), cache.kv["a"].expireAt)
+}
+
+func TestCache_GetStale(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+	cache.SetWithTTL("a", 1, time.Minute)
+
+	// fresh
+	v, expired, ok := cache.GetStale("a")
+	assert.True(t, ok)
+	assert.False(t, expired)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	// expired but present, and kept
+	clock.Advance(time.Minute)
+	v, expired, ok = cache.GetStale("a")
+	assert.True(t, ok)
+	assert.True(t, expired)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+	v, expired, ok = cache.GetStale("a")
+	assert.True(t, ok)
+	assert.True(t, expired)
+	assert.Equal(t, 1, v)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Size: 1}, cache.Stats())
+
+	// Get still drops it
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+
+	// absent
+	v, expired, ok = cache.GetStale("a")
+	assert.False(t, ok)
+	assert.False(t, expired)
+	assert.Nil(t, v)
+}
+
+func TestCache_SetWithTTLFunc(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock))
+
+	// tokens expiring at a time of their own
+	type token struct{ exp time.Time }
+	ttlFn := func(v interface{}) time.Duration {
+		return v.(token).exp.Sub(clock.Now())
+	}
+	cache.SetWithTTLFunc("a", token{exp: time.Unix(10, 0)}, ttlFn)
+	cache.SetWithTTLFunc("b", token{exp: time.Unix(20, 0)}, ttlFn)
+	cache.SetWithTTLFunc("c", token{}, nil)
+
+	clock.Advance(10 * time.Second)
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	_, ok = cache.Get("b")
+	assert.True(t, ok)
+
+	clock.Advance(10 * time.Second)
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+
+	// an expiry in the past means no TTL, like a non-positive ttl does
+	cache.SetWithTTLFunc("d", token{exp: time.Unix(5, 0)}, ttlFn)
+	_, ok = cache.Get("d")
+	assert.True(t, ok)
+}
+
+func TestCache_DefaultTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithDefaultTTL(time.Minute))
+
+	cache.Set("a", 1)
+	cache.SetWithTTL("b", 2, 0)
+	cache.SetWithTTL("c", 3, time.Hour)
+	cache.Set("d", 4)
+
+	clock.Advance(time.Second)
+	// storing d again starts its TTL over
+	cache.Set("d", 5)
+
+	clock.Advance(time.Minute - time.Second)
+	_, ok := cache.Get("a")
+	assert.False(t, ok)
+	_, ok = cache.Get("b")
+	assert.True(t, ok)
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+	v, ok := cache.Get("d")
+	assert.True(t, ok)
+	assert.Equal(t, 5, v)
+
+	clock.Advance(time.Second)
+	_, ok = cache.Get("d")
+	assert.False(t, ok)
+}
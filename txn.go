@@ -0,0 +1,59 @@
+package lfu
+
+// Txn gives access to a cache inside Batch. Its methods work like those of
+// Cache of the same name.
+type Txn struct {
+	c    *Cache
+	done bool
+}
+
+// Batch calls fn with a Txn whose operations all run under a single held lock,
+// so other callers observe either none or all of them. fn must only use cache
+// through tx, and tx must not be used after Batch returns: doing so panics.
+func (c *Cache) Batch(fn func(tx *Txn)) {
+	c.lock()
+	defer c.unlock()
+	c.waitWritable()
+
+	tx := &Txn{c: c}
+	defer func() { tx.done = true }()
+	fn(tx)
+}
+
+// Set stores the given kv pair.
+func (tx *Txn) Set(k string, v interface{}) {
+	tx.check()
+	tx.c.set(tx.c.key(k), v)
+}
+
+// Get returns the v related to k. The ok indicates whether it is found in cache.
+func (tx *Txn) Get(k string) (v interface{}, ok bool) {
+	tx.check()
+	k = tx.c.key(k)
+
+	item, ok := tx.c.lookup(k)
+	if !ok {
+		tx.c.miss(k)
+		return
+	}
+	tx.c.hit(item)
+	return tx.c.clone(item.v), true
+}
+
+// Remove deletes k from cache. It reports whether k was in cache.
+func (tx *Txn) Remove(k string) bool {
+	tx.check()
+
+	item, ok := tx.c.kv[tx.c.key(k)]
+	if !ok {
+		return false
+	}
+	tx.c.deleteItem(item, ReasonDeleted)
+	return true
+}
+
+func (tx *Txn) check() {
+	if tx.done {
+		panic("lfu: Txn used after Batch returned")
+	}
+}
@@ -0,0 +1,69 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestCache_Batch(t *testing.T) {
+	cache := New(0)
+
+	var escaped *Txn
+	cache.Batch(func(tx *Txn) {
+		escaped = tx
+		tx.Set("a", 1)
+		tx.Set("b", 2)
+		v, ok := tx.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 1, v)
+		_, ok = tx.Get("c")
+		assert.False(t, ok)
+		assert.True(t, tx.Remove("b"))
+		assert.False(t, tx.Remove("b"))
+	})
+	assert.Equal(t, 1, cache.Size())
+	assert.Equal(t, 2, cache.kv["a"].parent.Value.(*freqNode).freq)
+
+	assert.Panics(t, func() { escaped.Set("c", 3) })
+	assert.Panics(t, func() { escaped.Get("a") })
+	assert.Panics(t, func() { escaped.Remove("a") })
+}
+
+func TestCache_BatchAtomic(t *testing.T) {
+	cache := New(0)
+	cache.Set("a", 100)
+	cache.Set("b", 0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				cache.Batch(func(tx *Txn) {
+					a, _ := tx.Get("a")
+					b, _ := tx.Get("b")
+					tx.Set("a", a.(int)-1)
+					tx.Set("b", b.(int)+1)
+				})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				cache.Batch(func(tx *Txn) {
+					a, _ := tx.Get("a")
+					b, _ := tx.Get("b")
+					assert.Equal(t, 100, a.(int)+b.(int))
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	a, _ := cache.Get("a")
+	b, _ := cache.Get("b")
+	assert.Equal(t, 100-8*200, a)
+	assert.Equal(t, 8*200, b)
+}
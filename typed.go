@@ -0,0 +1,269 @@
+package lfu
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Typed is an LFU cache of keys of type K and values of type V, for callers
+// who would otherwise box their values into interface{} and cast them back,
+// or format non-string keys, e.g. int64 IDs, into strings. It evicts like
+// Cache, the least frequently used item first, but has none of the features
+// configured through Config: it only counts frequencies.
+//
+// Its items are kept in freq nodes ordered by frequency, each linked through
+// the items themselves, oldest first, like those of Cache, so Set, Get and
+// eviction all run in constant time, and an eviction takes the oldest of the
+// least frequently used items.
+type Typed[K comparable, V any] struct {
+	mu sync.Mutex
+
+	cap      int
+	kv       map[K]*typedItem[K, V]
+	freqList *list.List
+}
+
+type typedItem[K comparable, V any] struct {
+	k      K
+	v      V
+	parent *list.Element
+	// prev and next are the neighbours of item in the freq node of parent.
+	prev, next *typedItem[K, V]
+}
+
+type typedFreqNode[K comparable, V any] struct {
+	freq       int
+	head, tail *typedItem[K, V]
+}
+
+// push appends item to node.
+func (node *typedFreqNode[K, V]) push(item *typedItem[K, V]) {
+	item.prev, item.next = node.tail, nil
+	if node.tail == nil {
+		node.head = item
+	} else {
+		node.tail.next = item
+	}
+	node.tail = item
+}
+
+// unlink removes item from node.
+func (node *typedFreqNode[K, V]) unlink(item *typedItem[K, V]) {
+	if item.prev == nil {
+		node.head = item.next
+	} else {
+		item.prev.next = item.next
+	}
+	if item.next == nil {
+		node.tail = item.prev
+	} else {
+		item.next.prev = item.prev
+	}
+	item.prev, item.next = nil, nil
+}
+
+// NewTyped creates a Typed cache of the given capacity. A non-positive cap
+// means the cache won't do any eviction.
+func NewTyped[K comparable, V any](cap int) *Typed[K, V] {
+	return &Typed[K, V]{
+		cap:      cap,
+		kv:       make(map[K]*typedItem[K, V]),
+		freqList: list.New(),
+	}
+}
+
+// Set stores the given kv pair. If k is in cache, its v is updated and its
+// frequency count incremented. Otherwise, the least frequently used item is
+// evicted if the cache is full, and k starts at a frequency of 1.
+func (c *Typed[K, V]) Set(k K, v V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.kv[k]; ok {
+		item.v = v
+		c.increment(item)
+		return
+	}
+	c.set(k, v)
+}
+
+// Get returns the v related to k, incrementing its frequency count. The ok
+// indicates whether it is found.
+func (c *Typed[K, V]) Get(k K) (v V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.kv[k]
+	if !ok {
+		return v, false
+	}
+	c.increment(item)
+	return item.v, true
+}
+
+// Peek returns the v related to k like Get, without counting an access.
+func (c *Typed[K, V]) Peek(k K) (v V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.kv[k]
+	if !ok {
+		return v, false
+	}
+	return item.v, true
+}
+
+// Frequency returns the frequency count of k, or 0 if k isn't in cache.
+func (c *Typed[K, V]) Frequency(k K) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.kv[k]
+	if !ok {
+		return 0
+	}
+	return item.parent.Value.(*typedFreqNode[K, V]).freq
+}
+
+// GetOrSet returns the v related to k if it is in cache, with loaded true,
+// incrementing its frequency count like Get. Otherwise it stores the given
+// v like Set and returns it, with loaded false.
+func (c *Typed[K, V]) GetOrSet(k K, v V) (actual V, loaded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if item, ok := c.kv[k]; ok {
+		c.increment(item)
+		return item.v, true
+	}
+	c.set(k, v)
+	return v, false
+}
+
+// Remove removes k from cache, reporting whether it was there. Removing a
+// key is not an eviction.
+func (c *Typed[K, V]) Remove(k K) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item, ok := c.kv[k]
+	if ok {
+		c.remove(item)
+	}
+	return ok
+}
+
+// Purge deletes every item.
+func (c *Typed[K, V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.kv = make(map[K]*typedItem[K, V])
+	c.freqList.Init()
+}
+
+// Evict evicts up to n least frequently used items, fewer if there aren't
+// that many. A non-positive n is a no-op.
+func (c *Typed[K, V]) Evict(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evict(n)
+}
+
+// Size returns the number of items.
+func (c *Typed[K, V]) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.kv)
+}
+
+// Range calls fn for every kv pair in cache, from the least to the most
+// frequently used, until fn returns false. The pairs are copied under the
+// lock and fn is called after releasing it, so fn may use cache.
+func (c *Typed[K, V]) Range(fn func(k K, v V) bool) {
+	c.mu.Lock()
+	items := make([]typedItem[K, V], 0, len(c.kv))
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		for item := e.Value.(*typedFreqNode[K, V]).head; item != nil; item = item.next {
+			items = append(items, typedItem[K, V]{k: item.k, v: item.v})
+		}
+	}
+	c.mu.Unlock()
+
+	for _, item := range items {
+		if !fn(item.k, item.v) {
+			return
+		}
+	}
+}
+
+// set stores k, which isn't in cache, at a frequency of 1, evicting the
+// least frequently used item first if the cache is full.
+func (c *Typed[K, V]) set(k K, v V) {
+	if c.cap > 0 && len(c.kv) >= c.cap {
+		c.evict(1)
+	}
+
+	item := &typedItem[K, V]{k: k, v: v}
+	c.kv[k] = item
+	c.moveTo(item, 1)
+}
+
+func (c *Typed[K, V]) evict(n int) {
+	for i := 0; i < n; i++ {
+		e := c.freqList.Front()
+		if e == nil {
+			return
+		}
+		c.remove(e.Value.(*typedFreqNode[K, V]).head)
+	}
+}
+
+func (c *Typed[K, V]) increment(item *typedItem[K, V]) {
+	c.moveTo(item, item.parent.Value.(*typedFreqNode[K, V]).freq+1)
+}
+
+// moveTo moves item to the freq node of freq, creating the node next to the
+// current one if needed, and removing the current one once it's empty.
+func (c *Typed[K, V]) moveTo(item *typedItem[K, V], freq int) {
+	cur := item.parent
+	next := c.freqList.Front()
+	if cur != nil {
+		next = cur.Next()
+	}
+
+	if next == nil || next.Value.(*typedFreqNode[K, V]).freq != freq {
+		node := &typedFreqNode[K, V]{freq: freq}
+		if cur == nil {
+			if next == nil {
+				next = c.freqList.PushFront(node)
+			} else {
+				next = c.freqList.InsertBefore(node, next)
+			}
+		} else {
+			next = c.freqList.InsertAfter(node, cur)
+		}
+	}
+
+	if cur != nil {
+		c.unlink(item, cur)
+	}
+	next.Value.(*typedFreqNode[K, V]).push(item)
+	item.parent = next
+}
+
+func (c *Typed[K, V]) remove(item *typedItem[K, V]) {
+	delete(c.kv, item.k)
+	c.unlink(item, item.parent)
+}
+
+// unlink removes item from the freq node e, and e from freqList once empty.
+func (c *Typed[K, V]) unlink(item *typedItem[K, V], e *list.Element) {
+	node := e.Value.(*typedFreqNode[K, V])
+	node.unlink(item)
+	if node.head == nil {
+		c.freqList.Remove(e)
+	}
+}
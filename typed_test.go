@@ -0,0 +1,108 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestTyped(t *testing.T) {
+	cache := NewTyped[int64, []byte](2)
+
+	cache.Set(1, []byte("a"))
+	cache.Set(2, []byte("b"))
+	v, ok := cache.Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("a"), v)
+	assert.Equal(t, 2, cache.Frequency(1))
+	assert.Equal(t, 1, cache.Frequency(2))
+
+	// 2 is the least frequently used
+	cache.Set(3, []byte("c"))
+	_, ok = cache.Peek(2)
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Size())
+
+	cache.Set(3, []byte("cc"))
+	v, _ = cache.Peek(3)
+	assert.Equal(t, []byte("cc"), v)
+	assert.Equal(t, 2, cache.Frequency(3))
+	assert.Equal(t, 0, cache.Frequency(2))
+
+	assert.True(t, cache.Remove(1))
+	assert.False(t, cache.Remove(1))
+	v, ok = cache.Get(1)
+	assert.False(t, ok)
+	assert.Nil(t, v)
+
+	cache.Evict(5)
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 0, cache.freqList.Len())
+}
+
+func TestTyped_Unbounded(t *testing.T) {
+	type point struct{ x, y int }
+	cache := NewTyped[point, string](0)
+	for i := 0; i < 100; i++ {
+		cache.Set(point{i, -i}, "p")
+	}
+	cache.Get(point{3, -3})
+	cache.Evict(99)
+	v, ok := cache.Get(point{3, -3})
+	assert.True(t, ok)
+	assert.Equal(t, "p", v)
+	assert.Equal(t, 1, cache.Size())
+}
+
+func TestTyped_Purge(t *testing.T) {
+	cache := NewTyped[string, int](2)
+	cache.Set("a", 1)
+	cache.Set("b", 2)
+	cache.Purge()
+	assert.Equal(t, 0, cache.Size())
+	assert.Equal(t, 0, cache.freqList.Len())
+
+	cache.Set("c", 3)
+	v, ok := cache.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, v)
+}
+
+func TestTyped_GetOrSetRange(t *testing.T) {
+	cache := NewTyped[int, string](0)
+	actual, loaded := cache.GetOrSet(1, "a")
+	assert.False(t, loaded)
+	assert.Equal(t, "a", actual)
+	actual, loaded = cache.GetOrSet(1, "b")
+	assert.True(t, loaded)
+	assert.Equal(t, "a", actual)
+	assert.Equal(t, 2, cache.Frequency(1))
+
+	cache.Set(2, "c")
+	var keys []int
+	cache.Range(func(k int, v string) bool {
+		keys = append(keys, k)
+		cache.Get(k) // Range doesn't hold the lock
+		return true
+	})
+	assert.Equal(t, []int{2, 1}, keys)
+}
+
+func TestTyped_EvictsOldestFirst(t *testing.T) {
+	cache := NewTyped[int, int](0)
+	for i := 0; i < 5; i++ {
+		cache.Set(i, i)
+	}
+	cache.Get(0)
+	cache.Get(1)
+	cache.Remove(3)
+
+	// 2 and 4 are the least frequently used, 2 the oldest of them; 0 and 1
+	// keep their order on moving up
+	cache.Evict(3)
+	var keys []int
+	cache.Range(func(k, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+	assert.Equal(t, []int{1}, keys)
+}
@@ -0,0 +1,138 @@
+package lfu
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidConfig is what the errors of Config.Validate wrap.
+var ErrInvalidConfig = errors.New("lfu: invalid config")
+
+// Validate reports the first setting of cfg that is out of range, or that
+// conflicts with another one or can't take effect, which NewWithConfig would
+// silently clamp or ignore instead. The error wraps ErrInvalidConfig and
+// names the settings at fault.
+func (cfg Config) Validate() error {
+	invalid := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: "+format, append([]interface{}{ErrInvalidConfig}, args...)...)
+	}
+
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"TrackMisses", cfg.TrackMisses},
+		{"MaxConcurrentLoads", cfg.MaxConcurrentLoads},
+		{"AccessBuffer", cfg.AccessBuffer},
+		{"HitRatioWindow", cfg.HitRatioWindow},
+		{"MaxKeyLength", cfg.MaxKeyLength},
+		{"FailureThreshold", cfg.FailureThreshold},
+		{"MaxTiers", cfg.MaxTiers},
+		{"LazyPromotions", cfg.LazyPromotions},
+		{"ReadBuffer", cfg.ReadBuffer},
+		{"FrequencyBuckets", cfg.FrequencyBuckets},
+		{"AdmissionWindow", cfg.AdmissionWindow},
+		{"RefreshWorkers", cfg.RefreshWorkers},
+		{"MaxDirty", cfg.MaxDirty},
+		{"EvictionBatch", cfg.EvictionBatch},
+		{"Shards", cfg.Shards},
+	} {
+		if n.value < 0 {
+			return invalid("negative %s %d", n.name, n.value)
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"Freshness", cfg.Freshness},
+		{"DefaultTTL", cfg.DefaultTTL},
+		{"CooldownDuration", cfg.CooldownDuration},
+		{"FrequencyWindow", cfg.FrequencyWindow},
+		{"ExpirySweepInterval", cfg.ExpirySweepInterval},
+		{"FrequencyDecayInterval", cfg.FrequencyDecayInterval},
+		{"RefreshAfter", cfg.RefreshAfter},
+		{"HeapCheckInterval", cfg.HeapCheckInterval},
+	} {
+		if d.value < 0 {
+			return invalid("negative %s %v", d.name, d.value)
+		}
+	}
+	if cfg.MaxBytes < 0 {
+		return invalid("negative MaxBytes %d", cfg.MaxBytes)
+	}
+
+	switch {
+	case cfg.TTLJitter < 0 || cfg.TTLJitter > 1:
+		return invalid("TTLJitter %v out of [0, 1]", cfg.TTLJitter)
+	case cfg.ProtectedRatio < 0 || cfg.ProtectedRatio >= 1:
+		return invalid("ProtectedRatio %v out of [0, 1)", cfg.ProtectedRatio)
+	case cfg.LowWatermark < 0 || cfg.LowWatermark >= 1:
+		return invalid("LowWatermark %v out of [0, 1)", cfg.LowWatermark)
+	}
+
+	bounded := cfg.Capacity > 0
+	switch {
+	case cfg.AdmissionWindow > 0 && !bounded:
+		return invalid("AdmissionWindow needs a positive Capacity")
+	case cfg.ProtectedRatio > 0 && !bounded:
+		return invalid("ProtectedRatio needs a positive Capacity")
+	case (cfg.EvictionBatch > 1 || cfg.LowWatermark > 0) && !bounded:
+		return invalid("EvictionBatch and LowWatermark need a positive Capacity")
+	case (cfg.EvictionBatch > 1 || cfg.LowWatermark > 0) && cfg.AdmissionWindow > 0:
+		return invalid("EvictionBatch and LowWatermark don't apply with AdmissionWindow")
+	case (cfg.FrequencyWindow > 0) != (cfg.FrequencyBuckets > 0):
+		return invalid("FrequencyWindow and FrequencyBuckets go together")
+	case (cfg.ValueEncoder == nil) != (cfg.ValueDecoder == nil):
+		return invalid("ValueEncoder and ValueDecoder go together")
+	case cfg.TruncateLongKeys && cfg.MaxKeyLength == 0:
+		return invalid("TruncateLongKeys needs a MaxKeyLength")
+	case cfg.FailureThreshold > 0 && cfg.CooldownDuration == 0:
+		return invalid("FailureThreshold needs a positive CooldownDuration")
+	case (cfg.HeapCheckInterval > 0 || cfg.HeapUsage != nil) && cfg.HeapLimit == 0:
+		return invalid("HeapCheckInterval and HeapUsage need a HeapLimit")
+	case cfg.WriteThrough && cfg.WriteBack == nil:
+		return invalid("WriteThrough needs a WriteBack")
+	}
+
+	if cfg.Shards > 1 {
+		switch {
+		case cfg.ExpirySweepInterval > 0:
+			return invalid("ExpirySweepInterval starts a goroutine per shard, which a Sharded can't Close")
+		case cfg.HeapLimit > 0:
+			return invalid("HeapLimit makes every shard watch the same heap, and a Sharded can't Close them")
+		case cfg.RandSource != nil:
+			return invalid("RandSource isn't safe for the concurrent use of shards")
+		case cfg.Capacity > 0 && cfg.Capacity < cfg.Shards:
+			return invalid("Capacity %d is less than one item per shard of %d", cfg.Capacity, cfg.Shards)
+		}
+	}
+	return nil
+}
+
+// NewChecked works like New, but validates the resulting Config first, and
+// returns the error of Validate rather than a cache that would ignore what
+// it was configured with. A Config with Shards needs NewShardedChecked.
+func NewChecked(cap int, opts ...Option) (*Cache, error) {
+	cfg := configOf(cap, opts)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Shards > 1 {
+		return nil, fmt.Errorf("%w: %d Shards need NewShardedChecked", ErrInvalidConfig, cfg.Shards)
+	}
+	return NewWithConfig(cfg), nil
+}
+
+// NewShardedChecked works like NewChecked, returning a Sharded of Shards
+// shards, one if not set, each configured like cfg with an equal part of
+// Capacity, MaxBytes, MaxDirty and AdmissionWindow. Warmup is called once,
+// adding to the shards of its keys.
+func NewShardedChecked(cap int, opts ...Option) (*Sharded, error) {
+	cfg := configOf(cap, opts)
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newSharded(cfg), nil
+}
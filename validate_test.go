@@ -0,0 +1,66 @@
+package lfu
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		cfg  Config
+		err  string
+	}{
+		{"admission unbounded", Config{AdmissionWindow: 10}, "AdmissionWindow needs a positive Capacity"},
+		{"protected ratio", Config{Capacity: 10, ProtectedRatio: 1.5}, "ProtectedRatio 1.5 out of [0, 1)"},
+		{"low watermark", Config{Capacity: 10, LowWatermark: 1}, "LowWatermark 1 out of [0, 1)"},
+		{"batch with admission", Config{Capacity: 10, AdmissionWindow: 2, EvictionBatch: 3}, "EvictionBatch and LowWatermark don't apply with AdmissionWindow"},
+		{"negative ttl", Config{DefaultTTL: -time.Second}, "negative DefaultTTL -1s"},
+		{"negative count", Config{ReadBuffer: -1}, "negative ReadBuffer -1"},
+		{"half a codec", Config{ValueEncoder: gobEncode}, "ValueEncoder and ValueDecoder go together"},
+		{"write through", Config{WriteThrough: true}, "WriteThrough needs a WriteBack"},
+		{"sharded janitor", Config{Shards: 4, ExpirySweepInterval: time.Second}, "ExpirySweepInterval starts a goroutine per shard, which a Sharded can't Close"},
+		{"sharded capacity", Config{Capacity: 2, Shards: 4}, "Capacity 2 is less than one item per shard of 4"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			assert.True(t, errors.Is(err, ErrInvalidConfig))
+			assert.EqualError(t, err, "lfu: invalid config: "+tt.err)
+		})
+	}
+
+	assert.NoError(t, Config{}.Validate())
+	assert.NoError(t, Config{Capacity: 10, AdmissionWindow: 2, ProtectedRatio: 0.8, EvictionBatch: 1}.Validate())
+}
+
+func TestNewChecked(t *testing.T) {
+	// the defaults are those of New
+	cache, err := NewChecked(2)
+	assert.NoError(t, err)
+	assert.Equal(t, New(2).Stats(), cache.Stats())
+
+	_, err = NewChecked(0, WithAdmissionWindow(10))
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+	_, err = NewChecked(10, WithShards(2))
+	assert.EqualError(t, err, "lfu: invalid config: 2 Shards need NewShardedChecked")
+}
+
+func TestNewShardedChecked(t *testing.T) {
+	s, err := NewShardedChecked(8, WithShards(4), WithDefaultTTL(time.Minute),
+		WithWarmup(func(add func(k string, v interface{})) {
+			for _, k := range []string{"a", "b", "c"} {
+				add(k, k)
+			}
+		}))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(s.ShardSizes()))
+	assert.Equal(t, 3, s.Size())
+	v, ok := s.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, "b", v)
+
+	_, err = NewShardedChecked(8, WithShards(4), WithExpirySweep(time.Second))
+	assert.True(t, errors.Is(err, ErrInvalidConfig))
+}
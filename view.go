@@ -0,0 +1,80 @@
+package lfu
+
+// View is an immutable point-in-time copy of the keys, values and frequency
+// counts of a cache, returned by Clone. It is safe for concurrent use, and
+// doesn't change when cache does.
+type View struct {
+	entries []Entry
+	index   map[string]int
+	key     func(k string) string
+}
+
+// Clone returns a View of cache as it is now, e.g. for a debugging dump or to
+// hand a stable copy to an analytics job. Unlike Snapshot, it holds the lock
+// only for a shallow copy of the items: the values are cloned, with
+// Config.CloneFunc, and indexed after releasing it, so writers wait for one
+// pass over cache rather than the whole copy. Expired and negative entries
+// are left out. Freq accounts for the hits queued by LazyPromotions.
+func (c *Cache) Clone() *View {
+	c.lock()
+	entries := make([]Entry, 0, len(c.kv))
+	for e := c.freqList.Front(); e != nil; e = e.Next() {
+		node := e.Value.(*freqNode)
+		for item := node.head; item != nil; item = item.next {
+			if c.listed(item) {
+				entries = append(entries, Entry{Key: item.k, Value: item.v, Freq: node.freq + item.queuedHits, ExpiresAt: item.expireAt})
+			}
+		}
+	}
+	c.unlock()
+
+	index := make(map[string]int, len(entries))
+	for i := range entries {
+		entries[i].Value = c.clone(entries[i].Value)
+		index[entries[i].Key] = i
+	}
+	return &View{entries: entries, index: index, key: c.key}
+}
+
+// Len returns the number of entries in v.
+func (v *View) Len() int {
+	return len(v.entries)
+}
+
+// Get returns the value of k in v. Unlike Cache.Get, it doesn't count as an
+// access, and it doesn't clone the value again: the callers of Get share the
+// copy in v, which they must not modify.
+func (v *View) Get(k string) (value interface{}, ok bool) {
+	i, ok := v.index[v.key(k)]
+	if !ok {
+		return nil, false
+	}
+	return v.entries[i].Value, true
+}
+
+// GetFrequency returns the frequency count k had in cache.
+func (v *View) GetFrequency(k string) (freq int, ok bool) {
+	i, ok := v.index[v.key(k)]
+	if !ok {
+		return 0, false
+	}
+	return v.entries[i].Freq, true
+}
+
+// Entries returns a copy of the entries in v, ordered from the least to the
+// most frequently used.
+func (v *View) Entries() []Entry {
+	entries := make([]Entry, len(v.entries))
+	copy(entries, v.entries)
+	return entries
+}
+
+// Range calls fn for every entry in v, from the least to the most frequently
+// used, until fn returns false.
+func (v *View) Range(fn func(e Entry) bool) {
+	for _, e := range v.entries {
+		if !fn(e) {
+			return
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCache_Clone(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(0, WithClock(clock), WithKeyNormalizer(strings.ToLower),
+		WithCloneFunc(func(v interface{}) interface{} {
+			return append([]int(nil), v.([]int)...)
+		}))
+	cache.Set("a", []int{1})
+	cache.Set("b", []int{2})
+	cache.Get("b")
+	cache.SetWithTTL("gone", []int{3}, time.Second)
+	cache.StoreMiss("missing", 0)
+	clock.Advance(time.Second)
+
+	view := cache.Clone()
+	cache.Set("a", []int{4})
+	cache.Set("c", []int{5})
+	cache.Get("b")
+
+	assert.Equal(t, 2, view.Len())
+	v, ok := view.Get("A")
+	assert.True(t, ok)
+	assert.Equal(t, []int{1}, v)
+	_, ok = view.Get("c")
+	assert.False(t, ok)
+	_, ok = view.Get("gone")
+	assert.False(t, ok)
+	_, ok = view.Get("missing")
+	assert.False(t, ok)
+	freq, ok := view.GetFrequency("b")
+	assert.True(t, ok)
+	assert.Equal(t, 2, freq)
+	assert.Equal(t, []Entry{{Key: "a", Value: []int{1}, Freq: 1}, {Key: "b", Value: []int{2}, Freq: 2}}, view.Entries())
+
+	// the values were cloned
+	v, _ = view.Get("b")
+	v.([]int)[0] = 6
+	v, _ = cache.Get("b")
+	assert.Equal(t, []int{2}, v)
+
+	var keys []string
+	view.Range(func(e Entry) bool {
+		keys = append(keys, e.Key)
+		return false
+	})
+	assert.Equal(t, []string{"a"}, keys)
+}
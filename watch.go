@@ -0,0 +1,31 @@
+package lfu
+
+// Watch makes cache call fn on every lookup of k by the Get methods, with
+// the value found and whether it was a hit, e.g. to trace why a hot key
+// behaves unexpectedly. fn runs after the lookup releases the lock, so it
+// may use cache. Watching a watched k replaces its fn.
+func (c *Cache) Watch(k string, fn func(v interface{}, hit bool)) {
+	c.lock()
+	defer c.unlock()
+
+	if c.watchers == nil {
+		c.watchers = make(map[string]func(v interface{}, hit bool))
+	}
+	c.watchers[c.key(k)] = fn
+}
+
+// Unwatch stops the calls set up by Watch for k.
+func (c *Cache) Unwatch(k string) {
+	c.lock()
+	defer c.unlock()
+
+	delete(c.watchers, c.key(k))
+}
+
+// watched queues the call of the watcher of k, if any, for a lookup of k
+// that found v, or nothing. The caller must hold the lock.
+func (c *Cache) watched(k string, v interface{}, hit bool) {
+	if fn, ok := c.watchers[k]; ok {
+		c.after = append(c.after, func() { fn(v, hit) })
+	}
+}
@@ -0,0 +1,36 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCache_Watch(t *testing.T) {
+	type call struct {
+		v   interface{}
+		hit bool
+	}
+	var calls []call
+	cache := New(1)
+	cache.Watch("a", func(v interface{}, hit bool) {
+		calls = append(calls, call{v, hit})
+		// outside the lock
+		cache.Size()
+	})
+
+	cache.Get("a")
+	cache.Set("a", 1)
+	cache.Get("a")
+	cache.GetBatch([]string{"a", "b"})
+	cache.Set("a", 2)
+	cache.GetOrDefault("a", 0)
+	cache.Peek("a")
+	cache.Get("b")
+	cache.Set("b", 3) // evicts a
+	cache.Get("a")
+	assert.Equal(t, []call{{nil, false}, {1, true}, {1, true}, {2, true}, {nil, false}}, calls)
+
+	cache.Unwatch("a")
+	cache.Get("a")
+	assert.Equal(t, 5, len(calls))
+}
@@ -0,0 +1,94 @@
+package lfu
+
+import "time"
+
+// frequencyWindow counts the accesses of every item into time buckets, for
+// Config.FrequencyWindow.
+type frequencyWindow struct {
+	width   time.Duration
+	buckets int
+	// epoch is the bucket the frequencies in freqList were scored at.
+	epoch int64
+}
+
+// windowCounts is the access counts of an item in the latest buckets: at is
+// the latest bucket counted, and counts[at % buckets] its count.
+type windowCounts struct {
+	at     int64
+	counts []int
+}
+
+// epoch returns the index of the current bucket.
+func (c *Cache) epoch() int64 {
+	return c.now().UnixNano() / int64(c.window.width)
+}
+
+// bucketOf returns the index in the counts of an item of the given bucket.
+func (w *frequencyWindow) bucketOf(epoch int64) int {
+	k := int64(w.buckets)
+	return int(((epoch % k) + k) % k)
+}
+
+// add counts n accesses of item in the bucket epoch.
+func (w *frequencyWindow) add(item *kvItem, epoch int64, n int) {
+	wc := &item.window
+	if wc.counts == nil {
+		wc.counts = make([]int, w.buckets)
+		wc.at = epoch
+	}
+	for e := wc.at + 1; e <= epoch && e <= wc.at+int64(w.buckets); e++ {
+		wc.counts[w.bucketOf(e)] = 0
+	}
+	if epoch > wc.at {
+		wc.at = epoch
+	}
+	wc.counts[w.bucketOf(epoch)] += n
+}
+
+// reset makes freq the only accesses of item, counted in the bucket epoch.
+func (w *frequencyWindow) reset(item *kvItem, epoch int64, freq int) {
+	item.window = windowCounts{}
+	w.add(item, epoch, freq)
+}
+
+// score returns the frequency of item in the bucket epoch: its counts of the
+// latest buckets weighted by how recent they are, from 1 for the current
+// bucket down to 1/buckets for the oldest one counted, and at least 1.
+func (w *frequencyWindow) score(item *kvItem, epoch int64) int {
+	wc := item.window
+	k := int64(w.buckets)
+	sum := int64(0)
+	for e := wc.at; e > wc.at-k && e > epoch-k; e-- {
+		if e <= epoch {
+			sum += int64(wc.counts[w.bucketOf(e)]) * (k - (epoch - e))
+		}
+	}
+	if freq := int(sum / k); freq > 1 {
+		return freq
+	}
+	return 1
+}
+
+// touchWindow counts n accesses of item now and moves it to its new
+// frequency. The caller must hold the lock.
+func (c *Cache) touchWindow(item *kvItem, n int) {
+	epoch := c.epoch()
+	c.age(epoch)
+	c.window.add(item, epoch, n)
+	c.moveTo(item, c.window.score(item, epoch))
+}
+
+// age scores every item again once the current bucket moved on since they
+// were last scored, so the items hot only in buckets gone by drop toward
+// eviction. It costs a pass over the items once per bucket width at most.
+// The caller must hold the lock.
+func (c *Cache) age(epoch int64) {
+	w := c.window
+	if w == nil || epoch <= w.epoch || c.frozen {
+		return
+	}
+	w.epoch = epoch
+	for _, item := range c.kv {
+		c.moveTo(item, w.score(item, epoch))
+	}
+}
@@ -0,0 +1,52 @@
+package lfu
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestCache_FrequencyWindow(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	cache := New(2, WithClock(clock), WithFrequencyWindow(time.Minute, 60))
+	freq := func(k string) int {
+		return cache.kv[k].parent.Value.(*freqNode).freq
+	}
+
+	// hot an hour ago
+	cache.Set("old", 1)
+	for i := 0; i < 99; i++ {
+		cache.Get("old")
+	}
+	assert.Equal(t, 100, freq("old"))
+
+	// half an hour later, old weighs half as much, and still beats a key
+	// hot now
+	clock.Advance(30 * time.Minute)
+	cache.Set("new", 2)
+	for i := 0; i < 9; i++ {
+		cache.Get("new")
+	}
+	assert.Equal(t, 50, freq("old"))
+	assert.Equal(t, 10, freq("new"))
+	cache.Set("x", 3)
+	assert.ElementsMatch(t, []string{"old", "x"}, cache.Keys())
+
+	// an hour later, old is out of the window and ranks below new
+	clock.Advance(31 * time.Minute)
+	cache.Set("new", 2)
+	cache.Get("new")
+	cache.Get("new")
+	assert.Equal(t, 3, freq("new"))
+	cache.Set("y", 4)
+	assert.ElementsMatch(t, []string{"new", "y"}, cache.Keys())
+
+	// explicit frequencies are counted in the current bucket
+	cache.SetFrequency("y", 30)
+	assert.Equal(t, 30, freq("y"))
+	clock.Advance(30 * time.Minute)
+	cache.Get("new")
+	assert.Equal(t, 15, freq("y"))
+	assert.Equal(t, 2, freq("new"))
+	assert.NoError(t, cache.Verify())
+}
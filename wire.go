@@ -0,0 +1,179 @@
+package lfu
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// wireVersion is the first byte of what WriteTo writes, so ReadFrom can tell
+// the format apart from later ones.
+const wireVersion = 1
+
+// ErrNoCodec is returned by WriteTo and ReadFrom when cache has no
+// ValueEncoder, respectively ValueDecoder, configured.
+var ErrNoCodec = errors.New("lfu: no value codec configured")
+
+var (
+	_ io.WriterTo   = (*Cache)(nil)
+	_ io.ReaderFrom = (*Cache)(nil)
+)
+
+// WriteTo writes the entries of cache to w in a compact binary format, with
+// the values encoded by the configured ValueEncoder, e.g. to warm another
+// process through a pipe or a file with ReadFrom. It returns the number of
+// bytes written. The entries are copied under the lock, like Snapshot does,
+// and encoded after releasing it. Expired entries are left out, and so are
+// the negative ones of StoreMiss, as the format doesn't carry their TTL.
+//
+// The format is a version byte and the number of entries, followed by the
+// entries from the least to the most frequently used, each being its key,
+// frequency and encoded value. Numbers are uvarints, and the key and the
+// value are prefixed with their length.
+func (c *Cache) WriteTo(w io.Writer) (int64, error) {
+	if c.encode == nil {
+		return 0, ErrNoCodec
+	}
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	entries := c.Snapshot()
+
+	var buf [binary.MaxVarintLen64]byte
+	writeUvarint := func(x uint64) {
+		bw.Write(buf[:binary.PutUvarint(buf[:], x)])
+	}
+
+	bw.WriteByte(wireVersion)
+	writeUvarint(uint64(len(entries)))
+	for _, e := range entries {
+		v, err := c.encode(e.Value)
+		if err != nil {
+			bw.Flush()
+			return cw.n, fmt.Errorf("lfu: encode value of %q: %w", e.Key, err)
+		}
+		writeUvarint(uint64(len(e.Key)))
+		bw.WriteString(e.Key)
+		writeUvarint(uint64(e.Freq))
+		writeUvarint(uint64(len(v)))
+		bw.Write(v)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// ReadFrom reads entries written by WriteTo from r, decodes their values with
+// the configured ValueDecoder, and stores them with their frequencies like
+// Restore does. It returns the number of bytes read, and never reads past
+// the entries, so r may go on with other data. Nothing is stored if reading
+// or decoding fails.
+func (c *Cache) ReadFrom(r io.Reader) (int64, error) {
+	if c.decode == nil {
+		return 0, ErrNoCodec
+	}
+
+	cr := &countingReader{r: r}
+	version, err := cr.ReadByte()
+	if err != nil {
+		return cr.n, err
+	}
+	if version != wireVersion {
+		return cr.n, fmt.Errorf("lfu: unknown wire format version %d", version)
+	}
+
+	count, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return cr.n, err
+	}
+
+	var entries []Entry
+	for i := uint64(0); i < count; i++ {
+		key, err := cr.readBytes()
+		if err != nil {
+			return cr.n, err
+		}
+		freq, err := binary.ReadUvarint(cr)
+		if err != nil {
+			return cr.n, err
+		}
+		data, err := cr.readBytes()
+		if err != nil {
+			return cr.n, err
+		}
+		v, err := c.decode(data)
+		if err != nil {
+			return cr.n, fmt.Errorf("lfu: decode value of %q: %w", key, err)
+		}
+		entries = append(entries, Entry{Key: string(key), Value: v, Freq: int(freq)})
+	}
+
+	c.Restore(entries)
+	return cr.n, nil
+}
+
+// gobEncode encodes v with encoding/gob as an interface value, so gobDecode
+// gets back its concrete type.
+func gobEncode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(&v)
+	return buf.Bytes(), err
+}
+
+func gobDecode(data []byte) (interface{}, error) {
+	var v interface{}
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+	return v, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// countingReader reads exactly what it is asked for from r, without
+// buffering ahead, and counts it.
+type countingReader struct {
+	r   io.Reader
+	n   int64
+	buf [1]byte
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	_, err := cr.read(cr.buf[:])
+	return cr.buf[0], err
+}
+
+// readBytes reads a length-prefixed byte string.
+func (cr *countingReader) readBytes() ([]byte, error) {
+	size, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return nil, err
+	}
+	// grow as the data comes rather than trusting size with an allocation
+	var b bytes.Buffer
+	n, err := io.CopyN(&b, cr.r, int64(size))
+	cr.n += n
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return b.Bytes(), err
+}
+
+func (cr *countingReader) read(p []byte) (int, error) {
+	n, err := io.ReadFull(cr.r, p)
+	cr.n += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,117 @@
+package lfu
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func intCodec() Option {
+	return WithValueCodec(func(v interface{}) ([]byte, error) {
+		return []byte(strconv.Itoa(v.(int))), nil
+	}, func(data []byte) (interface{}, error) {
+		return strconv.Atoi(string(data))
+	})
+}
+
+func TestCache_WriteToReadFrom(t *testing.T) {
+	src := New(0, intCodec())
+	src.SetWithFrequency("a", 1, 1)
+	src.SetWithFrequency("bb", 22, 300)
+
+	var buf bytes.Buffer
+	n, err := src.WriteTo(&buf)
+	assert.NoError(t, err)
+	// version, count, then key length, key, freq, value length, value
+	assert.Equal(t, int64(1+1+(1+1+1+1+1)+(1+2+2+1+2)), n)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	buf.WriteString("trailer")
+	dst := New(0, intCodec())
+	m, err := dst.ReadFrom(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, n, m)
+	assert.Equal(t, "trailer", buf.String())
+	assert.Equal(t, src.Snapshot(), dst.Snapshot())
+}
+
+func TestCache_WriteToExpired(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	src := New(0, intCodec(), WithClock(clock))
+	src.Set("a", 1)
+	src.SetWithTTL("b", 2, time.Second)
+	clock.Advance(time.Second)
+
+	var buf bytes.Buffer
+	_, err := src.WriteTo(&buf)
+	assert.NoError(t, err)
+	dst := New(0, intCodec())
+	_, err = dst.ReadFrom(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dst.Size())
+	_, ok := dst.Get("b")
+	assert.False(t, ok)
+}
+
+type gobPoint struct{ X, Y int }
+
+func TestCache_GobCodec(t *testing.T) {
+	gob.Register(gobPoint{})
+
+	src := New(0, WithGobCodec())
+	src.Set("int", 1)
+	src.Set("str", "s")
+	src.SetWithFrequency("point", gobPoint{1, 2}, 5)
+	src.Set("bytes", []byte("b"))
+
+	var buf bytes.Buffer
+	_, err := src.WriteTo(&buf)
+	assert.NoError(t, err)
+
+	dst := New(0, WithGobCodec())
+	_, err = dst.ReadFrom(&buf)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, src.Snapshot(), dst.Snapshot())
+	v, _ := dst.Peek("point")
+	assert.Equal(t, gobPoint{1, 2}, v)
+
+	// unregistered types fail to encode
+	src.Set("chan", make(chan int))
+	_, err = src.WriteTo(io.Discard)
+	assert.Error(t, err)
+}
+
+func TestCache_WriteToReadFromErrors(t *testing.T) {
+	cache := New(0)
+	_, err := cache.WriteTo(io.Discard)
+	assert.Equal(t, ErrNoCodec, err)
+	_, err = cache.ReadFrom(bytes.NewReader(nil))
+	assert.Equal(t, ErrNoCodec, err)
+
+	var buf bytes.Buffer
+	src := New(0, intCodec())
+	src.Set("a", 1)
+	src.Set("b", 2)
+	src.WriteTo(&buf)
+
+	// a truncated stream stores nothing
+	dst := New(0, intCodec())
+	n, err := dst.ReadFrom(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+	assert.Equal(t, int64(buf.Len()-1), n)
+	assert.Equal(t, 0, dst.Size())
+
+	_, err = dst.ReadFrom(bytes.NewReader([]byte{9}))
+	assert.EqualError(t, err, "lfu: unknown wire format version 9")
+
+	boom := errors.New("boom")
+	src = New(0, WithValueCodec(func(v interface{}) ([]byte, error) { return nil, boom }, nil))
+	src.Set("a", 1)
+	_, err = src.WriteTo(io.Discard)
+	assert.True(t, errors.Is(err, boom))
+}